@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/rules"
+)
+
+var applyDiff bool
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <rules.yaml>",
+	Short: "Run the steps of a rules file on the vault",
+	Long: `Run the rules of the file one after the other. Each rule selects the notes with
+a query, finds the matches in them and runs an action on the matches:
+
+  rules:
+    - name: todo-to-task
+      select: "tag:#project -status:done"
+      match:
+        regex: 'TODO\((\w+)\)'
+      action:
+        replace: '- [ ] $1'
+    - name: archive-done
+      select: "status:done folder:Projects"
+      action:
+        move: Archive/
+
+The matchers are regex, literal, heading, ignore_case and scope, which is one
+of body, frontmatter, all and section. The actions are replace, insert_before,
+insert_after, remove, set_frontmatter and move.
+
+Nothing is written unless every rule runs fine, and then all of the changes are
+written together. With --dry-run the changes are shown as a diff instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := rules.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		plan, err := rules.Run(v, g, r, f)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		// The diff is only shown as text, the records are the stats.
+		if (dryRun || applyDiff) && !w.Structured() {
+			d, err := plan.Diff()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(cmd.OutOrStdout(), d)
+		}
+		for _, st := range plan.Stats {
+			r := output.NewRecord(output.KIND_RULE, "").
+				With("rule", st.Rule).
+				With("notes", st.Notes).
+				With("matches", st.Matches)
+			if err := w.Write(r, fmt.Sprintf("%s: %d notes, %d matches", st.Rule, st.Notes, st.Matches)); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return nil
+		}
+		return plan.Commit()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	addDryRunFlag(applyCmd)
+
+	applyCmd.Flags().BoolVar(&applyDiff, "diff", false, "show the diff of the changes while applying them")
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/attachments"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/obsidian"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	attachmentsTrash  bool
+	attachmentsFolder string
+)
+
+var attachmentsCmd = &cobra.Command{
+	Use:   "attachments",
+	Short: "Find and manage the attachments of the notes",
+	Long: `Find and manage the attachments, the files which are not notes. An attachment
+is used if any note links to or embeds it.`,
+}
+
+var attachmentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the attachments with the number of links to them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAttachments(func(v *vault.Vault, g api.Group, r *vault.Resolver, rep *attachments.Report) error {
+			w, err := newWriter(cmd)
+			if err != nil {
+				return err
+			}
+			for _, a := range rep.Attachments {
+				rec := attachmentRecord(a)
+				if err := w.Write(rec, fmt.Sprintf("%6d  %10d  %s", len(a.References), a.Size, a.Path)); err != nil {
+					return err
+				}
+			}
+			return w.Close()
+		})
+	},
+}
+
+var attachmentsUnusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "List the attachments no note links to",
+	Long: `List the attachments no note links to, and exit with 1 if there are any. With
+--trash they are moved to the .trash folder of the vault.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAttachments(func(v *vault.Vault, g api.Group, r *vault.Resolver, rep *attachments.Report) error {
+			w, err := newWriter(cmd)
+			if err != nil {
+				return err
+			}
+
+			unused := rep.Unused()
+			size := int64(0)
+			for _, a := range unused {
+				size += a.Size
+				text := a.Path
+				if attachmentsTrash && !dryRun {
+					dest, err := v.Trash(a.Path)
+					if err != nil {
+						return err
+					}
+					text = fmt.Sprintf("%s -> %s", a.Path, dest)
+				}
+				if err := w.Write(attachmentRecord(a), text); err != nil {
+					return err
+				}
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+
+			if len(unused) > 0 && !attachmentsTrash {
+				return findings("%d unused attachments, %d bytes", len(unused), size)
+			}
+			return nil
+		})
+	},
+}
+
+var attachmentsMissingCmd = &cobra.Command{
+	Use:   "missing",
+	Short: "List the links to the attachments which don't exist",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAttachments(func(v *vault.Vault, g api.Group, r *vault.Resolver, rep *attachments.Report) error {
+			w, err := newWriter(cmd)
+			if err != nil {
+				return err
+			}
+
+			sets := make(map[string]api.Set)
+			for _, s := range g.Sets() {
+				sets[v.RelPath(s)] = s
+			}
+			for _, ref := range rep.Missing {
+				data := *sets[ref.Note].Data()
+				rec := output.NewMatchRecord(output.KIND_LINK, ref.Note, data, metadata.NewLineIndex(data), ref.Link.Match).
+					With("target", ref.Link.Target).
+					With("embed", ref.Link.Embed)
+				if err := w.Write(rec, fmt.Sprintf("%s:%d: %s", ref.Note, ref.Link.Line, ref.Link.Target)); err != nil {
+					return err
+				}
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+
+			if len(rep.Missing) > 0 {
+				return findings("%d missing attachments", len(rep.Missing))
+			}
+			return nil
+		})
+	},
+}
+
+var attachmentsMoveCmd = &cobra.Command{
+	Use:   "move",
+	Short: "Move the attachments into the attachment folder",
+	Long: `Move every linked attachment into the attachment folder of the first note
+linking to it, and rewrite the links and embeds. The folder is read from the
+attachment_folder setting, or from the attachmentFolderPath of
+.obsidian/app.json: '/' is the vault root, './' is the folder of the note and
+'./name' is a sub folder of it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAttachments(func(v *vault.Vault, g api.Group, r *vault.Resolver, rep *attachments.Report) error {
+			folder := attachmentsFolder
+			if folder == "" {
+				folder = cfg.Settings.AttachmentFolder
+			}
+			if folder == "" {
+				app, err := obsidian.LoadApp(v)
+				if err != nil {
+					return err
+				}
+				folder = app.AttachmentFolderPath
+			}
+
+			changed, moves, err := attachments.Relocate(v, g, r, rep, folder)
+			if err != nil {
+				return err
+			}
+
+			w, err := newWriter(cmd)
+			if err != nil {
+				return err
+			}
+			for _, m := range moves {
+				rec := output.NewRecord(output.KIND_FILE, m.From).With("to", m.To)
+				if err := w.Write(rec, fmt.Sprintf("%s -> %s", m.From, m.To)); err != nil {
+					return err
+				}
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+
+			if dryRun {
+				return nil
+			}
+			tx := v.Begin()
+			for _, s := range changed {
+				tx.Write(s)
+			}
+			for _, m := range moves {
+				tx.Rename(m.From, m.To)
+			}
+			return tx.Commit()
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attachmentsCmd)
+	attachmentsCmd.AddCommand(attachmentsListCmd, attachmentsUnusedCmd, attachmentsMissingCmd, attachmentsMoveCmd)
+	addDryRunFlag(attachmentsUnusedCmd)
+	addDryRunFlag(attachmentsMoveCmd)
+
+	attachmentsUnusedCmd.Flags().BoolVar(&attachmentsTrash, "trash", false, "move the unused attachments to the trash")
+	attachmentsMoveCmd.Flags().StringVar(&attachmentsFolder, "folder", "", "attachment folder, overrides the settings")
+}
+
+// Loads the vault and scans it for the attachments.
+func withAttachments(fn func(v *vault.Vault, g api.Group, r *vault.Resolver, rep *attachments.Report) error) error {
+	v, g, err := loadVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	r, err := v.Resolver()
+	if err != nil {
+		return err
+	}
+
+	rep, err := attachments.Scan(v, g, r)
+	if err != nil {
+		return err
+	}
+	return fn(v, g, r, rep)
+}
+
+// Creates the record of an attachment.
+func attachmentRecord(a attachments.Attachment) output.Record {
+	notes := make([]string, 0, len(a.References))
+	for _, ref := range a.References {
+		notes = append(notes, ref.Note)
+	}
+	return output.NewRecord(output.KIND_FILE, a.Path).
+		With("size", a.Size).
+		With("references", len(a.References)).
+		With("notes", notes)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/autolink"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	autolinkOptions autolink.Options
+	autolinkApply   bool
+)
+
+var autolinkCmd = &cobra.Command{
+	Use:   "autolink [note...]",
+	Short: "Find and link the unlinked mentions of other notes",
+	Long: `Find the plain text occurrences of other notes' titles and aliases. Code,
+comments, headings and the existing links are skipped. The mentions are listed
+for review, with --apply they are converted into [[Title|matched text]] links.
+
+Without any notes, the whole vault is scanned.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		linker, err := autolink.NewLinker(v, g, r, autolinkOptions)
+		if err != nil {
+			return err
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range sets {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+
+			mentions, err := linker.Find(s, md)
+			if err != nil {
+				return err
+			}
+
+			data := *s.Data()
+			lines := metadata.NewLineIndex(data)
+			for _, m := range mentions {
+				link := linker.Link(m)
+				r := output.NewMatchRecord(output.KIND_MENTION, md.Path, data, lines, m.Match).
+					With("target", m.Target).
+					With("link", link)
+				if err := w.Write(r, fmt.Sprintf("%s:%d:%d: %s -> %s", md.Path, m.Line, m.Col, m.Text, link)); err != nil {
+					return err
+				}
+			}
+
+			if !autolinkApply {
+				continue
+			}
+			if ok, err := linker.Apply(s, mentions); err != nil {
+				return err
+			} else if ok {
+				if err := saveNote(v, s); err != nil {
+					return err
+				}
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(autolinkCmd)
+	addDryRunFlag(autolinkCmd)
+
+	autolinkCmd.Flags().BoolVar(&autolinkApply, "apply", false, "convert the mentions into links")
+	autolinkCmd.Flags().BoolVar(&autolinkOptions.FirstOnly, "first-only", false, "only link the first mention of each note")
+	autolinkCmd.Flags().BoolVar(&autolinkOptions.CaseSensitive, "case-sensitive", false, "match the titles case sensitively")
+	autolinkCmd.Flags().IntVar(&autolinkOptions.MinLength, "min-length", autolink.DEFAULT_MIN_LENGTH, "ignore titles shorter than this")
+}
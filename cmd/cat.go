@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var catCmd = &cobra.Command{
+	Use:   "cat <note...>",
+	Short: "Print the notes",
+	Long:  `Print the contents of the notes. Notes can be given by their paths or titles.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, s := range sets {
+			// The text is written as is, without the line the writer adds.
+			if !w.Structured() {
+				if _, err := cmd.OutOrStdout().Write(*s.Data()); err != nil {
+					return err
+				}
+				continue
+			}
+			r := output.NewRecord(output.KIND_NOTE, v.RelPath(s)).With("content", string(*s.Data()))
+			if err := w.Write(r, ""); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/config"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"gopkg.in/yaml.v3"
+)
+
+var configGlobal bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and write the configuration",
+	Long: `Read and write the configuration. The settings are merged from the global
+file, the selected profile in it, the .odm/config file of the vault and the
+ODM_* environment variables, the later ones win. The keys are:
+
+  vault              path of the vault
+  ignore             glob patterns of the files which are not loaded
+  link_style         wikilink or markdown
+  link_path          shortest, relative or absolute
+  date_format        moment.js date format, e.g. YYYY-MM-DD
+  time_format        moment.js time format, e.g. HH:mm
+  attachment_folder  folder of the new attachments
+  template_folder    folder of the templates of 'odm new'
+  lint               lint rules, see 'odm lint --help'
+  schemas            frontmatter schemas, see 'odm validate --help'
+
+Profiles are defined in the global file under 'profiles', each with its own
+settings, and selected with --profile, ODM_PROFILE or the 'profile' key.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, ok := cfg.Get(args[0])
+		if !ok {
+			return findings("'%s' is not set", args[0])
+		}
+		return writeSetting(cmd, output.NewRecord(output.KIND_SETTING, "").With("key", args[0]).With("value", value), formatValue(value))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a setting in the vault or the global configuration",
+	Long: `Set a setting in the .odm/config file of the vault, or with --global in the
+global file. The value is parsed as YAML, e.g. '[a, b]' is a list. Nested keys
+are separated with dots, e.g. 'profiles.work.vault' or 'lint.required'.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			r := output.NewRecord(output.KIND_SETTING, path).With("key", args[0]).With("value", args[1])
+			return writeSetting(cmd, r, fmt.Sprintf("%s: %s = %s", path, args[0], args[1]))
+		}
+		return config.Set(path, args[0], args[1])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the vault or the global configuration",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return writeSetting(cmd, output.NewRecord(output.KIND_SETTING, path).With("key", args[0]), fmt.Sprintf("%s: %s", path, args[0]))
+		}
+		if ok, err := config.Unset(path, args[0]); err != nil {
+			return err
+		} else if !ok {
+			return findings("'%s' is not set in %s", args[0], path)
+		}
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the effective settings and where they come from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		if cfg.Profile != "" && !w.Structured() {
+			fmt.Fprintf(cmd.OutOrStdout(), "# profile: %s\n", cfg.Profile)
+		}
+		for _, e := range cfg.List() {
+			r := output.NewRecord(output.KIND_SETTING, "").
+				With("key", e.Key).
+				With("value", e.Value).
+				With("source", e.Source).
+				With("profile", cfg.Profile)
+			if err := w.Write(r, fmt.Sprintf("%s = %s  (%s)", e.Key, formatValue(e.Value), e.Source)); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd)
+
+	for _, c := range []*cobra.Command{configSetCmd, configUnsetCmd} {
+		c.Flags().BoolVarP(&configGlobal, "global", "g", false, "write to the global configuration file")
+		addDryRunFlag(c)
+	}
+}
+
+// Gets the file the set and unset commands write to.
+func configPath() (string, error) {
+	if !configGlobal {
+		return config.VaultFile(cfg.Settings.Vault), nil
+	}
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	return config.DefaultHomeFile()
+}
+
+// Writes the single record of a setting.
+func writeSetting(cmd *cobra.Command, r output.Record, text string) error {
+	w, err := newWriter(cmd)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(r, text); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Formats a value on a single line, lists and maps in the YAML flow style.
+func formatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if _, ok := value.([]any); ok {
+		var node yaml.Node
+		if yaml.Unmarshal(data, &node) == nil && len(node.Content) > 0 {
+			node.Content[0].Style = yaml.FlowStyle
+			if flow, err := yaml.Marshal(node.Content[0]); err == nil {
+				data = flow
+			}
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
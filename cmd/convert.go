@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	convertOptions links.ConvertOptions
+	convertPath    string
+)
+
+var linksConvertCmd = &cobra.Command{
+	Use:   "convert [note...]",
+	Short: "Rewrite the links between the wikilink and the markdown styles",
+	Long: `Rewrite the internal links of the notes, or of the whole vault, in a single
+style. The targets are written as the shortest unique path, relative to the note
+or relative to the vault root. The markdown links get the extension and their
+paths are URL encoded, with --slugs their heading anchors are written as slugs
+like '#my-heading'. Converting back turns the slugs into the headings again.
+
+With the shortest paths the links round-trip, a target is kept as it is written
+if it still finds the same file. The relative and the absolute paths are one
+way, converting back keeps the longer paths.
+
+The style and the path default to link_style and link_path of the
+configuration. With --stdin a single note is read from stdin and written to
+stdout, --note-path tells where it is in the vault.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("to") {
+			convertOptions.Style = cfg.Settings.LinkStyle
+		}
+		if !cmd.Flags().Changed("path") {
+			convertOptions.Path = cfg.Settings.LinkPath
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		c, err := links.NewConverter(v, g, r, convertOptions)
+		if err != nil {
+			return err
+		}
+
+		if filterMode {
+			return runFilter(cmd, args, func(s api.Set) error {
+				_, err := c.Convert(s, metadata.Parse(convertPath, *s.Data()))
+				return err
+			})
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range sets {
+			data := *s.Data()
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+
+			changes, err := c.Convert(s, md)
+			if err != nil {
+				return err
+			}
+
+			lines := metadata.NewLineIndex(data)
+			for _, ch := range changes {
+				r := output.NewMatchRecord(output.KIND_LINK, ch.Path, data, lines, ch.Match).With("after", ch.After)
+				if err := w.Write(r, fmt.Sprintf("%s:%d: %s -> %s", ch.Path, ch.Line, ch.Before, ch.After)); err != nil {
+					return err
+				}
+			}
+
+			if len(changes) > 0 {
+				if err := saveNote(v, s); err != nil {
+					return err
+				}
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	linksCmd.AddCommand(linksConvertCmd)
+	addDryRunFlag(linksConvertCmd)
+	addFilterFlag(linksConvertCmd)
+
+	linksConvertCmd.Flags().StringVar(&convertOptions.Style, "to", links.STYLE_WIKILINK, "link style, wikilink or markdown")
+	linksConvertCmd.Flags().StringVar(&convertOptions.Path, "path", links.PATH_SHORTEST, "path style, shortest, relative or absolute")
+	linksConvertCmd.Flags().BoolVar(&convertOptions.Slugs, "slugs", false, "write the heading anchors of the markdown links as slugs")
+	linksConvertCmd.Flags().StringVar(&convertPath, "note-path", "", "path of the note read with --stdin, relative to the vault")
+}
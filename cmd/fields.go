@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/fields"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var fieldsKeys []string
+
+var fieldsCmd = &cobra.Command{
+	Use:   "fields",
+	Short: "Read and edit the inline fields of the notes",
+	Long: `Read and edit the Dataview inline fields of the notes, written in any of the
+three ways:
+
+  status:: active          a line of its own
+  [due:: 2024-05-01]       anywhere in the text
+  (rating:: 4)             anywhere in the text, the key is hidden
+
+The keys are compared like Dataview does, in lower case and with dashes for the
+spaces, so 'Due Date' is also 'due-date'. The changes are written like the ones
+of 'odm frontmatter', all of the notes together, or shown as a diff with
+--dry-run.`,
+}
+
+var fieldsListCmd = &cobra.Command{
+	Use:   "list [note...]",
+	Short: "List the inline fields of the notes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+		if sets, err = whereNotes(v, sets, frontmatterWhere); err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, s := range sets {
+			path := v.RelPath(s)
+			data := *s.Data()
+			lines := metadata.NewLineIndex(data)
+			for _, f := range metadata.ParseInlineFields(data) {
+				if len(fieldsKeys) > 0 && !hasFieldKey(fieldsKeys, f.Key) {
+					continue
+				}
+				r := output.NewMatchRecord(output.KIND_FIELD, path, data, lines, f.Match).
+					With("key", f.Key).
+					With("value", f.Value).
+					With("syntax", fieldSyntax(f.Syntax))
+				if err := w.Write(r, fmt.Sprintf("%s:%d: %s:: %s", path, f.Line, f.Key, f.Value)); err != nil {
+					return err
+				}
+			}
+		}
+		return w.Close()
+	},
+}
+
+var fieldsSetCmd = &cobra.Command{
+	Use:   "set <key> <value> [note...]",
+	Short: "Set the value of an inline field",
+	Long: `Set the value of every inline field with the key, only the values are
+rewritten. The notes without the field get a 'key:: value' line at their end.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		op := fields.Operation{Op: fields.OP_SET, Keys: []string{args[0]}, Value: args[1]}
+		return runOperation(cmd, args[2:], op, op.Op, args[0])
+	},
+}
+
+var fieldsUnsetCmd = &cobra.Command{
+	Use:   "unset <key> [note...]",
+	Short: "Remove an inline field",
+	Long: `Remove every inline field with the key. The 'key:: value' lines are removed,
+the other fields are cut out of their lines.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		op := fields.Operation{Op: fields.OP_UNSET, Keys: []string{args[0]}}
+		return runOperation(cmd, args[1:], op, op.Op, args[0])
+	},
+}
+
+var fieldsToFrontmatterCmd = &cobra.Command{
+	Use:   "to-frontmatter [note...]",
+	Short: "Move the inline fields into the frontmatter",
+	Long: `Move the inline fields given with --key, or all of them, into the frontmatter.
+The fields given more than once become lists. The fields of the tasks stay with
+their tasks. It is an error if the frontmatter already has the property.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		op := fields.Operation{Op: fields.OP_TO_FRONTMATTER, Keys: fieldsKeys}
+		return runOperation(cmd, args, op, op.Op, strings.Join(fieldsKeys, ","))
+	},
+}
+
+var fieldsToInlineCmd = &cobra.Command{
+	Use:   "to-inline [note...]",
+	Short: "Move frontmatter properties into inline fields",
+	Long: `Move the frontmatter properties given with --key into 'key:: value' lines after
+the frontmatter. The lists are written with commas between their items, and the
+frontmatter is removed if no properties are left in it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(fieldsKeys) == 0 {
+			return fmt.Errorf("the properties to move are given with --key")
+		}
+		op := fields.Operation{Op: fields.OP_TO_INLINE, Keys: fieldsKeys}
+		return runOperation(cmd, args, op, op.Op, strings.Join(fieldsKeys, ","))
+	},
+}
+
+// Gets the name of the syntax of the field.
+func fieldSyntax(syntax int) string {
+	switch syntax {
+	case metadata.FieldBracket:
+		return "bracket"
+	case metadata.FieldParen:
+		return "paren"
+	}
+	return "line"
+}
+
+func hasFieldKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if metadata.CanonicalKey(k) == metadata.CanonicalKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(fieldsCmd)
+	fieldsCmd.AddCommand(fieldsListCmd)
+	fieldsListCmd.Flags().StringSliceVarP(&fieldsKeys, "key", "k", []string{}, "only list the fields with the keys")
+	fieldsListCmd.Flags().StringVar(&frontmatterWhere, "where", "", "only list the fields of the notes matching the query")
+
+	for _, c := range []*cobra.Command{fieldsSetCmd, fieldsUnsetCmd, fieldsToFrontmatterCmd, fieldsToInlineCmd} {
+		fieldsCmd.AddCommand(c)
+		addDryRunFlag(c)
+		addFilterFlag(c)
+		c.Flags().StringVar(&frontmatterWhere, "where", "", "only change the notes matching the query")
+		c.Flags().BoolVar(&frontmatterDiff, "diff", false, "show the diff of the changes while writing them")
+	}
+	fieldsToFrontmatterCmd.Flags().StringSliceVarP(&fieldsKeys, "key", "k", []string{}, "keys of the fields to move, all of them by default")
+	fieldsToInlineCmd.Flags().StringSliceVarP(&fieldsKeys, "key", "k", []string{}, "keys of the properties to move")
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// Whether the transform command reads a note from stdin and writes it to stdout.
+var filterMode bool
+
+// A transformation of a single note.
+type transformFunc func(s api.Set) error
+
+// Adds the --stdin flag to a transform command.
+func addFilterFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&filterMode, "stdin", false, "read a note from stdin and write the result to stdout, the vault is not touched")
+}
+
+// Runs the transformation as a filter, reading the note from stdin and writing the
+// result to stdout. The note is written even if nothing has changed, so editors
+// can pipe a buffer through it.
+func runFilter(cmd *cobra.Command, args []string, transform transformFunc) error {
+	if len(args) > 0 {
+		return fmt.Errorf("notes can't be given with --stdin")
+	}
+
+	s, err := odm.NewSetFromReader(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	if err := transform(s); err != nil {
+		return err
+	}
+
+	_, err = cmd.OutOrStdout().Write(*s.Data())
+	return err
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/format"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	fmtCheck   bool
+	fmtDisable []string
+	fmtIndent  string
+	fmtOptions = format.NewDefaultOptions()
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [note...]",
+	Short: "Format the notes in place",
+	Long: `Normalize the markdown of the notes: heading spacing, list markers and
+indentation, emphasis markers, blank lines around blocks, table alignment,
+trailing whitespace and the final new line. Code blocks, comments, wikilinks,
+callouts and block identifiers are kept exactly as they are, so are the two
+trailing spaces of the hard line breaks.
+
+With --check nothing is written, the unformatted notes are listed and the
+command fails if there are any. With --stdin a single note is read from stdin
+and the formatted note is written to stdout. The rules are: ` + strings.Join(format.RuleNames, ", ") + `.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, rule := range fmtDisable {
+			fmtOptions.Disabled[rule] = true
+		}
+		switch fmtIndent {
+		case "tab":
+			fmtOptions.ListIndent = "\t"
+		case "2", "4":
+			fmtOptions.ListIndent = strings.Repeat(" ", int(fmtIndent[0]-'0'))
+		default:
+			return fmt.Errorf("unknown indentation '%s', use tab, 2 or 4", fmtIndent)
+		}
+		if err := fmtOptions.Check(); err != nil {
+			return err
+		}
+
+		if filterMode && fmtCheck {
+			s, err := odm.NewSetFromReader(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if !format.IsFormatted(s, fmtOptions) {
+				return findings("the note is not formatted")
+			}
+			return nil
+		}
+		if filterMode {
+			return runFilter(cmd, args, func(s api.Set) error {
+				_, err := format.Format(s, fmtOptions)
+				return err
+			})
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		unformatted := 0
+		for _, s := range sets {
+			path := v.RelPath(s)
+			if fmtCheck {
+				if !format.IsFormatted(s, fmtOptions) {
+					if err := w.Write(output.NewRecord(output.KIND_NOTE, path).With("formatted", false), path); err != nil {
+						return err
+					}
+					unformatted += 1
+				}
+				continue
+			}
+
+			if ok, err := format.Format(s, fmtOptions); err != nil {
+				return err
+			} else if ok {
+				if err := saveNote(v, s); err != nil {
+					return err
+				}
+				if err := w.Write(output.NewRecord(output.KIND_NOTE, path).With("formatted", true), path); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if unformatted > 0 {
+			return findings("%d notes are not formatted", unformatted)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	addDryRunFlag(fmtCmd)
+	addFilterFlag(fmtCmd)
+
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "only list the notes which are not formatted")
+	fmtCmd.Flags().StringSliceVar(&fmtDisable, "disable", []string{}, "rules to disable")
+	fmtCmd.Flags().StringVar(&fmtOptions.BulletMarker, "bullet", fmtOptions.BulletMarker, "bullet list marker, one of -, * or +")
+	fmtCmd.Flags().StringVar(&fmtIndent, "indent", "tab", "list indentation, tab, 2 or 4")
+	fmtCmd.Flags().StringVar(&fmtOptions.EmphasisMarker, "emphasis", fmtOptions.EmphasisMarker, "emphasis marker, * or _")
+	fmtCmd.Flags().StringVar(&fmtOptions.StrongMarker, "strong", fmtOptions.StrongMarker, "strong emphasis marker, ** or __")
+}
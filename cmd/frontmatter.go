@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/selector"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	frontmatterWhere      string
+	frontmatterDiff       bool
+	frontmatterDateFormat string
+)
+
+var frontmatterCmd = &cobra.Command{
+	Use:   "frontmatter",
+	Short: "Edit the frontmatter properties of the notes",
+	Long: `Edit a frontmatter property of the given notes, or of every note of the vault.
+The notes can also be selected with a query given with --where, e.g.
+'tag:#book has:created_at', see 'odm apply --help'.
+
+The notes are only written if the change works on all of them, and then they
+are written together. With --dry-run the changes are shown as a diff instead.`,
+}
+
+var frontmatterSetCmd = &cobra.Command{
+	Use:   "set <key> <value> [note...]",
+	Short: "Set a property in the frontmatter",
+	Long: `Set a property in the frontmatter of the notes, or of every note of the vault.
+The value is parsed as YAML, e.g. '[a, b]' is a list and an empty value leaves
+the property empty. An existing property is replaced where it is, a new one is
+appended to the frontmatter, which is created if the note has none.
+
+With --stdin a single note is read from stdin and the result is written to
+stdout.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := frontmatter.ParseValue(args[1])
+		if err != nil {
+			return err
+		}
+		op := frontmatter.Operation{Op: frontmatter.OP_SET, Key: args[0], Values: []*yaml.Node{value}}
+		return runOperation(cmd, args[2:], op, op.Op, op.Key)
+	},
+}
+
+var frontmatterUnsetCmd = &cobra.Command{
+	Use:   "unset <key> [note...]",
+	Short: "Remove a property from the frontmatter",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		op := frontmatter.Operation{Op: frontmatter.OP_UNSET, Key: args[0]}
+		return runOperation(cmd, args[1:], op, op.Op, op.Key)
+	},
+}
+
+var frontmatterRenameCmd = &cobra.Command{
+	Use:   "rename <key> <new-key> [note...]",
+	Short: "Rename a property, keeping its value",
+	Long: `Rename a property, e.g. created_at to created. The value and the place of the
+property are kept. It is an error if a note already has the new key.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		op := frontmatter.Operation{Op: frontmatter.OP_RENAME, Key: args[0], To: args[1]}
+		return runOperation(cmd, args[2:], op, op.Op, op.Key)
+	},
+}
+
+var frontmatterAppendCmd = &cobra.Command{
+	Use:   "append <key> <value> [note...]",
+	Short: "Append values to a list property",
+	Long: `Append the value to a list property, the values of '[a, b]' one by one. The
+values the list already has are skipped. A scalar like 'tags: a, b' becomes a
+list of its comma separated values, a missing property is created.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := parseItems(args[1])
+		if err != nil {
+			return err
+		}
+		op := frontmatter.Operation{Op: frontmatter.OP_APPEND, Key: args[0], Values: values}
+		return runOperation(cmd, args[2:], op, op.Op, op.Key)
+	},
+}
+
+var frontmatterRemoveCmd = &cobra.Command{
+	Use:   "remove <key> <value> [note...]",
+	Short: "Remove values from a list property",
+	Long: `Remove the value from a list property, the values of '[a, b]' one by one. A
+list without values is left empty, so is a scalar equal to the value.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := parseItems(args[1])
+		if err != nil {
+			return err
+		}
+		op := frontmatter.Operation{Op: frontmatter.OP_REMOVE, Key: args[0], Values: values}
+		return runOperation(cmd, args[2:], op, op.Op, op.Key)
+	},
+}
+
+var frontmatterConvertCmd = &cobra.Command{
+	Use:   "convert <key> <type> [note...]",
+	Short: "Change the type of a property",
+	Long: `Change the type of a property to ` + strings.Join(frontmatter.Types, ", ") + `.
+A scalar like 'tags: a, b' becomes a list of its comma separated values, a list
+of one item becomes the item and the dates are written in --date-format. It is
+an error if a value can't be converted.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := frontmatterDateFormat
+		if format == "" {
+			format = cfg.Settings.DateFormat
+		}
+		op := frontmatter.Operation{Op: frontmatter.OP_CONVERT, Key: args[0], To: args[1], Format: format}
+		return runOperation(cmd, args[2:], op, op.Op, op.Key)
+	},
+}
+
+// Parses the value as YAML, the items of a list are the values.
+func parseItems(text string) ([]*yaml.Node, error) {
+	n, err := frontmatter.ParseValue(text)
+	if err != nil {
+		return nil, err
+	}
+	if n.Kind == yaml.SequenceNode {
+		return n.Content, nil
+	}
+	return []*yaml.Node{n}, nil
+}
+
+// Runs the operation on the given notes, or on the ones selected with --where, and
+// writes them together. The name and the key of the operation are in the records.
+func runOperation(cmd *cobra.Command, args []string, op frontmatter.Editor, name, key string) error {
+	if filterMode {
+		return runFilter(cmd, args, func(s api.Set) error {
+			_, err := op.Apply(s)
+			return err
+		})
+	}
+
+	v, g, err := loadVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	sets, err := selectNotes(v, g, args)
+	if err != nil {
+		return err
+	}
+	if sets, err = whereNotes(v, sets, frontmatterWhere); err != nil {
+		return err
+	}
+
+	plan, err := frontmatter.Run(v, sets, op)
+	if err != nil {
+		return err
+	}
+
+	w, err := newWriter(cmd)
+	if err != nil {
+		return err
+	}
+
+	// The diff is only shown as text, the records are the notes.
+	if (dryRun || frontmatterDiff) && !w.Structured() {
+		d, err := plan.Diff()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), d)
+	} else {
+		for _, s := range plan.Changed() {
+			path := v.RelPath(s)
+			r := output.NewRecord(output.KIND_NOTE, path).With("op", name).With("key", key)
+			if err := w.Write(r, path); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+	return plan.Commit()
+}
+
+// Keeps the notes matching the selector query, all of them if it is empty.
+func whereNotes(v *vault.Vault, sets []api.Set, query string) ([]api.Set, error) {
+	if query == "" {
+		return sets, nil
+	}
+	sel, err := selector.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]api.Set, 0, len(sets))
+	for _, s := range sets {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		if sel.Match(md, *s.Data()) {
+			kept = append(kept, s)
+		}
+	}
+	return kept, nil
+}
+
+func init() {
+	rootCmd.AddCommand(frontmatterCmd)
+	for _, c := range []*cobra.Command{frontmatterSetCmd, frontmatterUnsetCmd, frontmatterRenameCmd, frontmatterAppendCmd, frontmatterRemoveCmd, frontmatterConvertCmd} {
+		frontmatterCmd.AddCommand(c)
+		addDryRunFlag(c)
+		addFilterFlag(c)
+		c.Flags().StringVar(&frontmatterWhere, "where", "", "only change the notes matching the query")
+		c.Flags().BoolVar(&frontmatterDiff, "diff", false, "show the diff of the changes while writing them")
+	}
+	frontmatterConvertCmd.Flags().StringVar(&frontmatterDateFormat, "date-format", "", "moment.js format of the dates, the date_format setting by default")
+}
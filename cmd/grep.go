@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	grepIgnoreCase bool
+	grepFixed      bool
+	grepFilesOnly  bool
+	grepCount      bool
+)
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <pattern> [note...]",
+	Short: "Find the lines matching a regular expression",
+	Long: `Find the matches of the regular expression in the notes, or in every note of
+the vault. The pattern uses the Go regular expression syntax. Exits with 1 if
+nothing matches.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		regex, err := compilePattern(args[0], grepFixed, grepIgnoreCase)
+		if err != nil {
+			return err
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args[1:])
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, s := range sets {
+			matches, err := s.Match(regex)
+			if err != nil {
+				return err
+			}
+			if len(*matches) == 0 {
+				continue
+			}
+			total += len(*matches)
+
+			path := v.RelPath(s)
+			if grepFilesOnly || grepCount {
+				text := path
+				if grepCount {
+					text = fmt.Sprintf("%s:%d", path, len(*matches))
+				}
+				if err := w.Write(output.NewRecord(output.KIND_NOTE, path).With("count", len(*matches)), text); err != nil {
+					return err
+				}
+				continue
+			}
+
+			data := *s.Data()
+			lines := metadata.NewLineIndex(data)
+			for _, m := range *matches {
+				line, col := lines.Position(m.Begin)
+				text := fmt.Sprintf("%s:%d:%d:%s", path, line, col, lineAt(data, lines, line))
+				if err := w.Write(output.NewMatchRecord(output.KIND_MATCH, path, data, lines, m), text); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if total == 0 {
+			return findings("")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(grepCmd)
+
+	grepCmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "match case insensitively")
+	grepCmd.Flags().BoolVarP(&grepFixed, "fixed-strings", "F", false, "treat the pattern as a literal string")
+	grepCmd.Flags().BoolVarP(&grepFilesOnly, "files-with-matches", "l", false, "only print the paths of the notes")
+	grepCmd.Flags().BoolVarP(&grepCount, "count", "c", false, "only print the number of matches per note")
+}
+
+// Compiles the pattern given on the command line.
+func compilePattern(pattern string, fixed, ignoreCase bool) (*regexp.Regexp, error) {
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Gets the given 1 based line without the new line.
+func lineAt(data []byte, lines metadata.LineIndex, line int) string {
+	begin := lines.LineStart(line)
+	end := len(data)
+	if i := bytes.IndexByte(data[begin:], '\n'); i >= 0 {
+		end = begin + i
+	}
+	return string(bytes.TrimRight(data[begin:end], "\r"))
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	linksBacklinks bool
+	linksBroken    bool
+)
+
+var linksCmd = &cobra.Command{
+	Use:   "links [note...]",
+	Short: "List the links of the notes",
+	Long: `List the outgoing links of the notes, or of the whole vault, with the paths
+they resolve to. With --backlinks the links pointing to the notes are listed
+instead. With --broken only the links which don't resolve are listed, and the
+command exits with 1 if there are any.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		if linksBacklinks {
+			wanted := make(map[string]bool)
+			for _, s := range sets {
+				wanted[v.RelPath(s)] = true
+			}
+
+			all := g.Sets()
+			sortSets(v, all)
+			for _, s := range all {
+				md, err := v.Metadata(s)
+				if err != nil {
+					return err
+				}
+				for _, l := range md.Links {
+					if target, ok := r.Resolve(md.Path, l.Target); ok && !l.External && wanted[target] && target != md.Path {
+						text := fmt.Sprintf("%s <- %s:%d", target, md.Path, l.Line)
+						if err := w.Write(linkRecord(s, md, l, target), text); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return w.Close()
+		}
+
+		broken := 0
+		for _, s := range sets {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+			for _, l := range md.Links {
+				text := string((*s.Data())[l.Match.Begin:l.Match.End])
+				if l.External {
+					if !linksBroken {
+						if err := w.Write(linkRecord(s, md, l, ""), fmt.Sprintf("%s:%d: %s -> %s", md.Path, l.Line, text, l.Target)); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				target, ok := r.Resolve(md.Path, l.Target)
+				if !ok {
+					broken += 1
+					if err := w.Write(linkRecord(s, md, l, ""), fmt.Sprintf("%s:%d: %s -> (missing)", md.Path, l.Line, text)); err != nil {
+						return err
+					}
+				} else if !linksBroken {
+					if err := w.Write(linkRecord(s, md, l, target), fmt.Sprintf("%s:%d: %s -> %s", md.Path, l.Line, text, target)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if linksBroken && broken > 0 {
+			return findings("%d broken links", broken)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(linksCmd)
+
+	linksCmd.Flags().BoolVarP(&linksBacklinks, "backlinks", "b", false, "list the links pointing to the notes")
+	linksCmd.Flags().BoolVar(&linksBroken, "broken", false, "only list the broken links")
+}
+
+// Creates the record of a link, the resolved path is empty if the link is
+// external or broken.
+func linkRecord(s api.Set, md *metadata.Metadata, l metadata.Link, resolved string) output.Record {
+	data := *s.Data()
+	return output.NewMatchRecord(output.KIND_LINK, md.Path, data, metadata.NewLineIndex(data), l.Match).
+		With("target", l.Target).
+		With("anchor", l.Anchor).
+		With("external", l.External).
+		With("embed", l.Embed).
+		With("resolved", resolved)
+}
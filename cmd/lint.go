@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/lint"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	lintFix   bool
+	lintRules []string
+	lintList  bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the vault for common problems",
+	Long: `Check every note of the vault with the lint rules. The rules are enabled or
+disabled per folder in the lint section of the configuration, e.g.
+
+  lint:
+    rules:
+      orphan-note: false
+    folders:
+      People:
+        required: [birthday]
+
+The older .odm/lint.json file with the same layout is read if the configuration
+has no lint section.
+
+With --fix the fixable problems are fixed in place, the fixed notes are written
+together. With --dry-run the fixes are shown as a diff instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lintList {
+			for _, r := range lint.Rules() {
+				fmt.Fprintf(cmd.OutOrStdout(), "%-22s %s\n", r.Name(), r.Description())
+			}
+			return nil
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		// The lint section of the configuration wins over .odm/lint.json.
+		config := cfg.Settings.Lint
+		if config == nil {
+			if config, err = lint.LoadConfig(v); err != nil {
+				return err
+			}
+		}
+
+		if len(lintRules) > 0 {
+			// Only run the given rules.
+			if err := lint.CheckRuleNames(lintRules); err != nil {
+				return err
+			}
+			config.Rules = make(map[string]bool)
+			for _, r := range lint.Rules() {
+				config.Rules[r.Name()] = false
+			}
+			for _, name := range lintRules {
+				config.Rules[name] = true
+			}
+			for folder, s := range config.Folders {
+				s.Rules = nil
+				config.Folders[folder] = s
+			}
+		}
+
+		ctx, err := lint.NewContext(v, g, config)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		sets := g.Sets()
+		sortSets(v, sets)
+
+		problems := 0
+		fixed := make([]api.Set, 0)
+		for _, s := range sets {
+			diagnostics, err := lint.RunSet(ctx, s)
+			if err != nil {
+				return err
+			}
+
+			if lintFix {
+				// Fixes are applied in rounds, since the overlapping ones are skipped.
+				for round := 0; round < 8; round++ {
+					n, err := lint.ApplyFixes(s, diagnostics)
+					if err != nil {
+						return err
+					}
+					if n == 0 {
+						break
+					}
+					if round == 0 {
+						fixed = append(fixed, s)
+					}
+					if diagnostics, err = lint.RunSet(ctx, s); err != nil {
+						return err
+					}
+				}
+			}
+
+			data := *s.Data()
+			lines := metadata.NewLineIndex(data)
+			for _, d := range diagnostics {
+				r := output.NewMatchRecord(output.KIND_DIAGNOSTIC, d.Path, data, lines, d.Match).
+					With("rule", d.Rule).
+					With("severity", d.SeverityName()).
+					With("message", d.Message).
+					With("fixable", d.Fix != nil)
+				if err := w.Write(r, d.String()); err != nil {
+					return err
+				}
+			}
+			problems += len(diagnostics)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if err := writeNotes(cmd, w, v, fixed); err != nil {
+			return err
+		}
+
+		if problems > 0 {
+			return findings("%d problems found", problems)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	addDryRunFlag(lintCmd)
+
+	lintCmd.Flags().BoolVar(&lintFix, "fix", false, "fix the problems which can be fixed")
+	lintCmd.Flags().StringSliceVar(&lintRules, "rule", []string{}, "only run the given rules")
+	lintCmd.Flags().BoolVar(&lintList, "list-rules", false, "list the available rules")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	lsTag  string
+	lsLong bool
+	lsAll  bool
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [folder]",
+	Short: "List the notes of the vault",
+	Long: `List the notes under the given folder of the vault, or the whole vault. With
+--all the attachments are listed as well.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		folder := v.Root()
+		if len(args) > 0 {
+			if folder, err = v.Root().Folder(args[0]); err != nil {
+				return err
+			}
+			if ok, err := folder.Exists(); err != nil {
+				return err
+			} else if !ok {
+				return fmt.Errorf("folder '%s' doesn't exist", args[0])
+			}
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		err = folder.Walk(func(f *file.File) error {
+			rel, err := v.Root().Rel(f)
+			if err != nil {
+				return err
+			}
+
+			if v.Ignored(rel) {
+				return nil
+			}
+
+			note := vault.IsNote(f)
+			if !note && !lsAll {
+				return nil
+			}
+
+			tags := []string{}
+			if note && (lsTag != "" || lsLong || w.Structured()) {
+				s, err := v.LoadSet(f)
+				if err != nil {
+					return err
+				}
+				md, err := v.Metadata(s)
+				if err != nil {
+					return err
+				}
+				if lsTag != "" && !md.HasTag(lsTag) {
+					return nil
+				}
+				tags = md.AllTags()
+			} else if lsTag != "" {
+				return nil
+			}
+
+			r := output.NewRecord(output.KIND_NOTE, rel).With("note", note)
+			if !lsLong && !w.Structured() {
+				return w.Write(r, rel)
+			}
+
+			size, err := f.Size()
+			if err != nil {
+				return err
+			}
+			modTime, err := f.ModTime()
+			if err != nil {
+				return err
+			}
+			text := fmt.Sprintf("%8d  %s  %s", size, modTime.Format("2006-01-02 15:04"), rel)
+			if len(tags) > 0 {
+				text += fmt.Sprintf("  #%s", strings.Join(tags, " #"))
+			}
+			r = r.With("size", size).With("mod_time", modTime).With("tags", tags)
+			return w.Write(r, text)
+		})
+		if err != nil {
+			return err
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+
+	lsCmd.Flags().StringVarP(&lsTag, "tag", "t", "", "only list the notes with the tag")
+	lsCmd.Flags().BoolVarP(&lsLong, "long", "l", false, "show the size, modification time and tags")
+	lsCmd.Flags().BoolVarP(&lsAll, "all", "a", false, "list the attachments as well")
+}
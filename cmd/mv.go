@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <source> <destination>",
+	Short: "Move or rename a note and update the links to it",
+	Long: `Move or rename a note or an attachment. Every link to it is rewritten to point
+to the new path, and the relative links in the moved note are fixed. If the
+destination is a folder, or ends with a '/', the file is moved into it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		source, ok := r.Resolve("", args[0])
+		if !ok {
+			return fmt.Errorf("'%s' not found", args[0])
+		}
+
+		dest, err := destinationPath(v, source, args[1])
+		if err != nil {
+			return err
+		}
+		if dest == source {
+			return nil
+		}
+		if r.Exists(dest) {
+			return fmt.Errorf("'%s' already exists", dest)
+		}
+
+		changed, changes, err := links.Retarget(v, g, r, source, dest)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			rec := output.NewRecord(output.KIND_LINK, c.Path).With("before", c.Before).With("after", c.After)
+			rec.Line = c.Line
+			if err := w.Write(rec, fmt.Sprintf("%s:%d: %s -> %s", c.Path, c.Line, c.Before, c.After)); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(output.NewRecord(output.KIND_FILE, source).With("to", dest), fmt.Sprintf("%s -> %s", source, dest)); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		// The links and the move are applied together, the moved note is written to
+		// its old path first.
+		tx := v.Begin()
+		for _, s := range changed {
+			tx.Write(s)
+		}
+		tx.Rename(source, dest)
+		return tx.Commit()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+	addDryRunFlag(mvCmd)
+}
+
+// Gets the path the source moves to. The destination can be a folder, then the name is
+// kept. Notes keep their extension if the destination doesn't have one.
+func destinationPath(v *vault.Vault, source, dest string) (string, error) {
+	dest = strings.ReplaceAll(dest, "\\", "/")
+	isFolder := strings.HasSuffix(dest, "/")
+
+	dest = strings.TrimPrefix(path.Clean("/"+dest), "/")
+	if dest == "" {
+		return path.Base(source), nil
+	}
+
+	if folder, err := v.Root().Folder(dest); err != nil {
+		return "", err
+	} else if ok, err := folder.Exists(); err != nil {
+		return "", err
+	} else if ok {
+		isFolder = true
+	}
+
+	if isFolder {
+		return path.Join(dest, path.Base(source)), nil
+	}
+	if path.Ext(dest) == "" && path.Ext(source) != "" {
+		dest += path.Ext(source)
+	}
+	return dest, nil
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/config"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/obsidian"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/templates"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	newTemplate   string
+	newFolder     string
+	newVars       []string
+	newProperties []string
+	newList       bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new <title>",
+	Short: "Create a note, optionally from a template",
+	Long: `Create a note with the title, from the template if one is given. The templates
+are the notes in the template_folder setting, or in the folder of the Obsidian
+templates plugin, 'Templates' by default. Their tags are:
+
+  {{title}}                      the title of the note
+  {{date}}, {{date:YYYY-MM-DD}}  the date of today, with the default or the given format
+  {{time}}, {{time:HH:mm}}       the time of now
+  {{name}}, {{name:default}}     a variable given with --var name=value
+  {{prompt:Question|default}}    asks for the value, unless --var Question=value
+  {{#if name}}..{{else}}..{{/if}} a conditional, also {{#if name == value}} and
+                                 {{#unless name}}
+  {{> other}}                    includes the other template
+  {{cursor}}                     where the editing continues
+
+The note goes to the folder given with --folder, or to the folder of the new
+notes in the Obsidian settings. --set sets a frontmatter property, the value is
+parsed as YAML. The path of the note is written with the line and the column of
+the cursor, e.g. to open it in an editor. With --dry-run the note is written to
+stdout instead.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if newList {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		e, err := newEngine(cmd, v)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		if newList {
+			names, err := e.Templates()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				rec := output.NewRecord(output.KIND_NOTE, path.Join(e.Folder(), name+vault.NOTE_EXTENSION)).With("template", name)
+				if err := w.Write(rec, name); err != nil {
+					return err
+				}
+			}
+			return w.Close()
+		}
+
+		title := strings.TrimSpace(args[0])
+		if title == "" || strings.ContainsAny(title, "/\\:") {
+			return fmt.Errorf("'%s' can't be the title of a note", args[0])
+		}
+
+		folder, err := newNoteFolder(v)
+		if err != nil {
+			return err
+		}
+		notePath := path.Join(folder, title+vault.NOTE_EXTENSION)
+
+		build := e.Create
+		if dryRun {
+			build = e.Build
+		}
+		s, cursor, err := build(newTemplate, notePath, title)
+		if err != nil {
+			return err
+		}
+
+		rec := output.NewRecord(output.KIND_NOTE, notePath).With("template", newTemplate)
+		text := notePath
+		if cursor >= 0 {
+			line, col := metadata.NewLineIndex(*s.Data()).Position(cursor)
+			rec.Line, rec.Col = line, col
+			text = fmt.Sprintf("%s:%d:%d", notePath, line, col)
+		}
+		if dryRun {
+			rec = rec.With("content", string(*s.Data()))
+			text = string(*s.Data())
+		}
+		if err := w.Write(rec, strings.TrimSuffix(text, "\n")); err != nil {
+			return err
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	addDryRunFlag(newCmd)
+
+	newCmd.Flags().StringVarP(&newTemplate, "template", "t", "", "name of the template")
+	newCmd.Flags().StringVarP(&newFolder, "folder", "f", "", "folder of the note relative to the vault")
+	newCmd.Flags().StringArrayVar(&newVars, "var", []string{}, "value of a template variable, name=value")
+	newCmd.Flags().StringArrayVar(&newProperties, "set", []string{}, "frontmatter property of the note, key=value")
+	newCmd.Flags().BoolVarP(&newList, "list", "l", false, "list the templates")
+}
+
+// Creates the template engine from the settings and the flags. The prompts are asked
+// on stderr.
+func newEngine(cmd *cobra.Command, v *vault.Vault) (*templates.Engine, error) {
+	plugin, err := obsidian.LoadTemplates(v)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := cfg.Settings.TemplateFolder
+	if folder == "" {
+		folder = plugin.Folder
+	}
+	if folder == "" {
+		folder = templates.DEFAULT_FOLDER
+	}
+
+	e := templates.NewEngine(v, folder)
+	e.DateFormat = cfg.Settings.DateFormat
+	if cfg.Source("date_format") == config.SOURCE_DEFAULT && plugin.DateFormat != "" {
+		e.DateFormat = plugin.DateFormat
+	}
+	e.TimeFormat = cfg.Settings.TimeFormat
+	if cfg.Source("time_format") == config.SOURCE_DEFAULT && plugin.TimeFormat != "" {
+		e.TimeFormat = plugin.TimeFormat
+	}
+	e.Now = time.Now()
+
+	for _, kv := range newVars {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a name=value", kv)
+		}
+		e.Vars[name] = value
+	}
+	for _, kv := range newProperties {
+		key, text, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a key=value", kv)
+		}
+		value, err := frontmatter.ParseValue(text)
+		if err != nil {
+			return nil, err
+		}
+		e.Properties = append(e.Properties, templates.Property{Key: key, Value: value})
+	}
+
+	e.Prompt = templatePrompt(cmd)
+	return e, nil
+}
+
+// Gets the prompt of the templates, it asks on stderr and takes the default for an
+// empty answer.
+func templatePrompt(cmd *cobra.Command) func(question, def string) (string, error) {
+	p := newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr())
+	return func(question, def string) (string, error) {
+		q := question + ": "
+		if def != "" {
+			q = fmt.Sprintf("%s [%s]: ", question, def)
+		}
+		if answer, ok := p.ask(q); ok && answer != "" {
+			return answer, nil
+		}
+		return def, nil
+	}
+}
+
+// Gets the folder of the new note, the one given with --folder or the one of the
+// Obsidian settings.
+func newNoteFolder(v *vault.Vault) (string, error) {
+	if newFolder != "" {
+		return strings.Trim(newFolder, "/"), nil
+	}
+	app, err := obsidian.LoadApp(v)
+	if err != nil {
+		return "", err
+	}
+	if app.NewFileLocation == obsidian.LOCATION_FOLDER {
+		return strings.Trim(app.NewFileFolderPath, "/"), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// Opens the vault and loads every note of it.
+func loadVault() (*vault.Vault, api.Group, error) {
+	v, err := openVault()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g, err := v.Load()
+	if err != nil {
+		v.Close()
+		return nil, nil, err
+	}
+	return v, g, nil
+}
+
+// Finds the note the argument refers to. It can be a path relative to the vault or a
+// link target like '[[Note]]' would have.
+func findNote(v *vault.Vault, g api.Group, r *vault.Resolver, arg string) (api.Set, error) {
+	path, ok := r.Resolve("", arg)
+	if !ok {
+		return nil, fmt.Errorf("note '%s' not found", arg)
+	}
+
+	for _, s := range g.Sets() {
+		if v.RelPath(s) == path {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a note", arg)
+}
+
+// Gets the notes the arguments refer to in the same order, or every note sorted by
+// their paths if there are no arguments.
+func selectNotes(v *vault.Vault, g api.Group, args []string) ([]api.Set, error) {
+	if len(args) == 0 {
+		sets := g.Sets()
+		sortSets(v, sets)
+		return sets, nil
+	}
+
+	r, err := v.Resolver()
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]api.Set, 0, len(args))
+	for _, arg := range args {
+		if s, err := findNote(v, g, r, arg); err == nil {
+			sets = append(sets, s)
+		} else {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// Sorts the sets by their paths, so the output is stable.
+func sortSets(v *vault.Vault, sets []api.Set) {
+	sort.Slice(sets, func(i, j int) bool {
+		return v.RelPath(sets[i]) < v.RelPath(sets[j])
+	})
+}
+
+// Writes the modified set down to the disk, unless it is a dry run.
+func saveNote(v *vault.Vault, s api.Set) error {
+	if dryRun {
+		return nil
+	}
+	_, err := v.Save(s)
+	return err
+}
+
+// Writes the modified sets down together, unless it is a dry run. If one of them
+// can't be written, none of them are.
+func commitNotes(v *vault.Vault, sets []api.Set) error {
+	if dryRun {
+		return nil
+	}
+	tx := v.Begin()
+	for _, s := range sets {
+		tx.Write(s)
+	}
+	return tx.Commit()
+}
+
+// Writes the modified sets down together like commitNotes. On a dry run the changes
+// are shown as a unified diff instead, after the records of the writer. The diff is
+// only shown as text, the structured formats have the records alone.
+func writeNotes(cmd *cobra.Command, w *output.Writer, v *vault.Vault, sets []api.Set) error {
+	if !dryRun {
+		return commitNotes(v, sets)
+	}
+	if w.Structured() {
+		return nil
+	}
+
+	tx := v.Begin()
+	for _, s := range sets {
+		tx.Write(s)
+	}
+	d, err := tx.Diff()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(cmd.OutOrStdout(), d)
+	return err
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/periodic"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/templates"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	periodicPrev bool
+	periodicNext bool
+	periodicFrom string
+	periodicTo   string
+)
+
+// The commands of the periods, with their names and the names of their notes.
+var periodicCmds = []struct {
+	period  string
+	use     string
+	aliases []string
+	name    string
+}{
+	{periodic.PERIOD_DAY, "today", []string{"day", "daily"}, "daily"},
+	{periodic.PERIOD_WEEK, "week", []string{"weekly"}, "weekly"},
+	{periodic.PERIOD_MONTH, "month", []string{"monthly"}, "monthly"},
+	{periodic.PERIOD_QUARTER, "quarter", []string{"quarterly"}, "quarterly"},
+	{periodic.PERIOD_YEAR, "year", []string{"yearly"}, "yearly"},
+}
+
+const periodicHelp = `The folder, the file name format and the template of the notes come from the
+Periodic Notes plugin, or from the core daily notes plugin for the daily notes.
+The template is rendered like the ones of 'odm new', {{date}} and {{title}} are
+the date of the note in its format. The daily notes have {{yesterday}} and
+{{tomorrow}}, the weekly ones {{monday}} to {{sunday}}, with optional formats
+like {{yesterday:YYYY-MM-DD}}. With --dry-run the path of the missing note is
+written with what would be in it.`
+
+func init() {
+	for _, pc := range periodicCmds {
+		period, name := pc.period, pc.name
+		c := &cobra.Command{
+			Use:     pc.use + " [date]",
+			Aliases: pc.aliases,
+			Short:   fmt.Sprintf("Open or create the %s note", name),
+			Long: fmt.Sprintf(`Get the path of the %s note of the date, today by default, and create it if
+it doesn't exist. The date is YYYY-MM-DD, in the date_format setting, in the
+format of the notes, or like today, yesterday, +1d, -2w. With --prev or --next
+the path of the closest existing note before or after it is written instead.
+
+`, name) + periodicHelp,
+			Args: cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				date := ""
+				if len(args) > 0 {
+					date = args[0]
+				}
+				return runPeriodic(cmd, period, name, date)
+			},
+		}
+		missing := &cobra.Command{
+			Use:   "missing",
+			Short: fmt.Sprintf("List the %s notes missing in a range", name),
+			Long: fmt.Sprintf(`List the paths of the %s notes which don't exist from --from to --to, both
+included. --to is today by default. Exits with 1 if there are any.`, name),
+			Args: cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runPeriodicRange(cmd, period, name, false)
+			},
+		}
+		backfill := &cobra.Command{
+			Use:   "backfill",
+			Short: fmt.Sprintf("Create the %s notes missing in a range", name),
+			Long: fmt.Sprintf(`Create the %s notes which don't exist from --from to --to, both included,
+from the template. --to is today by default. Either all of them are created or
+none.`, name),
+			Args: cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runPeriodicRange(cmd, period, name, true)
+			},
+		}
+
+		rootCmd.AddCommand(c)
+		c.AddCommand(missing, backfill)
+		addDryRunFlag(c)
+		addDryRunFlag(backfill)
+
+		c.Flags().BoolVar(&periodicPrev, "prev", false, "get the previous existing note")
+		c.Flags().BoolVar(&periodicNext, "next", false, "get the next existing note")
+		c.MarkFlagsMutuallyExclusive("prev", "next")
+		for _, sub := range []*cobra.Command{missing, backfill} {
+			sub.Flags().StringVar(&periodicFrom, "from", "", "first date of the range")
+			sub.Flags().StringVar(&periodicTo, "to", "", "last date of the range, today by default")
+			sub.MarkFlagRequired("from")
+		}
+	}
+}
+
+// Writes the path of the note of the period, creating it or finding the closest one.
+func runPeriodic(cmd *cobra.Command, period, name, arg string) error {
+	v, err := openVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	s, err := periodSettings(v, period)
+	if err != nil {
+		return err
+	}
+	date, err := parsePeriodDay(s, arg)
+	if err != nil {
+		return err
+	}
+
+	r, err := v.Resolver()
+	if err != nil {
+		return err
+	}
+	w, err := newWriter(cmd)
+	if err != nil {
+		return err
+	}
+
+	if periodicPrev || periodicNext {
+		existing := s.Existing(r.Paths(), time.Local)
+		start := s.Start(date)
+		found := -1
+		for i, t := range existing {
+			if periodicPrev && t.Before(start) {
+				found = i
+			}
+			if periodicNext && t.After(start) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			dir := "before"
+			if periodicNext {
+				dir = "after"
+			}
+			return fmt.Errorf("there is no %s note %s '%s'", name, dir, s.Path(date))
+		}
+		notePath := s.Path(existing[found])
+		if err := w.Write(periodRecord(s, existing[found], notePath, false), notePath); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+
+	notePath := s.Path(date)
+	if r.Exists(notePath) {
+		if err := w.Write(periodRecord(s, date, notePath, false), notePath); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+
+	e := periodEngine(cmd, v, s, date)
+	build := e.Create
+	if dryRun {
+		build = e.Build
+	}
+	n, _, err := build(s.Template, notePath, periodTitle(notePath))
+	if err != nil {
+		return err
+	}
+
+	rec, text := periodRecord(s, date, notePath, true), notePath
+	if dryRun {
+		rec = rec.With("content", string(*n.Data()))
+		// The path it would be created at, and then what would be in it.
+		text = notePath + "\n" + strings.TrimSuffix(string(*n.Data()), "\n")
+	}
+	if err := w.Write(rec, text); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Lists the notes of the period missing in the range, and creates them if asked.
+func runPeriodicRange(cmd *cobra.Command, period, name string, create bool) error {
+	v, err := openVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	s, err := periodSettings(v, period)
+	if err != nil {
+		return err
+	}
+	from, err := parsePeriodDay(s, periodicFrom)
+	if err != nil {
+		return err
+	}
+	to, err := parsePeriodDay(s, periodicTo)
+	if err != nil {
+		return err
+	}
+	if to.Before(from) {
+		return fmt.Errorf("the range ends before it starts")
+	}
+
+	r, err := v.Resolver()
+	if err != nil {
+		return err
+	}
+	w, err := newWriter(cmd)
+	if err != nil {
+		return err
+	}
+
+	created := make([]api.Set, 0)
+	missing := 0
+	for _, date := range s.Range(from, to) {
+		notePath := s.Path(date)
+		if r.Exists(notePath) {
+			continue
+		}
+		missing++
+		if create {
+			n, _, err := periodEngine(cmd, v, s, date).Build(s.Template, notePath, periodTitle(notePath))
+			if err != nil {
+				return err
+			}
+			created = append(created, n)
+		}
+		if err := w.Write(periodRecord(s, date, notePath, create), notePath); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if create {
+		return commitNotes(v, created)
+	}
+	if missing > 0 {
+		return findings("%d %s notes are missing", missing, name)
+	}
+	return nil
+}
+
+// Gets the settings of the period's notes.
+func periodSettings(v *vault.Vault, period string) (periodic.Settings, error) {
+	all, err := periodic.Load(v)
+	if err != nil {
+		return periodic.Settings{}, err
+	}
+	return all[period], nil
+}
+
+// Parses the date like parseDay, or in the format of the period's notes, e.g.
+// '2024-W05' for the weekly ones.
+func parsePeriodDay(s periodic.Settings, arg string) (time.Time, error) {
+	if d, err := parseDay(arg, time.Now()); err == nil {
+		return d, nil
+	}
+	if d, err := moment.Parse(s.Format, arg, time.Local); err == nil {
+		return tasks.Day(d), nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a date, use YYYY-MM-DD or %s", arg, s.Format)
+}
+
+// Creates the template engine of a note of the period. The templates are found
+// relative to the vault, like the plugins do.
+func periodEngine(cmd *cobra.Command, v *vault.Vault, s periodic.Settings, date time.Time) *templates.Engine {
+	start := s.Start(date)
+	now := time.Now()
+
+	e := templates.NewEngine(v, "")
+	e.DateFormat = s.Format
+	e.TimeFormat = cfg.Settings.TimeFormat
+	e.Now = time.Date(start.Year(), start.Month(), start.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local)
+	e.Prompt = templatePrompt(cmd)
+
+	switch s.Period {
+	case periodic.PERIOD_DAY:
+		e.Dates["yesterday"] = start.AddDate(0, 0, -1)
+		e.Dates["tomorrow"] = start.AddDate(0, 0, 1)
+	case periodic.PERIOD_WEEK:
+		for i := 0; i < 7; i++ {
+			day := start.AddDate(0, 0, i)
+			e.Dates[strings.ToLower(day.Weekday().String())] = day
+		}
+	}
+	return e
+}
+
+// Gets the title of a periodic note, the name of its file.
+func periodTitle(notePath string) string {
+	return strings.TrimSuffix(path.Base(notePath), vault.NOTE_EXTENSION)
+}
+
+// Creates the record of a periodic note.
+func periodRecord(s periodic.Settings, date time.Time, notePath string, created bool) output.Record {
+	return output.NewRecord(output.KIND_NOTE, notePath).
+		With("period", s.Period).
+		With("date", s.Start(date).Format(tasks.DATE_LAYOUT)).
+		With("created", created)
+}
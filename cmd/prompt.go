@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The number of lines shown before and after a match.
+const PROMPT_CONTEXT int = 2
+
+// The escape codes highlighting the old and the new text.
+const (
+	COLOR_OLD   string = "\033[1;31m"
+	COLOR_NEW   string = "\033[1;32m"
+	COLOR_RESET string = "\033[0m"
+)
+
+// Asks about every replacement on a plain terminal, an answer is a line.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// Whether the user has stopped, the current note is finished first.
+	quit bool
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// Creates the decider of a note. Once the rest of the note is accepted or the user
+// has quit, it doesn't ask anymore.
+func (p *prompter) decider(path string, data []byte) replaceDecider {
+	lines := metadata.NewLineIndex(data)
+	all := false
+
+	return func(m api.Match, text []byte) ([]byte, bool) {
+		if all {
+			return text, true
+		}
+		if p.quit {
+			return nil, false
+		}
+
+		p.show(path, data, lines, m, text)
+		for {
+			answer, ok := p.ask("Replace? [y]es, [n]o, [e]dit, [a]ll in this note, [q]uit: ")
+			if !ok {
+				p.quit = true
+				return nil, false
+			}
+
+			switch strings.ToLower(answer) {
+			case "y", "yes":
+				return text, true
+			case "n", "no":
+				return nil, false
+			case "e", "edit":
+				if edited, ok := p.ask("Replacement: "); ok {
+					return []byte(edited), true
+				}
+				p.quit = true
+				return nil, false
+			case "a", "all":
+				all = true
+				return text, true
+			case "q", "quit":
+				p.quit = true
+				return nil, false
+			}
+		}
+	}
+}
+
+// Shows the match with the lines around it, the match is shown replaced.
+func (p *prompter) show(path string, data []byte, lines metadata.LineIndex, m api.Match, text []byte) {
+	line, col := lines.Position(m.Begin)
+	endLine, _ := lines.Position(m.End)
+	fmt.Fprintf(p.out, "\n%s:%d:%d\n", path, line, col)
+
+	first := line - PROMPT_CONTEXT
+	if first < 1 {
+		first = 1
+	}
+	last := endLine + PROMPT_CONTEXT
+	if last > lines.Lines() {
+		last = lines.Lines()
+	}
+
+	for n := first; n <= last; n++ {
+		if n < line || n > endLine {
+			fmt.Fprintf(p.out, "  %4d | %s\n", n, lineAt(data, lines, n))
+			continue
+		}
+		if n > line {
+			// The rest of a multi line match is shown on the first line.
+			continue
+		}
+
+		// The changed lines, from the start of the first line of the match to the
+		// end of its last line.
+		begin := lines.LineStart(line)
+		end := lines.LineStart(endLine) + len(lineAt(data, lines, endLine))
+		changed := string(data[begin:m.Begin]) +
+			COLOR_OLD + string(data[m.Begin:m.End]) + COLOR_RESET +
+			COLOR_NEW + string(text) + COLOR_RESET +
+			string(data[m.End:end])
+		for i, l := range strings.Split(changed, "\n") {
+			if i == 0 {
+				fmt.Fprintf(p.out, "> %4d | %s\n", n, l)
+			} else {
+				fmt.Fprintf(p.out, "       | %s\n", l)
+			}
+		}
+	}
+}
+
+// Asks a question and reads a line, returns false at the end of the input.
+func (p *prompter) ask(question string) (string, bool) {
+	fmt.Fprint(p.out, question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(p.out)
+		return "", false
+	}
+	return strings.TrimRight(answer, "\r\n"), true
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/query"
+)
+
+var (
+	queryAs   string
+	queryThis string
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <query>",
+	Short: "Run a Dataview-style query over the notes",
+	Long: `Run a query in the language of the Dataview plugin over the frontmatter, the
+inline fields like 'status:: active' and the tasks of the notes:
+
+  TABLE status, due AS "Due" FROM #project WHERE status != "done" SORT due
+  LIST rating FROM "Books" WHERE rating >= 4 LIMIT 10
+  TASK FROM [[Sprint]] WHERE !completed AND due <= date(today) + dur(3 days)
+  TABLE length(rows) AS "Notes" GROUP BY status
+
+FROM takes #tags, "folders", [[links]] for the notes linking to a note and
+outgoing([[links]]) for the notes it links to, combined with and, or and '-'.
+WHERE, SORT, GROUP BY, FLATTEN and LIMIT run in the order they are written. The
+notes have the fields of their frontmatter and their inline fields, and 'file'
+with name, path, folder, link, size, ctime, mtime, day, tags, outlinks, inlinks,
+aliases and tasks. The tasks have text, status, completed, due, scheduled,
+done, priority and tags. 'this' is the note given with --this.
+
+The result is written as markdown, or as json or csv with --as.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := query.Parse(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+		this := ""
+		if queryThis != "" {
+			s, err := findNote(v, g, r, queryThis)
+			if err != nil {
+				return err
+			}
+			this = v.RelPath(s)
+		}
+
+		en, err := query.NewEngine(v, g)
+		if err != nil {
+			return err
+		}
+		res, err := en.Run(q, this)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		if w.Structured() {
+			for _, obj := range queryRecords(res) {
+				if err := w.Write(obj, ""); err != nil {
+					return err
+				}
+			}
+			return w.Close()
+		}
+
+		c, err := links.NewConverter(v, g, r, links.ConvertOptions{Style: cfg.Settings.LinkStyle, Path: cfg.Settings.LinkPath})
+		if err != nil {
+			return err
+		}
+		text, err := res.Render(queryAs, query.RenderOptions{
+			From:       this,
+			Link:       c.Link,
+			DateFormat: cfg.Settings.DateFormat,
+			TimeFormat: cfg.Settings.TimeFormat,
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Write(output.NewRecord(output.KIND_ROW, this), text); err != nil {
+			return err
+		}
+		return w.Close()
+	},
+}
+
+// Creates a record for each row of the result. The rows of the notes and the tasks
+// have their paths, the tasks also their lines.
+func queryRecords(res *query.Result) []output.Record {
+	records := make([]output.Record, 0, len(res.Rows))
+	for i, obj := range res.Objects() {
+		rec := output.NewRecord(output.KIND_ROW, "")
+		row := res.Rows[i]
+		if link, ok := row.ID.(query.Link); ok {
+			rec.Path = link.Path
+		}
+		if row.Task != nil {
+			rec.Kind = output.KIND_TASK
+			rec.Path = fmt.Sprint(row.Task["path"])
+			if line, ok := row.Task["line"].(float64); ok {
+				rec.Line = int(line)
+			}
+		}
+		for k, v := range obj {
+			rec = rec.With(k, v)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringVar(&queryAs, "as", query.FORMAT_MARKDOWN, "format of the result, markdown, json or csv")
+	queryCmd.Flags().StringVar(&queryThis, "this", "", "note the query is run from, 'this' in the query")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/query"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/regions"
+)
+
+var refreshCheck bool
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh [note...]",
+	Short: "Regenerate the managed regions of the notes",
+	Long: `Regenerate the managed regions of the notes, or of every note. A region is
+between the marker comments
+
+  <!-- odm:begin query="tag:#project status:active" -->
+  <!-- odm:end -->
+
+and holds the notes matching the query as plain markdown, which renders
+everywhere. The query selects the notes like the rules of 'odm apply' do, e.g.
+tag:#project, folder:Projects, status:active or -has:due. The begin marker can
+also have:
+
+  format="table"       a table instead of a list of links
+  fields="status,due"  the frontmatter properties in the columns of the table
+  sort="-due"          sort by path, title or a property, '-' reverses it
+  limit="10"           at most this many notes
+
+Instead of the query, dql='TABLE status FROM #project SORT due' runs a query
+like 'odm query' does and writes its result, from the note of the region.
+
+Only the regions whose contents changed are replaced, the other notes are not
+written. With --check nothing is written, the outdated regions are listed and the
+command fails if there are any.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+		c, err := links.NewConverter(v, g, r, links.ConvertOptions{Style: cfg.Settings.LinkStyle, Path: cfg.Settings.LinkPath})
+		if err != nil {
+			return err
+		}
+		rd := regions.NewRenderer(v, g, c.Link)
+		var en *query.Engine
+		rd.DQL = func(notePath, dql string) (string, error) {
+			q, err := query.Parse(dql)
+			if err != nil {
+				return "", err
+			}
+			if en == nil {
+				if en, err = query.NewEngine(v, g); err != nil {
+					return "", err
+				}
+			}
+			res, err := en.Run(q, notePath)
+			if err != nil {
+				return "", err
+			}
+			return res.Markdown(query.RenderOptions{
+				From:       notePath,
+				Link:       c.Link,
+				DateFormat: cfg.Settings.DateFormat,
+				TimeFormat: cfg.Settings.TimeFormat,
+			}), nil
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		changed := make([]api.Set, 0)
+		outdated := 0
+		for _, s := range sets {
+			path := v.RelPath(s)
+			data := *s.Data()
+			refreshed, err := regions.Refresh(s, rd.For(path))
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if len(refreshed) == 0 {
+				continue
+			}
+			changed = append(changed, s)
+			outdated += len(refreshed)
+
+			lines := metadata.NewLineIndex(data)
+			for _, region := range refreshed {
+				rec := output.NewMatchRecord(output.KIND_NOTE, path, data, lines, region.Begin).
+					With("query", region.Query()).
+					With("refreshed", !refreshCheck)
+				if err := w.Write(rec, fmt.Sprintf("%s:%d: %s", path, region.Line, region.Query())); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if refreshCheck {
+			if outdated > 0 {
+				return findings("%d regions are outdated", outdated)
+			}
+			return nil
+		}
+		return commitNotes(v, changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(refreshCmd)
+	addDryRunFlag(refreshCmd)
+
+	refreshCmd.Flags().BoolVar(&refreshCheck, "check", false, "only list the outdated regions")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/related"
+)
+
+var (
+	relatedCount   int
+	relatedInsert  bool
+	relatedHeading string
+)
+
+var relatedCmd = &cobra.Command{
+	Use:   "related <note>",
+	Short: "Recommend notes similar to the given one",
+	Long: `Recommend the notes most similar to the given one by comparing their TF-IDF
+vectors. The notes it already links to are left out. With --insert the
+recommendations are written into a section of the note, with --dry-run the
+section is shown as a diff.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		s, err := findNote(v, g, r, args[0])
+		if err != nil {
+			return err
+		}
+
+		model, err := related.NewModel(v, g, relatedHeading)
+		if err != nil {
+			return err
+		}
+
+		suggestions, err := model.Related(v.RelPath(s), relatedCount)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, sg := range suggestions {
+			r := output.NewRecord(output.KIND_NOTE, sg.Path).With("score", sg.Score)
+			if err := w.Write(r, fmt.Sprintf("%.3f\t%s", sg.Score, sg.Path)); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if !relatedInsert || len(suggestions) == 0 {
+			return nil
+		}
+
+		md, err := v.Metadata(s)
+		if err != nil {
+			return err
+		}
+		if ok, err := related.InsertSection(s, md, relatedHeading, suggestions, r); err != nil || !ok {
+			return err
+		}
+		return writeNotes(cmd, w, v, []api.Set{s})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relatedCmd)
+	addDryRunFlag(relatedCmd)
+
+	relatedCmd.Flags().IntVarP(&relatedCount, "count", "k", 5, "number of notes to recommend")
+	relatedCmd.Flags().BoolVar(&relatedInsert, "insert", false, "write the recommendations into the note")
+	relatedCmd.Flags().StringVar(&relatedHeading, "heading", related.DEFAULT_HEADING, "heading of the inserted section")
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var (
+	replaceIgnoreCase  bool
+	replaceFixed       bool
+	replaceInteractive bool
+)
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace <pattern> <replacement> [note...]",
+	Short: "Replace the matches of a regular expression",
+	Long: `Replace the matches of the regular expression in the notes, or in every note
+of the vault. The replacement can refer to the groups of the pattern with $1 or
+${name}. Use --dry-run to see how many matches would be replaced.
+
+With --interactive every match is shown with the lines around it and asked for
+confirmation: y replaces it, n skips it, e asks for another replacement, a
+replaces the rest of the matches in the note and q stops after the note.
+
+With --stdin a single note is read from stdin and the result is written to
+stdout, e.g. to filter an editor buffer.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		regex, err := compilePattern(args[0], replaceFixed, replaceIgnoreCase)
+		if err != nil {
+			return err
+		}
+		template := args[1]
+		if replaceFixed {
+			template = escapeTemplate(template)
+		}
+
+		if filterMode {
+			if replaceInteractive {
+				return fmt.Errorf("--interactive can't be used with --stdin")
+			}
+			return runFilter(cmd, args[2:], func(s api.Set) error {
+				_, err := replaceAll(s, regex, template, nil)
+				return err
+			})
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args[2:])
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		var p *prompter
+		if replaceInteractive {
+			p = newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr())
+		}
+
+		total := 0
+		for _, s := range sets {
+			if p != nil && p.quit {
+				break
+			}
+
+			var decide replaceDecider
+			data := *s.Data()
+			path := v.RelPath(s)
+			if p != nil {
+				decide = p.decider(path, data)
+			}
+
+			replacements, err := replaceAll(s, regex, template, decide)
+			if err != nil {
+				return err
+			}
+			if len(replacements) == 0 {
+				continue
+			}
+			total += len(replacements)
+
+			// The records point into the note before the replacements.
+			lines := metadata.NewLineIndex(data)
+			for i, rp := range replacements {
+				text := ""
+				if i == 0 {
+					text = fmt.Sprintf("%s: %d replacements", path, len(replacements))
+				}
+				r := output.NewMatchRecord(output.KIND_REPLACEMENT, path, data, lines, rp.Match).With("replacement", string(rp.Text))
+				if err := w.Write(r, text); err != nil {
+					return err
+				}
+			}
+
+			if err := saveNote(v, s); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if total == 0 {
+			return findings("no matches")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replaceCmd)
+	addDryRunFlag(replaceCmd)
+	addFilterFlag(replaceCmd)
+
+	replaceCmd.Flags().BoolVarP(&replaceIgnoreCase, "ignore-case", "i", false, "match case insensitively")
+	replaceCmd.Flags().BoolVarP(&replaceFixed, "fixed-strings", "F", false, "treat the pattern and the replacement as literal strings")
+	replaceCmd.Flags().BoolVarP(&replaceInteractive, "interactive", "I", false, "confirm every replacement")
+}
+
+// A replacement made by replaceAll, the match is in the data before replacing.
+type replacement struct {
+	Match api.Match
+	Text  []byte
+}
+
+// Decides whether the match is replaced, and with which text. The text is the
+// expanded replacement.
+type replaceDecider func(m api.Match, text []byte) ([]byte, bool)
+
+// Replaces the matches in the set through the SetModifier, every match unless the
+// decider is given. Returns the replacements in the order of the matches.
+func replaceAll(s api.Set, regex *regexp.Regexp, template string, decide replaceDecider) ([]replacement, error) {
+	matches, err := s.Match(regex)
+	if err != nil || len(*matches) == 0 {
+		return nil, err
+	}
+
+	// The submatches, so the groups can be expanded in the callback.
+	data := *s.Data()
+	submatches := make(map[int][]int)
+	for _, sm := range regex.FindAllSubmatchIndex(data, -1) {
+		submatches[sm[0]] = sm
+	}
+
+	// Decide on every match first, the skipped ones are left as they are.
+	replacements := make([]replacement, 0, len(*matches))
+	accepted := make(map[int][]byte)
+	for _, m := range *matches {
+		sm, ok := submatches[m.Begin]
+		if !ok {
+			continue
+		}
+		text := regex.Expand(nil, []byte(template), data, sm)
+		if decide != nil {
+			if text, ok = decide(m, text); !ok {
+				continue
+			}
+		}
+		accepted[m.Begin] = text
+		replacements = append(replacements, replacement{Match: m, Text: text})
+	}
+	if len(replacements) == 0 {
+		return replacements, nil
+	}
+
+	_, err = s.Replace(matches, func(m api.Match, buffer api.Data) ([]byte, bool) {
+		text, ok := accepted[m.Begin]
+		return text, ok
+	})
+	return replacements, err
+}
+
+// Escapes the dollar signs so the template is taken literally.
+func escapeTemplate(t string) string {
+	out := make([]byte, 0, len(t))
+	for i := 0; i < len(t); i++ {
+		if t[i] == '$' {
+			out = append(out, '$')
+		}
+		out = append(out, t[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/periodic"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/review"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	reviewPeriod   string
+	reviewFrom     string
+	reviewTo       string
+	reviewSections []string
+	reviewMetrics  []string
+	reviewNote     string
+	reviewForce    bool
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review [date]",
+	Short: "Roll the daily notes up into a review",
+	Long: `Roll the daily notes of the week of the date, or of the period given with
+--period, up into a review: the contents of the sections given with --section,
+e.g. 'Wins', the tasks done, the notes created and the numbers in the
+frontmatter of the daily notes, like mood or sleep. The date is today by
+default, --from and --to give the days instead.
+
+A note is created during the days if its 'created' property says so. The
+metrics are every frontmatter key with a number unless --metric is given.
+Everything links back to the notes it came from.
+
+The review is written to stdout, or to the note given with --note. An existing
+note is only replaced with --force.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		all, err := periodic.Load(v)
+		if err != nil {
+			return err
+		}
+		period, ok := all[reviewPeriod]
+		if !ok || reviewPeriod == periodic.PERIOD_DAY {
+			return fmt.Errorf("'%s' is not a period, use week, month, quarter or year", reviewPeriod)
+		}
+
+		date := ""
+		if len(args) > 0 {
+			date = args[0]
+		}
+		day, err := parsePeriodDay(period, date)
+		if err != nil {
+			return err
+		}
+		from, to := period.Start(day), period.Add(day, 1).AddDate(0, 0, -1)
+		if reviewFrom != "" {
+			if from, err = parseDay(reviewFrom, time.Now()); err != nil {
+				return err
+			}
+		}
+		if reviewTo != "" {
+			if to, err = parseDay(reviewTo, time.Now()); err != nil {
+				return err
+			}
+		}
+		if to.Before(from) {
+			return fmt.Errorf("the range ends before it starts")
+		}
+
+		notePath := strings.TrimPrefix(reviewNote, "/")
+		if notePath != "" && !vault.IsNotePath(notePath) {
+			notePath += vault.NOTE_EXTENSION
+		}
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+		if notePath != "" {
+			if r.Exists(notePath) && !reviewForce {
+				return fmt.Errorf("'%s' already exists, use --force to replace it", notePath)
+			}
+			r.Add(notePath)
+		}
+
+		rev, err := review.Collect(v, g, all[periodic.PERIOD_DAY], review.Options{
+			From:     from,
+			To:       to,
+			Sections: reviewSections,
+			Metrics:  reviewMetrics,
+			Path:     notePath,
+		})
+		if err != nil {
+			return err
+		}
+
+		c, err := links.NewConverter(v, g, r, links.ConvertOptions{Style: cfg.Settings.LinkStyle, Path: cfg.Settings.LinkPath})
+		if err != nil {
+			return err
+		}
+		title := fmt.Sprintf("Review %s to %s", from.Format(tasks.DATE_LAYOUT), to.Format(tasks.DATE_LAYOUT))
+		text := rev.Render(notePath, title, c.Link)
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		rec := output.NewRecord(output.KIND_NOTE, notePath).
+			With("from", from.Format(tasks.DATE_LAYOUT)).
+			With("to", to.Format(tasks.DATE_LAYOUT)).
+			With("days", rev.Days).
+			With("tasks", len(rev.Tasks)).
+			With("created", len(rev.Created))
+		if notePath == "" || dryRun {
+			if err := w.Write(rec.With("content", text), strings.TrimSuffix(text, "\n")); err != nil {
+				return err
+			}
+			return w.Close()
+		}
+
+		s, err := v.NewNote(notePath)
+		if err != nil {
+			return err
+		}
+		data := *s.Data()
+		_, err = s.Replace(&[]api.Match{{Begin: 0, End: len(data)}}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+			return []byte(text), true
+		})
+		if err != nil {
+			return err
+		}
+		if err := saveNote(v, s); err != nil {
+			return err
+		}
+		if err := w.Write(rec, notePath); err != nil {
+			return err
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+	addDryRunFlag(reviewCmd)
+
+	reviewCmd.Flags().StringVarP(&reviewPeriod, "period", "p", periodic.PERIOD_WEEK, "period of the review, week, month, quarter or year")
+	reviewCmd.Flags().StringVar(&reviewFrom, "from", "", "first day of the review")
+	reviewCmd.Flags().StringVar(&reviewTo, "to", "", "last day of the review")
+	reviewCmd.Flags().StringArrayVarP(&reviewSections, "section", "s", []string{}, "heading to collect from the daily notes, e.g. Wins")
+	reviewCmd.Flags().StringArrayVarP(&reviewMetrics, "metric", "m", []string{}, "frontmatter key to sum up")
+	reviewCmd.Flags().StringVar(&reviewNote, "note", "", "path of the review note")
+	reviewCmd.Flags().BoolVar(&reviewForce, "force", false, "replace the review note if it exists")
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var rmForce bool
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <note...>",
+	Short: "Move notes to the trash of the vault",
+	Long: `Move the notes or the attachments to the .trash folder of the vault, like
+Obsidian does. Files which are still linked from other notes are not removed
+unless --force is given.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		targets := make([]string, 0, len(args))
+		removing := make(map[string]bool)
+		for _, arg := range args {
+			target, ok := r.Resolve("", arg)
+			if !ok {
+				return fmt.Errorf("'%s' not found", arg)
+			}
+			targets = append(targets, target)
+			removing[target] = true
+		}
+
+		// Find the links which would break.
+		linked := 0
+		for _, s := range g.Sets() {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+			if removing[md.Path] {
+				continue
+			}
+			for _, l := range md.Links {
+				if target, ok := r.Resolve(md.Path, l.Target); ok && !l.External && removing[target] {
+					warn(cmd, "%s:%d: links to %s", md.Path, l.Line, target)
+					linked += 1
+				}
+			}
+		}
+		if linked > 0 && !rmForce {
+			return fmt.Errorf("%d links would break, use --force to remove anyway", linked)
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, target := range targets {
+			if dryRun {
+				if err := w.Write(output.NewRecord(output.KIND_FILE, target), target); err != nil {
+					return err
+				}
+				continue
+			}
+			trashed, err := v.Trash(target)
+			if err != nil {
+				return err
+			}
+			r := output.NewRecord(output.KIND_FILE, target).With("to", trashed)
+			if err := w.Write(r, fmt.Sprintf("%s -> %s", target, trashed)); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+	addDryRunFlag(rmCmd)
+
+	rmCmd.Flags().BoolVarP(&rmForce, "force", "f", false, "remove even if other notes link to it")
+}
@@ -5,35 +5,129 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/config"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
 )
 
+// The exit codes of the commands.
+const (
+	// Everything went fine.
+	EXIT_OK int = 0
 
+	// The command worked but found something, e.g. lint problems or no grep matches.
+	EXIT_FINDINGS int = 1
+
+	// The command failed.
+	EXIT_ERROR int = 2
+)
+
+// The path of the vault every command works on.
+var vaultPath string
+
+// The global configuration file and the profile to use.
+var (
+	cfgFile    string
+	cfgProfile string
+)
+
+// The loaded configuration, available to every command.
+var cfg *config.Config
+
+// Whether the mutating commands only report what they would do.
+var dryRun bool
+
+// The output format of the results and the optional template of a result.
+var (
+	outputFormat   string
+	outputTemplate string
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "obsidian-document-manager",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Use:   "odm",
+	Short: "Manage the notes of an Obsidian vault",
+	Long: `odm works on the notes of an Obsidian vault from the command line. It can
+list, search, lint, format and rewrite the notes in bulk.
+
+Every command works on the vault in the current folder unless --vault or a
+profile from the configuration says otherwise. The configuration is read from
+~/` + config.HOME_FILE_NAME + `, then from .odm/config of the vault and then
+from the ODM_* environment variables.
+
+The commands exit with 0 on success, 1 when they find something, e.g. lint
+problems or no grep matches, and 2 on errors.
+
+The results can be written as json, ndjson or tsv with --output, or with a Go
+text/template given with --format, e.g. --format '{{.Path}}:{{.Line}}'. Every
+result has the version of the schema, its kind, the path of the note, the byte
+range, the line and the column of its start and end, the matched text and the
+lines around it. The errors are written to stderr in the same format.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.CheckFormat(outputFormat); err != nil {
+			outputFormat = output.FORMAT_TEXT
+			return err
+		}
+
+		var err error
+		cfg, err = config.Load(config.Options{
+			HomeFile: cfgFile,
+			Profile:  cfgProfile,
+			Vault:    vaultPath,
+		})
+		return err
+	},
+}
+
+// An error with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	// Run: func(cmd *cobra.Command, args []string) { },
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Reports that the command found something, it exits with EXIT_FINDINGS.
+func findings(format string, obj ...any) error {
+	return &exitError{code: EXIT_FINDINGS, err: fmt.Errorf(format, obj...)}
+}
+
+// Writes a warning to stderr, as an error record in the structured formats.
+func warn(cmd *cobra.Command, format string, obj ...any) {
+	output.WriteError(cmd.ErrOrStderr(), outputFormat, fmt.Errorf(format, obj...), EXIT_OK)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	if err == nil {
+		return
+	}
+
+	var exit *exitError
+	if errors.As(err, &exit) {
+		if exit.err.Error() != "" {
+			output.WriteError(os.Stderr, outputFormat, exit.err, exit.code)
+		}
+		os.Exit(exit.code)
+	}
+
+	if outputFormat == output.FORMAT_TEXT {
+		err = fmt.Errorf("Error: %w", err)
 	}
+	output.WriteError(os.Stderr, outputFormat, err, EXIT_ERROR)
+	os.Exit(EXIT_ERROR)
 }
 
 func init() {
@@ -41,11 +135,36 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.obsidian-document-manager.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/"+config.HOME_FILE_NAME+")")
+	rootCmd.PersistentFlags().StringVar(&cfgProfile, "profile", "", "named vault profile from the config file")
+	rootCmd.PersistentFlags().StringVar(&vaultPath, "vault", "", "path of the vault (default from the config, or the current folder)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", output.FORMAT_TEXT, "output format, one of "+strings.Join(output.Formats, ", "))
+	rootCmd.PersistentFlags().StringVar(&outputTemplate, "format", "", "Go text/template executed for every result")
+}
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// Adds the --dry-run flag to a mutating command.
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "only report the changes, don't write anything")
 }
 
+// Opens the vault given with the --vault flag or the configuration.
+func openVault() (*vault.Vault, error) {
+	path := cfg.Settings.Vault
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("vault '%s' is not a folder", path)
+	}
+
+	v, err := vault.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	v.SetIgnore(cfg.Settings.Ignore)
+	return v, nil
+}
 
+// Creates the writer of the results of the command.
+func newWriter(cmd *cobra.Command) (*output.Writer, error) {
+	return output.NewWriter(cmd.OutOrStdout(), outputFormat, cmd.Name(), outputTemplate)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/search"
+)
+
+var (
+	searchLimit int
+	searchWidth int
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the notes with a ranked full-text query",
+	Long: `Search the notes of the vault. The index is kept under the .odm folder and only
+the changed notes are indexed again.
+
+A query is made of clauses which must all match:
+  word          the word in any field, stemmed
+  "some words"  the words next to each other
+  prefix*       any word starting with the prefix
+  field:clause  only in title, heading, body or tag
+  -clause       notes which don't match`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query, err := search.ParseQuery(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+
+		v, err := openVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		g, err := v.Load()
+		if err != nil {
+			return err
+		}
+
+		idx, err := search.LoadVaultIndex(v)
+		if err != nil {
+			return err
+		}
+		if _, err := idx.Sync(v, g); err != nil {
+			return err
+		}
+		if err := idx.Save(); err != nil {
+			return err
+		}
+
+		data := make(map[string][]byte)
+		for _, s := range g.Sets() {
+			data[v.RelPath(s)] = *s.Data()
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		// The matches are only colored on a terminal, not in a pipe or a file.
+		color := !w.Structured() && isTerminal(cmd.OutOrStdout())
+
+		results := idx.Search(query, searchLimit)
+		for _, r := range results {
+			text := fmt.Sprintf("%s (%.2f)", r.Path, r.Score)
+			snippet := search.MakeSnippet(data[r.Path], r.Matches, searchWidth)
+			if snippet.Text != "" && color {
+				text += "\n    " + snippet.Highlight(COLOR_OLD, COLOR_RESET)
+			} else if snippet.Text != "" {
+				text += "\n    " + snippet.Text
+			}
+
+			// The record covers the first match, the rest are in the snippet.
+			record := output.NewRecord(output.KIND_NOTE, r.Path)
+			if len(r.Matches) > 0 {
+				record = output.NewMatchRecord(output.KIND_NOTE, r.Path, data[r.Path], metadata.NewLineIndex(data[r.Path]), r.Matches[0])
+			}
+			record = record.With("score", r.Score).With("snippet", snippet.Text)
+			if err := w.Write(record, text); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		if len(results) == 0 {
+			return findings("no notes found")
+		}
+		return nil
+	},
+}
+
+// Checks if the writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	if f, ok := w.(*os.File); ok {
+		if info, err := f.Stat(); err == nil {
+			return info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "maximum number of results, 0 for all")
+	searchCmd.Flags().IntVar(&searchWidth, "width", 120, "width of the snippets in characters")
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the statistics of the vault",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		notes, words, size := 0, 0, 0
+		links, broken, external := 0, 0, 0
+		tags := make(map[string]bool)
+		linked := make(map[string]bool)
+		orphans := 0
+
+		for _, s := range g.Sets() {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+			notes += 1
+			size += len(*s.Data())
+			words += len(bytes.Fields((*s.Data())[md.Frontmatter.Match.End:]))
+
+			for _, t := range md.AllTags() {
+				tags[t] = true
+			}
+
+			for _, l := range md.Links {
+				links += 1
+				if l.External {
+					external += 1
+				} else if target, ok := r.Resolve(md.Path, l.Target); !ok {
+					broken += 1
+				} else if target != md.Path {
+					linked[target] = true
+					linked[md.Path] = true
+				}
+			}
+		}
+
+		for _, s := range g.Sets() {
+			if !linked[v.RelPath(s)] {
+				orphans += 1
+			}
+		}
+
+		attachments := 0
+		for _, p := range r.Paths() {
+			if !vault.IsNotePath(p) {
+				attachments += 1
+			}
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		rec := output.NewRecord(output.KIND_STATS, "")
+		text := make([]string, 0)
+		for _, stat := range []struct {
+			name  string
+			value int
+		}{
+			{"notes", notes},
+			{"attachments", attachments},
+			{"words", words},
+			{"bytes", size},
+			{"tags", len(tags)},
+			{"links", links},
+			{"external", external},
+			{"broken", broken},
+			{"orphans", orphans},
+		} {
+			rec = rec.With(stat.name, stat.value)
+			text = append(text, fmt.Sprintf("%-12s %d", stat.name, stat.value))
+		}
+		if err := w.Write(rec, strings.Join(text, "\n")); err != nil {
+			return err
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+)
+
+var tagsSortByCount bool
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags [note...]",
+	Short: "List the tags with the number of notes using them",
+	Long: `List the tags of the notes, or of the whole vault, with the number of notes
+using each. Both the frontmatter and the inline tags are counted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		counts := make(map[string]int)
+		for _, s := range sets {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+			for _, t := range md.AllTags() {
+				counts[t] += 1
+			}
+		}
+
+		tags := make([]string, 0, len(counts))
+		for t := range counts {
+			tags = append(tags, t)
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			if tagsSortByCount && counts[tags[i]] != counts[tags[j]] {
+				return counts[tags[i]] > counts[tags[j]]
+			}
+			return tags[i] < tags[j]
+		})
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, t := range tags {
+			r := output.NewRecord(output.KIND_TAG, "").With("tag", t).With("count", counts[t])
+			if err := w.Write(r, fmt.Sprintf("%6d  #%s", counts[t], t)); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+
+	tagsCmd.Flags().BoolVarP(&tagsSortByCount, "count", "c", false, "sort by the number of notes")
+}
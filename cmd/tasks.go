@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var (
+	tasksAll     bool
+	tasksDone    bool
+	tasksOverdue bool
+	tasksWeek    bool
+	tasksTag     string
+	tasksPath    string
+	tasksSort    []string
+	tasksToday   string
+	tasksField   string
+	tasksDays    int
+	tasksTo      string
+)
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks [note...]",
+	Short: "List the tasks of the notes",
+	Long: `List the checkbox tasks of the notes, or of the whole vault. Only the open
+tasks are listed unless --all or --done is given. The Tasks plugin metadata is
+understood: 📅 due, ⏳ scheduled, 🛫 start, ✅ done, the priorities 🔺 ⏫ 🔼 🔽 ⏬
+and 🔁 recurrence.
+
+The tasks are referred to as path:line by the other tasks commands.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today, err := parseDay(tasksToday, time.Now())
+		if err != nil {
+			return err
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		all := make([]tasks.Task, 0)
+		for _, s := range sets {
+			all = append(all, tasks.Parse(v.RelPath(s), *s.Data())...)
+		}
+
+		predicates := make([]tasks.Predicate, 0)
+		switch {
+		case tasksDone:
+			predicates = append(predicates, tasks.IsDone())
+		case !tasksAll:
+			predicates = append(predicates, tasks.IsOpen())
+		}
+		if tasksOverdue {
+			predicates = append(predicates, tasks.Overdue(today))
+		}
+		if tasksWeek {
+			predicates = append(predicates, tasks.DueThisWeek(today))
+		}
+		if tasksTag != "" {
+			predicates = append(predicates, tasks.WithTag(tasksTag))
+		}
+		if tasksPath != "" {
+			predicates = append(predicates, tasks.InPath(tasksPath))
+		}
+
+		found := tasks.Filter(all, predicates...)
+		if err := tasks.Sort(found, tasksSort...); err != nil {
+			return err
+		}
+
+		data := notesData(v, sets)
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, t := range found {
+			if err := w.Write(taskRecord(t, data[t.Path]), fmt.Sprintf("%s:%d: %s", t.Path, t.Line, strings.TrimSpace(t.Raw))); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	},
+}
+
+var tasksToggleCmd = &cobra.Command{
+	Use:   "toggle <path:line>...",
+	Short: "Check the tasks off or open them again",
+	Long: `Check the tasks off, adding the ✅ done date, or open the done ones again.
+Nothing else on the lines changes. When a recurring task is checked off its next
+occurrence is added above it, with its dates moved by the 🔁 rule.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today, err := parseDay(tasksToday, time.Now())
+		if err != nil {
+			return err
+		}
+		return editTasks(cmd, args, func(s api.Set, t tasks.Task) (bool, error) {
+			return tasks.Toggle(s, t, today)
+		})
+	},
+}
+
+var tasksRescheduleCmd = &cobra.Command{
+	Use:   "reschedule <date> <path:line>...",
+	Short: "Move the due date of the tasks",
+	Long: `Move the due date of the tasks, or the scheduled date of the tasks which only
+have that. The date is YYYY-MM-DD, today, tomorrow, or a number of days or
+weeks from today like +3d or +2w. With --field another date field is set.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today, err := parseDay(tasksToday, time.Now())
+		if err != nil {
+			return err
+		}
+		date, err := parseDay(args[0], today)
+		if err != nil {
+			return err
+		}
+		return editTasks(cmd, args[1:], func(s api.Set, t tasks.Task) (bool, error) {
+			if tasksField != "" {
+				return tasks.SetDate(s, t, tasksField, date)
+			}
+			return tasks.Reschedule(s, t, date)
+		})
+	},
+}
+
+var tasksRolloverCmd = &cobra.Command{
+	Use:   "rollover [note...]",
+	Short: "Add the next occurrences of the checked off recurring tasks",
+	Long: `Add the next occurrences of the recurring tasks which are checked off without
+one, e.g. in an editor without the Tasks plugin. The occurrence is added above the
+task with its dates moved by the 🔁 rule, like 'every week on Monday', 'every 2
+months' or 'every day when done'. All of the notes are written together.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today, err := parseDay(tasksToday, time.Now())
+		if err != nil {
+			return err
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		// The tasks are reported where they were.
+		data := notesData(v, sets)
+		changed, rolled, err := tasks.Rollover(v, sets, today)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, t := range rolled {
+			if err := w.Write(taskRecord(t, data[t.Path]), fmt.Sprintf("%s:%d: %s", t.Path, t.Line, strings.TrimSpace(t.Raw))); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return commitNotes(v, changed)
+	},
+}
+
+var tasksArchiveCmd = &cobra.Command{
+	Use:   "archive [note...]",
+	Short: "Move the old done tasks to an archive note",
+	Long: `Move the tasks done more than --days days ago out of the notes, or out of every
+note of the vault, to the end of the archive note. The lines nested under a task
+go along with it, so a task is only moved when the tasks nested under it are
+closed as well. The tasks are grouped under a heading linking back to the note
+they came from.
+
+The archive note is 'Archive/Tasks <date>.md' with the date of today in the
+date_format setting, unless --to is given. The slashes and colons of the date
+are written as dashes, so they don't make folders. The other notes in the folder
+of the archive note are not archived again. All of the notes are written
+together.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		today, err := parseDay(tasksToday, time.Now())
+		if err != nil {
+			return err
+		}
+		if tasksDays < 0 {
+			return fmt.Errorf("--days can't be negative")
+		}
+
+		to := tasksTo
+		if to == "" {
+			to = tasks.ArchivePath(today, cfg.Settings.DateFormat)
+		} else if !vault.IsNotePath(to) {
+			to += vault.NOTE_EXTENSION
+		}
+
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		r, err := v.Resolver()
+		if err != nil {
+			return err
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		archive, err := v.NewNote(to)
+		if err != nil {
+			return err
+		}
+		for _, s := range g.Sets() {
+			if v.RelPath(s) == v.RelPath(archive) {
+				archive = s
+			}
+		}
+		r.Add(v.RelPath(archive))
+
+		c, err := links.NewConverter(v, g, r, links.ConvertOptions{Style: cfg.Settings.LinkStyle, Path: cfg.Settings.LinkPath})
+		if err != nil {
+			return err
+		}
+
+		data := notesData(v, sets)
+		changed, archived, err := tasks.Archive(v, sets, archive, today.AddDate(0, 0, -tasksDays), c.Link)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+		for _, a := range archived {
+			t := a.Task
+			rec := taskRecord(t, data[t.Path]).With("archive", v.RelPath(archive))
+			if err := w.Write(rec, fmt.Sprintf("%s:%d: %s -> %s", t.Path, t.Line, strings.TrimSpace(t.Raw), v.RelPath(archive))); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return commitNotes(v, changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tasksCmd)
+	tasksCmd.AddCommand(tasksToggleCmd, tasksRescheduleCmd, tasksRolloverCmd, tasksArchiveCmd)
+	addDryRunFlag(tasksToggleCmd)
+	addDryRunFlag(tasksRescheduleCmd)
+	addDryRunFlag(tasksRolloverCmd)
+	addDryRunFlag(tasksArchiveCmd)
+
+	tasksCmd.PersistentFlags().StringVar(&tasksToday, "today", "", "the date of today, YYYY-MM-DD")
+	tasksCmd.Flags().BoolVarP(&tasksAll, "all", "a", false, "list the done and cancelled tasks as well")
+	tasksCmd.Flags().BoolVar(&tasksDone, "done", false, "only list the done tasks")
+	tasksCmd.Flags().BoolVar(&tasksOverdue, "overdue", false, "only list the open tasks due before today")
+	tasksCmd.Flags().BoolVar(&tasksWeek, "week", false, "only list the tasks due this week")
+	tasksCmd.Flags().StringVarP(&tasksTag, "tag", "t", "", "only list the tasks with the tag")
+	tasksCmd.Flags().StringVar(&tasksPath, "path", "", "only list the tasks in the notes matching the glob")
+	tasksCmd.Flags().StringSliceVarP(&tasksSort, "sort", "s", []string{}, "sort by "+strings.Join(tasks.SortKeys, ", "))
+	tasksRescheduleCmd.Flags().StringVar(&tasksField, "field", "", "date field to set, e.g. scheduled or start")
+	tasksArchiveCmd.Flags().IntVar(&tasksDays, "days", 30, "only archive the tasks done more than this many days ago")
+	tasksArchiveCmd.Flags().StringVar(&tasksTo, "to", "", "path of the archive note")
+}
+
+// Runs the edit on every task referred to as path:line, the notes are written
+// together at the end.
+func editTasks(cmd *cobra.Command, refs []string, fn func(s api.Set, t tasks.Task) (bool, error)) error {
+	v, g, err := loadVault()
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	r, err := v.Resolver()
+	if err != nil {
+		return err
+	}
+
+	w, err := newWriter(cmd)
+	if err != nil {
+		return err
+	}
+
+	// From the bottom up, so the lines added for the recurring tasks don't move the
+	// tasks which are not edited yet.
+	refs = append([]string{}, refs...)
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refLine(refs[i]) > refLine(refs[j])
+	})
+
+	changed := make([]api.Set, 0)
+	for _, ref := range refs {
+		s, t, err := findTask(v, g, r, ref)
+		if err != nil {
+			return err
+		}
+		before := bytes.Count(*s.Data(), []byte("\n"))
+		ok, err := fn(s, t)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if !containsSet(changed, s) {
+			changed = append(changed, s)
+		}
+
+		// Show the lines as they are now.
+		added := bytes.Count(*s.Data(), []byte("\n")) - before
+		for _, nt := range tasks.Parse(t.Path, *s.Data()) {
+			if nt.Line >= t.Line && nt.Line <= t.Line+added {
+				if err := w.Write(taskRecord(nt, *s.Data()), fmt.Sprintf("%s:%d: %s", nt.Path, nt.Line, strings.TrimSpace(nt.Raw))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return commitNotes(v, changed)
+}
+
+// Gets the contents of the notes by their paths.
+func notesData(v *vault.Vault, sets []api.Set) map[string][]byte {
+	data := make(map[string][]byte)
+	for _, s := range sets {
+		data[v.RelPath(s)] = *s.Data()
+	}
+	return data
+}
+
+// Gets the line of a path:line reference, 0 if there is none.
+func refLine(ref string) int {
+	line, _ := strconv.Atoi(ref[strings.LastIndex(ref, ":")+1:])
+	return line
+}
+
+func containsSet(sets []api.Set, s api.Set) bool {
+	for _, o := range sets {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the task referred to as path:line. The task is parsed from the current
+// contents of the note, so several edits on the same note work.
+func findTask(v *vault.Vault, g api.Group, r *vault.Resolver, ref string) (api.Set, tasks.Task, error) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 {
+		return nil, tasks.Task{}, fmt.Errorf("'%s' is not a path:line", ref)
+	}
+	line, err := strconv.Atoi(ref[i+1:])
+	if err != nil {
+		return nil, tasks.Task{}, fmt.Errorf("'%s' is not a path:line", ref)
+	}
+
+	s, err := findNote(v, g, r, ref[:i])
+	if err != nil {
+		return nil, tasks.Task{}, err
+	}
+	for _, t := range tasks.Parse(v.RelPath(s), *s.Data()) {
+		if t.Line == line {
+			return s, t, nil
+		}
+	}
+	return nil, tasks.Task{}, fmt.Errorf("there is no task at '%s'", ref)
+}
+
+// Creates the record of a task.
+func taskRecord(t tasks.Task, data []byte) output.Record {
+	r := output.NewMatchRecord(output.KIND_TASK, t.Path, data, metadata.NewLineIndex(data), t.Match).
+		With("status", string(t.Status)).
+		With("text", t.Text).
+		With("priority", t.Priority).
+		With("tags", t.Tags).
+		With("parent", t.Parent)
+	for _, f := range t.Fields {
+		if f.Name != tasks.FIELD_PRIORITY {
+			r = r.With(f.Name, f.Value)
+		}
+	}
+	return r
+}
+
+// Parses a day given on the command line: YYYY-MM-DD or in the date_format setting,
+// today, tomorrow, yesterday, or a number of days or weeks from the base like +3d,
+// -1w. Empty is the base.
+func parseDay(s string, base time.Time) (time.Time, error) {
+	base = tasks.Day(base)
+	switch strings.ToLower(s) {
+	case "", "today":
+		return base, nil
+	case "tomorrow":
+		return base.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return base.AddDate(0, 0, -1), nil
+	}
+
+	if len(s) > 2 && (s[0] == '+' || s[0] == '-') {
+		n, err := strconv.Atoi(s[1 : len(s)-1])
+		if err == nil {
+			if s[0] == '-' {
+				n = -n
+			}
+			switch s[len(s)-1] {
+			case 'd':
+				return base.AddDate(0, 0, n), nil
+			case 'w':
+				return base.AddDate(0, 0, 7*n), nil
+			}
+		}
+	}
+
+	if d, err := time.ParseInLocation(tasks.DATE_LAYOUT, s, time.Local); err == nil {
+		return d, nil
+	}
+	if d, err := moment.Parse(cfg.Settings.DateFormat, s, time.Local); err == nil {
+		return tasks.Day(d), nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a date, use YYYY-MM-DD", s)
+}
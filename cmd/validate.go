@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/output"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/schema"
+)
+
+var (
+	validateFix     bool
+	validateSchemas []string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [note...]",
+	Short: "Check the frontmatter of the notes against the schemas",
+	Long: `Check the frontmatter of the notes, all of them by default, against the schemas
+of the configuration. A schema applies to the notes matching its paths or having
+one of its tags, e.g.
+
+  schemas:
+    book:
+      paths: [Books/**]
+      tags: [book]
+      strict: false
+      properties:
+        title:  {type: string, required: true}
+        author: {type: link, required: true}
+        rating: {type: integer, enum: ["1", "2", "3", "4", "5"]}
+        status: {type: string, enum: [reading, done], default: reading}
+        isbn:   {type: string, pattern: '^[0-9-]{10,17}$'}
+        added:  {type: date, format: YYYY-MM-DD, default: today}
+        genres: {type: list}
+
+The types are string, number, integer, boolean, date, list and link. With strict
+the keys which aren't in the schema are reported too.
+
+With --fix the missing properties get their defaults and the values of the wrong
+type are converted where it is clear, e.g. '"5"' to 5, 'yes' to true, the dates
+to the format, 'a, b' to a list and 'Bob' to '[[Bob]]'. With --dry-run the fixes
+are shown as a diff instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, g, err := loadVault()
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+
+		schemas := cfg.Settings.Schemas
+		if len(schemas) == 0 {
+			return fmt.Errorf("there are no schemas in the configuration")
+		}
+		if len(validateSchemas) > 0 {
+			// Only check the given schemas.
+			schemas = make(map[string]*schema.Schema)
+			for _, name := range validateSchemas {
+				s, ok := cfg.Settings.Schemas[name]
+				if !ok {
+					return fmt.Errorf("schema '%s' is not found", name)
+				}
+				schemas[name] = s
+			}
+		}
+		set, err := schema.NewSet(schemas)
+		if err != nil {
+			return err
+		}
+
+		sets, err := selectNotes(v, g, args)
+		if err != nil {
+			return err
+		}
+
+		w, err := newWriter(cmd)
+		if err != nil {
+			return err
+		}
+
+		problems := 0
+		modified := make([]api.Set, 0)
+		for _, s := range sets {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return err
+			}
+			found := set.Check(*s.Data(), md)
+
+			if validateFix {
+				fixed, err := schema.Fix(s, found)
+				if err != nil {
+					return err
+				}
+				if fixed > 0 {
+					modified = append(modified, s)
+					md = metadata.Parse(md.Path, *s.Data())
+					found = set.Check(*s.Data(), md)
+				}
+			}
+
+			data := *s.Data()
+			lines := metadata.NewLineIndex(data)
+			for _, p := range found {
+				r := output.NewMatchRecord(output.KIND_DIAGNOSTIC, p.Path, data, lines, p.Match).
+					With("schema", p.Rule).
+					With("key", p.Key).
+					With("severity", p.SeverityName()).
+					With("message", p.Message).
+					With("fixable", p.Fix != nil)
+				if err := w.Write(r, p.String()); err != nil {
+					return err
+				}
+			}
+			problems += len(found)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if err := writeNotes(cmd, w, v, modified); err != nil {
+			return err
+		}
+
+		if problems > 0 {
+			return findings("%d problems found", problems)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	addDryRunFlag(validateCmd)
+
+	validateCmd.Flags().BoolVar(&validateFix, "fix", false, "fill in the defaults and convert the values of the wrong type")
+	validateCmd.Flags().StringSliceVar(&validateSchemas, "schema", []string{}, "only check the given schemas")
+}
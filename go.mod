@@ -2,7 +2,10 @@ module github.com/ubombar/obsidian-document-manager
 
 go 1.23.1
 
-require github.com/spf13/cobra v1.8.1
+require (
+	github.com/spf13/cobra v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,180 @@
+package attachments
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The files which are not notes but not attachments either, they are never
+// reported as unused.
+var Documents = []string{".canvas"}
+
+// A link from a note.
+type Reference struct {
+	// The path of the note the link is in.
+	Note string
+	Link metadata.Link
+}
+
+// A file which is not a note, with the links to it.
+type Attachment struct {
+	Path       string
+	Size       int64
+	References []Reference
+}
+
+// The attachments of the vault and the links to the attachments which don't exist.
+type Report struct {
+	Attachments []Attachment
+	Missing     []Reference
+}
+
+// A file which moves to another path.
+type Move struct {
+	From string
+	To   string
+}
+
+// Finds every attachment of the vault and the links to them.
+func Scan(v *vault.Vault, g api.Group, r *vault.Resolver) (*Report, error) {
+	rep := &Report{Attachments: make([]Attachment, 0), Missing: make([]Reference, 0)}
+	index := make(map[string]int)
+
+	err := v.Root().Walk(func(f *file.File) error {
+		rel, err := v.Root().Rel(f)
+		if err != nil {
+			return err
+		}
+		if !IsAttachment(rel) || v.Ignored(rel) {
+			return nil
+		}
+		size, err := f.Size()
+		if err != nil {
+			return err
+		}
+		index[rel] = len(rep.Attachments)
+		rep.Attachments = append(rep.Attachments, Attachment{Path: rel, Size: size, References: make([]Reference, 0)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sets := g.Sets()
+	sort.Slice(sets, func(i, j int) bool {
+		return v.RelPath(sets[i]) < v.RelPath(sets[j])
+	})
+	for _, s := range sets {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range md.Links {
+			if l.External || l.Target == "" {
+				continue
+			}
+			ref := Reference{Note: md.Path, Link: l}
+			if target, ok := r.Resolve(md.Path, l.Target); ok {
+				if i, ok := index[target]; ok {
+					rep.Attachments[i].References = append(rep.Attachments[i].References, ref)
+				}
+			} else if IsAttachment(l.Target) {
+				rep.Missing = append(rep.Missing, ref)
+			}
+		}
+	}
+	return rep, nil
+}
+
+// Checks if the path is an attachment, a file which is neither a note nor a
+// document like a canvas.
+func IsAttachment(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" || ext == vault.NOTE_EXTENSION {
+		return false
+	}
+	for _, d := range Documents {
+		if ext == d {
+			return false
+		}
+	}
+	return true
+}
+
+// Gets the attachments no note links to.
+func (rep *Report) Unused() []Attachment {
+	unused := make([]Attachment, 0)
+	for _, a := range rep.Attachments {
+		if len(a.References) == 0 {
+			unused = append(unused, a)
+		}
+	}
+	return unused
+}
+
+// Gets the folder the attachments of the note go to, for the attachmentFolderPath
+// setting of Obsidian.
+func Folder(setting, note string) string {
+	setting = strings.TrimSpace(setting)
+	switch {
+	case setting == "" || setting == "/":
+		return ""
+	case setting == "." || setting == "./":
+		return path.Dir(note)
+	case strings.HasPrefix(setting, "./"):
+		return path.Join(path.Dir(note), setting[2:])
+	}
+	return strings.Trim(path.Clean(setting), "/")
+}
+
+// Moves the linked attachments into the attachment folder of the first note linking
+// to them, and rewrites the links in the notes. The notes are only changed in memory,
+// the changed ones and the moves are returned.
+func Relocate(v *vault.Vault, g api.Group, r *vault.Resolver, rep *Report, setting string) ([]api.Set, []Move, error) {
+	changed := make([]api.Set, 0)
+	seen := make(map[api.Set]bool)
+	moves := make([]Move, 0)
+
+	for _, a := range rep.Attachments {
+		if len(a.References) == 0 {
+			continue
+		}
+
+		folder := Folder(setting, a.References[0].Note)
+		if path.Dir(a.Path) == folder || (folder == "" && path.Dir(a.Path) == ".") {
+			continue
+		}
+		dest := freePath(r, path.Join(folder, path.Base(a.Path)))
+
+		sets, _, err := links.Retarget(v, g, r, a.Path, dest)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range sets {
+			if !seen[s] {
+				seen[s] = true
+				changed = append(changed, s)
+			}
+		}
+		moves = append(moves, Move{From: a.Path, To: dest})
+	}
+	return changed, moves, nil
+}
+
+// Appends a number to the name until no file has the path.
+func freePath(r *vault.Resolver, p string) string {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 1; r.Exists(p); i++ {
+		p = fmt.Sprintf("%s %d%s", base, i, ext)
+	}
+	return p
+}
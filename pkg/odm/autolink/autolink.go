@@ -0,0 +1,277 @@
+package autolink
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The default minimum length of the titles and the aliases which are looked for.
+const DEFAULT_MIN_LENGTH = 3
+
+type Options struct {
+	// Only link the first mention of each note.
+	FirstOnly bool
+
+	// Match the titles and the aliases case sensitively.
+	CaseSensitive bool
+
+	// Titles and aliases shorter than this many characters are ignored.
+	MinLength int
+}
+
+// A name a note can be mentioned by, its title or one of its aliases.
+type Name struct {
+	Text string
+
+	// The path of the note relative to the vault.
+	Path string
+}
+
+// A plain text occurrence of another note's name.
+type Mention struct {
+	// The note which is mentioned.
+	Target string
+
+	// The matched text as it is written.
+	Text string
+
+	Match api.Match
+	Line  int
+	Col   int
+}
+
+// The text which isn't prose: the urls and the autolinks, the html tags and the math.
+var (
+	urlRegex  = regexp.MustCompile(`<[a-zA-Z][a-zA-Z0-9+.-]*:[^<>\s]*>|\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>()\[\]]*|\bwww\.[^\s<>()\[\]]+`)
+	htmlRegex = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9-]*(?:\s[^<>]*)?/?>`)
+	mathRegex = regexp.MustCompile(`(?s)\$\$.+?\$\$|\$[^\s$](?:[^$\n]*[^\s$])?\$`)
+)
+
+// The names of the notes to look for. Names shared by more than one note are ambiguous,
+// so they are left out.
+type Linker struct {
+	opts     Options
+	names    map[string]string
+	regex    *regexp.Regexp
+	resolver *vault.Resolver
+}
+
+// Collects the titles and the aliases of every note in the group.
+func NewLinker(v *vault.Vault, g api.Group, r *vault.Resolver, opts Options) (*Linker, error) {
+	if opts.MinLength <= 0 {
+		opts.MinLength = DEFAULT_MIN_LENGTH
+	}
+
+	names := make([]Name, 0)
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, Name{Text: md.Title, Path: md.Path})
+		for _, alias := range md.Aliases() {
+			names = append(names, Name{Text: alias, Path: md.Path})
+		}
+	}
+
+	return NewLinkerFromNames(names, r, opts)
+}
+
+func NewLinkerFromNames(names []Name, r *vault.Resolver, opts Options) (*Linker, error) {
+	l := &Linker{opts: opts, names: make(map[string]string), resolver: r}
+
+	ambiguous := make(map[string]bool)
+	for _, n := range names {
+		text := strings.TrimSpace(n.Text)
+		if utf8.RuneCountInString(text) < opts.MinLength {
+			continue
+		}
+		key := l.key(text)
+		if p, ok := l.names[key]; ok && p != n.Path {
+			ambiguous[key] = true
+		}
+		l.names[key] = n.Path
+	}
+	for key := range ambiguous {
+		delete(l.names, key)
+	}
+
+	if len(l.names) == 0 {
+		return l, nil
+	}
+
+	// Longer names come first, so they win over the names they contain.
+	keys := make([]string, 0, len(l.names))
+	for key := range l.names {
+		keys = append(keys, regexp.QuoteMeta(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	flags := ""
+	if !opts.CaseSensitive {
+		flags = "(?i)"
+	}
+	regex, err := regexp.Compile(flags + "(?:" + strings.Join(keys, "|") + ")")
+	if err != nil {
+		return nil, err
+	}
+	l.regex = regex
+	return l, nil
+}
+
+func (l *Linker) key(text string) string {
+	if l.opts.CaseSensitive {
+		return text
+	}
+	return strings.ToLower(text)
+}
+
+// Finds the unlinked mentions of the other notes in the set. The frontmatter, code,
+// comments, headings, the existing links, the tags, the urls, the html tags, the
+// math, the dates of the tasks and the inline fields are skipped, and so are the
+// mentions of the note itself.
+func (l *Linker) Find(s api.Set, md *metadata.Metadata) ([]Mention, error) {
+	mentions := make([]Mention, 0)
+	if l.regex == nil {
+		return mentions, nil
+	}
+
+	matches, err := s.Match(l.regex)
+	if err != nil {
+		return nil, err
+	}
+
+	data := *s.Data()
+	skip := append([]api.Match{}, md.Verbatim...)
+	skip = append(skip, md.Frontmatter.Match)
+	for _, h := range md.Headings {
+		skip = append(skip, h.Match)
+	}
+	for _, link := range md.Links {
+		skip = append(skip, link.Match)
+	}
+	for _, t := range md.Tags {
+		skip = append(skip, t.Match)
+	}
+	for _, f := range md.Fields {
+		skip = append(skip, f.Match)
+	}
+	for _, t := range tasks.Parse(md.Path, data) {
+		for _, f := range t.Fields {
+			skip = append(skip, f.Match)
+		}
+	}
+	for _, re := range []*regexp.Regexp{urlRegex, htmlRegex, mathRegex} {
+		for _, m := range re.FindAllIndex(data, -1) {
+			skip = append(skip, api.Match{Begin: m[0], End: m[1]})
+		}
+	}
+
+	lines := metadata.NewLineIndex(data)
+	linked := make(map[string]bool)
+
+	for _, m := range *matches {
+		if overlaps(skip, m) || !isWordBoundary(data, m) {
+			continue
+		}
+
+		text := string(data[m.Begin:m.End])
+		target, ok := l.names[l.key(text)]
+		if !ok || target == md.Path {
+			continue
+		}
+		if l.opts.FirstOnly && linked[target] {
+			continue
+		}
+		linked[target] = true
+
+		line, col := lines.Position(m.Begin)
+		mentions = append(mentions, Mention{
+			Target: target,
+			Text:   text,
+			Match:  m,
+			Line:   line,
+			Col:    col,
+		})
+	}
+
+	return mentions, nil
+}
+
+// Gets the link the mention is converted into.
+func (l *Linker) Link(m Mention) string {
+	target := l.resolver.ShortestTarget(m.Target)
+	if target == m.Text {
+		return fmt.Sprintf("[[%s]]", target)
+	}
+	return fmt.Sprintf("[[%s|%s]]", target, m.Text)
+}
+
+// Converts the mentions into wikilinks. The mentions must be found in the same version
+// of the set.
+func (l *Linker) Apply(s api.Set, mentions []Mention) (bool, error) {
+	if len(mentions) == 0 {
+		return false, nil
+	}
+
+	links := make(map[int]string)
+	matches := make([]api.Match, 0, len(mentions))
+	for _, m := range mentions {
+		if m.Match.End > len(*s.Data()) {
+			return false, errors.New("mention is out of the range of the set")
+		}
+		links[m.Match.Begin] = l.Link(m)
+		matches = append(matches, m.Match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Begin < matches[j].Begin
+	})
+
+	return s.Replace(&matches, func(md api.Match, _ api.Data) ([]byte, bool) {
+		link, ok := links[md.Begin]
+		return []byte(link), ok
+	})
+}
+
+func overlaps(mm []api.Match, m api.Match) bool {
+	for _, o := range mm {
+		if m.Begin < o.End && o.Begin < m.End {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+// Checks that the match is not a part of a longer word.
+func isWordBoundary(data []byte, m api.Match) bool {
+	if m.Begin > 0 {
+		if r, _ := utf8.DecodeLastRune(data[:m.Begin]); isWordRune(r) {
+			return false
+		}
+	}
+	if m.End < len(data) {
+		if r, _ := utf8.DecodeRune(data[m.End:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
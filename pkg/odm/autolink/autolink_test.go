@@ -0,0 +1,73 @@
+package autolink
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+func TestFind(t *testing.T) {
+	names := []Name{
+		{Text: "Alpha", Path: "Alpha.md"},
+		{Text: "2024-05-01", Path: "Daily/2024-05-01.md"},
+	}
+	l, err := NewLinkerFromNames(names, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"prose", "See Alpha and alpha.\n", []string{"Alpha", "alpha"}},
+		{"frontmatter", "---\ntitle: Alpha\n---\nbody\n", nil},
+		{"heading", "# Alpha\n", nil},
+		{"wikilink", "See [[Alpha]].\n", nil},
+		{"code", "Run `Alpha` now.\n", nil},
+		{"tag", "Tagged #Alpha here.\n", nil},
+		{"nested tag", "Tagged #project/Alpha here.\n", nil},
+		{"url", "Go to https://example.com/Alpha/page now.\n", nil},
+		{"autolink", "Go to <https://example.com/Alpha> now.\n", nil},
+		{"www", "Go to www.Alpha.com now.\n", nil},
+		{"html", "<span title=\"Alpha\">text</span>\n", nil},
+		{"inline math", "The $Alpha + 1$ value.\n", nil},
+		{"block math", "$$\nAlpha = 2\n$$\n", nil},
+		{"task date", "- [ ] call 📅 2024-05-01\n", nil},
+		{"task done date", "- [x] call ✅ 2024-05-01\n", nil},
+		{"line field", "status:: Alpha\n", nil},
+		{"bracket field", "Read [project:: Alpha] today.\n", nil},
+		{"paren field", "Read (project:: Alpha) today.\n", nil},
+		{"prose around skipped text", "Alpha #Alpha https://x.io/Alpha Alpha\n", []string{"Alpha", "Alpha"}},
+		{"dollars", "It costs $5 and Alpha $10.\n", []string{"Alpha"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := odm.NewSetFromReader(bytes.NewBufferString(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			md := metadata.Parse("Note.md", *s.Data())
+			mentions, err := l.Find(s, md)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(mentions))
+			for _, m := range mentions {
+				got = append(got, m.Text)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The name of the cache file under the '.odm' folder.
+const CACHE_FILE_NAME = "cache.gob"
+
+// Bump this when the layout of the metadata changes, old caches are then discarded.
+const CACHE_VERSION = 2
+
+type Hash [sha256.Size]byte
+
+// The cached metadata of a single note.
+type Entry struct {
+	Size     int64
+	ModTime  time.Time
+	Hash     Hash
+	Metadata metadata.Metadata
+}
+
+// This is what is written down to the disk.
+type snapshot struct {
+	Version int
+	Entries map[string]Entry
+}
+
+// The persistent metadata cache. Entries are keyed by the path relative to the vault.
+// It is not safe for concurrent use.
+type Cache struct {
+	file    *file.File
+	entries map[string]Entry
+	seen    map[string]bool
+	dirty   bool
+}
+
+// Gets the hash of the data.
+func HashOf(data []byte) Hash {
+	return sha256.Sum256(data)
+}
+
+// Loads the cache from the given file. If the file doesn't exist or is from an older
+// version, an empty cache is returned.
+func Load(f *file.File) (*Cache, error) {
+	c := &Cache{
+		file:    f,
+		entries: make(map[string]Entry),
+		seen:    make(map[string]bool),
+	}
+
+	data, err := f.ReadAll()
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := snapshot{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil || s.Version != CACHE_VERSION {
+		// A corrupt or old cache is not an error, it will be rebuilt.
+		c.dirty = true
+		return c, nil
+	}
+
+	if s.Entries != nil {
+		c.entries = s.Entries
+	}
+	return c, nil
+}
+
+// Gets the metadata if the size and the modification time matches, without looking at
+// the contents.
+func (c *Cache) Stat(path string, size int64, modTime time.Time) (*metadata.Metadata, bool) {
+	c.seen[path] = true
+	if e, ok := c.entries[path]; ok && e.Size == size && e.ModTime.Equal(modTime) {
+		return &e.Metadata, true
+	}
+	return nil, false
+}
+
+// Gets the metadata if the hash matches. The size and the modification time of the entry
+// are refreshed if they are given, so the next Stat call hits.
+func (c *Cache) Lookup(path string, hash Hash, size int64, modTime time.Time) (*metadata.Metadata, bool) {
+	c.seen[path] = true
+	e, ok := c.entries[path]
+	if !ok || e.Hash != hash {
+		return nil, false
+	}
+
+	if !modTime.IsZero() && (e.Size != size || !e.ModTime.Equal(modTime)) {
+		e.Size, e.ModTime = size, modTime
+		c.entries[path] = e
+		c.dirty = true
+	}
+	return &e.Metadata, true
+}
+
+// Stores the metadata of the note.
+func (c *Cache) Put(path string, hash Hash, size int64, modTime time.Time, md *metadata.Metadata) {
+	c.seen[path] = true
+	c.entries[path] = Entry{
+		Size:     size,
+		ModTime:  modTime,
+		Hash:     hash,
+		Metadata: *md,
+	}
+	c.dirty = true
+}
+
+// Removes the entry of the note.
+func (c *Cache) Delete(path string) {
+	if _, ok := c.entries[path]; ok {
+		delete(c.entries, path)
+		c.dirty = true
+	}
+}
+
+// Removes the entries which are not accessed since the cache is loaded. Call this after
+// visiting every note, so the deleted notes are not kept around. Returns how many
+// entries are removed.
+func (c *Cache) Prune() int {
+	n := 0
+	for path := range c.entries {
+		if !c.seen[path] {
+			delete(c.entries, path)
+			n += 1
+		}
+	}
+	if n > 0 {
+		c.dirty = true
+	}
+	return n
+}
+
+// Returns the number of entries.
+func (c *Cache) Len() int {
+	return len(c.entries)
+}
+
+// Writes the cache down to the disk if it is changed. The parent folder is created if
+// it doesn't exist. The file is replaced atomically so a crash can't corrupt it.
+func (c *Cache) Save() error {
+	if !c.dirty {
+		return nil
+	}
+
+	if parent, err := c.file.Parent(); err != nil {
+		return err
+	} else if _, err := parent.Create(); err != nil {
+		return err
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := gob.NewEncoder(buffer).Encode(snapshot{Version: CACHE_VERSION, Entries: c.entries}); err != nil {
+		return err
+	}
+
+	tmp := c.file.String() + ".tmp"
+	if err := os.WriteFile(tmp, buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.file.String()); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
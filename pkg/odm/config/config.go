@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/lint"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// The name of the global configuration file under the home folder.
+const HOME_FILE_NAME = ".obsidian-document-manager.yaml"
+
+// The name of the vault configuration file under the '.odm' folder.
+const VAULT_FILE_NAME = "config"
+
+// The prefix of the environment variables, e.g. ODM_LINK_STYLE.
+const ENV_PREFIX = "ODM_"
+
+// Where a setting comes from, in the order of precedence.
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_HOME    = "home"
+	SOURCE_PROFILE = "profile"
+	SOURCE_VAULT   = "vault"
+	SOURCE_ENV     = "env"
+)
+
+// The effective settings.
+type Settings struct {
+	// The path of the vault.
+	Vault string `yaml:"vault,omitempty"`
+
+	// Glob patterns of the files which are not loaded as notes, e.g. 'Templates/**'.
+	Ignore []string `yaml:"ignore,omitempty"`
+
+	// The style of the new links, either 'wikilink' or 'markdown'.
+	LinkStyle string `yaml:"link_style,omitempty"`
+
+	// How the new links refer to the notes, 'shortest', 'relative' or 'absolute'.
+	LinkPath string `yaml:"link_path,omitempty"`
+
+	// The format of the dates, as moment.js format strings like Obsidian uses.
+	DateFormat string `yaml:"date_format,omitempty"`
+	TimeFormat string `yaml:"time_format,omitempty"`
+
+	// The folder the attachments are kept in.
+	AttachmentFolder string `yaml:"attachment_folder,omitempty"`
+
+	// The folder the templates of the new notes are in.
+	TemplateFolder string `yaml:"template_folder,omitempty"`
+
+	// The lint rules, with the overrides for the folders.
+	Lint *lint.Config `yaml:"lint,omitempty"`
+
+	// The schemas of the frontmatter by their names.
+	Schemas map[string]*schema.Schema `yaml:"schemas,omitempty"`
+}
+
+// The keys of the settings, which can also be set from the environment.
+var Keys = []string{"vault", "ignore", "link_style", "link_path", "date_format", "time_format", "attachment_folder", "template_folder", "lint", "schemas"}
+
+func defaults() map[string]any {
+	return map[string]any{
+		"vault":             ".",
+		"ignore":            []any{},
+		"link_style":        "wikilink",
+		"link_path":         "shortest",
+		"date_format":       "YYYY-MM-DD",
+		"time_format":       "HH:mm",
+		"attachment_folder": "",
+		"template_folder":   "",
+	}
+}
+
+// The loaded configuration, the merged settings with where each of them comes from.
+type Config struct {
+	Settings Settings
+
+	// The name of the profile in use, empty if none.
+	Profile string
+
+	values  map[string]any
+	sources map[string]string
+}
+
+// Where to look for the configuration.
+type Options struct {
+	// The global configuration file, the one in the home folder by default.
+	HomeFile string
+
+	// The profile to use, the 'profile' key of the global configuration by default.
+	Profile string
+
+	// The vault path given on the command line, it wins over everything.
+	Vault string
+
+	// The environment, os.Environ() by default.
+	Environ []string
+}
+
+// Gets the path of the global configuration file.
+func DefaultHomeFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, HOME_FILE_NAME), nil
+}
+
+// Gets the path of the configuration file of the vault.
+func VaultFile(vaultPath string) string {
+	return filepath.Join(expandHome(vaultPath), ".odm", VAULT_FILE_NAME)
+}
+
+// Loads the configuration. The global file is read first, then the profile in it, then
+// the file of the vault and then the environment variables; the later ones win.
+func Load(opts Options) (*Config, error) {
+	if opts.Environ == nil {
+		opts.Environ = os.Environ()
+	}
+	env := environ(opts.Environ)
+
+	if opts.HomeFile == "" {
+		opts.HomeFile = env["CONFIG"]
+	}
+	if opts.HomeFile == "" {
+		if f, err := DefaultHomeFile(); err == nil {
+			opts.HomeFile = f
+		} else {
+			return nil, err
+		}
+	}
+
+	c := &Config{values: make(map[string]any), sources: make(map[string]string)}
+	merge(c.values, defaults(), c.sources, SOURCE_DEFAULT, "")
+
+	home, err := readFile(opts.HomeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, _ := home["profiles"].(map[string]any)
+	c.Profile = opts.Profile
+	if c.Profile == "" {
+		c.Profile = env["PROFILE"]
+	}
+	if c.Profile == "" {
+		c.Profile, _ = home["profile"].(string)
+	}
+	delete(home, "profiles")
+	delete(home, "profile")
+	merge(c.values, home, c.sources, SOURCE_HOME, "")
+
+	if c.Profile != "" {
+		profile, ok := profiles[c.Profile].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' is not defined", c.Profile)
+		}
+		merge(c.values, profile, c.sources, SOURCE_PROFILE, "")
+	}
+
+	// The vault must be known before reading its configuration.
+	vaultPath, _ := c.values["vault"].(string)
+	if v, ok := env["VAULT"]; ok {
+		vaultPath = v
+	}
+	if opts.Vault != "" {
+		vaultPath = opts.Vault
+	}
+
+	vaultValues, err := readFile(VaultFile(vaultPath))
+	if err != nil {
+		return nil, err
+	}
+	delete(vaultValues, "vault")
+	merge(c.values, vaultValues, c.sources, SOURCE_VAULT, "")
+
+	envValues := make(map[string]any)
+	for _, key := range Keys {
+		raw, ok := env[strings.ToUpper(key)]
+		if !ok {
+			continue
+		}
+		var value any
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+			value = raw
+		}
+		if key == "ignore" {
+			if s, ok := value.(string); ok {
+				value = splitList(s)
+			}
+		}
+		envValues[key] = value
+	}
+	merge(c.values, envValues, c.sources, SOURCE_ENV, "")
+
+	c.values["vault"] = expandHome(vaultPath)
+	if opts.Vault != "" {
+		c.sources["vault"] = "flag"
+	} else if _, ok := env["VAULT"]; ok {
+		c.sources["vault"] = SOURCE_ENV
+	}
+
+	if err := decode(c.values, &c.Settings); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Gets the effective value of the dotted key, e.g. 'lint.required'.
+func (c *Config) Get(key string) (any, bool) {
+	var current any = c.values
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Gets where the effective value of the dotted key comes from, one of the SOURCE_*
+// constants, or empty if there is no such key.
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// A single setting with where it comes from.
+type Entry struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// Gets every effective setting, flattened to dotted keys and sorted.
+func (c *Config) List() []Entry {
+	entries := make([]Entry, 0)
+	flatten(c.values, "", func(key string, value any) {
+		entries = append(entries, Entry{Key: key, Value: value, Source: c.sources[key]})
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+func flatten(m map[string]any, prefix string, fn func(string, any)) {
+	for key, value := range m {
+		if sub, ok := value.(map[string]any); ok && len(sub) > 0 {
+			flatten(sub, prefix+key+".", fn)
+			continue
+		}
+		fn(prefix+key, value)
+	}
+}
+
+// Merges the source into the destination. Maps are merged recursively, anything else
+// is replaced. The source of each replaced key is recorded.
+func merge(dst, src map[string]any, sources map[string]string, source, prefix string) {
+	for key, value := range src {
+		if sub, ok := value.(map[string]any); ok {
+			if existing, ok := dst[key].(map[string]any); ok {
+				merge(existing, sub, sources, source, prefix+key+".")
+				continue
+			}
+			copied := make(map[string]any)
+			merge(copied, sub, sources, source, prefix+key+".")
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+		sources[prefix+key] = source
+	}
+}
+
+// Reads a YAML file into a map, a missing file is an empty map.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]any), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Decodes the generic values into the settings through YAML.
+func decode(values map[string]any, settings *Settings) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, settings)
+}
+
+// Gets the variables with the prefix, without the prefix.
+func environ(env []string) map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 && strings.HasPrefix(kv, ENV_PREFIX) {
+			vars[kv[len(ENV_PREFIX):i]] = kv[i+1:]
+		}
+	}
+	return vars
+}
+
+func splitList(s string) []any {
+	list := make([]any, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Replaces the leading '~' with the home folder.
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sets the dotted key in the YAML file to the value. The value is parsed as YAML, so
+// '[a, b]' is a list and 'true' is a boolean. The rest of the file, including the
+// comments, is kept. The file and its folder are created if they don't exist.
+func Set(path, key, value string) error {
+	var parsed yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return err
+	}
+	var valueNode *yaml.Node
+	if len(parsed.Content) > 0 {
+		valueNode = parsed.Content[0]
+	} else {
+		valueNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}
+	}
+
+	return edit(path, func(root *yaml.Node) error {
+		parent, last, err := walk(root, key, true)
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == last {
+				parent.Content[i+1] = valueNode
+				return nil
+			}
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: last}, valueNode)
+		return nil
+	})
+}
+
+// Removes the dotted key from the YAML file. Returns false if it is not there.
+func Unset(path, key string) (bool, error) {
+	removed := false
+	err := edit(path, func(root *yaml.Node) error {
+		parent, last, err := walk(root, key, false)
+		if err != nil || parent == nil {
+			return err
+		}
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == last {
+				parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+				removed = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Finds the mapping holding the last part of the key. The missing mappings are created
+// if asked for, otherwise a nil mapping is returned.
+func walk(root *yaml.Node, key string, create bool) (*yaml.Node, string, error) {
+	parts := strings.Split(key, ".")
+	current := root
+	for _, part := range parts[:len(parts)-1] {
+		var next *yaml.Node
+		for i := 0; i+1 < len(current.Content); i += 2 {
+			if current.Content[i].Value == part {
+				next = current.Content[i+1]
+			}
+		}
+		if next == nil {
+			if !create {
+				return nil, "", nil
+			}
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			current.Content = append(current.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, next)
+		}
+		if next.Kind != yaml.MappingNode {
+			return nil, "", fmt.Errorf("'%s' is not a mapping", part)
+		}
+		current = next
+	}
+	return current, parts[len(parts)-1], nil
+}
+
+// Reads the YAML file, applies the change on its root mapping and writes it back.
+func edit(path string, change func(root *yaml.Node) error) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: the root is not a mapping", path)
+	}
+
+	if err := change(root); err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,198 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The default number of unchanged lines shown around the changes.
+const DEFAULT_CONTEXT int = 3
+
+// The kinds of the line operations.
+const (
+	OP_EQUAL  int = 0
+	OP_DELETE int = 1
+	OP_INSERT int = 2
+)
+
+// A single line of the edit script.
+type Op struct {
+	Kind int
+	Text string
+
+	// The 0 based line numbers in the old and the new text.
+	Old int
+	New int
+}
+
+// Splits the data into lines, keeping the new lines.
+func Lines(data []byte) []string {
+	lines := make([]string, 0)
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// Computes the shortest edit script between the lines with the Myers algorithm.
+func Compute(a, b []string) []Op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	trace := make([][]int, 0)
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// Walks the trace back from the end and builds the edit script.
+func backtrack(a, b []string, trace [][]int, d, offset int) []Op {
+	ops := make([]Op, 0, len(a)+len(b))
+	x, y := len(a), len(b)
+
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		if d == 0 {
+			prevX, prevY = 0, 0
+		}
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, Op{Kind: OP_EQUAL, Text: a[x], Old: x, New: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y -= 1
+				ops = append(ops, Op{Kind: OP_INSERT, Text: b[y], Old: x, New: y})
+			} else {
+				x -= 1
+				ops = append(ops, Op{Kind: OP_DELETE, Text: a[x], Old: x, New: y})
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Renders the unified diff of the two texts, empty if they are the same.
+func Unified(oldName, newName string, a, b []byte, context int) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	ops := Compute(Lines(a), Lines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == OP_EQUAL {
+			i++
+			continue
+		}
+
+		// Extend the hunk while the changes are close enough.
+		begin := i - context
+		if begin < 0 {
+			begin = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != OP_EQUAL {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		writeHunk(&out, ops[begin:end])
+		i = end
+	}
+	return out.String()
+}
+
+// Writes a hunk with its header.
+func writeHunk(out *strings.Builder, ops []Op) {
+	oldStart, newStart := ops[0].Old, ops[0].New
+	oldLines, newLines := 0, 0
+	for _, op := range ops {
+		if op.Kind != OP_INSERT {
+			oldLines++
+		}
+		if op.Kind != OP_DELETE {
+			newLines++
+		}
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLines), hunkRange(newStart, newLines))
+	for _, op := range ops {
+		prefix := " "
+		switch op.Kind {
+		case OP_DELETE:
+			prefix = "-"
+		case OP_INSERT:
+			prefix = "+"
+		}
+		out.WriteString(prefix + op.Text)
+		if !strings.HasSuffix(op.Text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// Formats the range of a hunk, the lines are 1 based and empty ranges point to the
+// line before.
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if lines == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, lines)
+}
@@ -0,0 +1,297 @@
+package fields
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"gopkg.in/yaml.v3"
+)
+
+// The operations on the inline fields.
+const (
+	OP_SET            string = "set"
+	OP_UNSET          string = "unset"
+	OP_TO_FRONTMATTER string = "to-frontmatter"
+	OP_TO_INLINE      string = "to-inline"
+)
+
+// An operation on the inline fields of the notes.
+type Operation struct {
+	// One of the OP_* constants.
+	Op string
+
+	// The keys of the fields, or of the properties for to-inline. The migration to the
+	// frontmatter moves all of the fields if there are none.
+	Keys []string
+
+	// The value of set.
+	Value string
+}
+
+// Runs the operation on the note. Returns true if the note has changed.
+func (op Operation) Apply(s api.Set) (bool, error) {
+	changed := false
+	switch op.Op {
+	case OP_SET, OP_UNSET:
+		for _, key := range op.Keys {
+			var ok bool
+			var err error
+			if op.Op == OP_SET {
+				ok, err = Set(s, key, op.Value)
+			} else {
+				ok, err = Delete(s, key)
+			}
+			if err != nil {
+				return changed, err
+			}
+			changed = changed || ok
+		}
+		return changed, nil
+	case OP_TO_FRONTMATTER:
+		return ToFrontmatter(s, op.Keys...)
+	case OP_TO_INLINE:
+		return ToInline(s, op.Keys...)
+	}
+	return false, fmt.Errorf("unknown operation '%s'", op.Op)
+}
+
+// Gets the inline fields of the note with the key. The keys are compared the way
+// Dataview does, so 'Due Date' is also 'due-date'.
+func Get(data []byte, key string) []metadata.InlineField {
+	found := make([]metadata.InlineField, 0)
+	for _, f := range metadata.ParseInlineFields(data) {
+		if metadata.CanonicalKey(f.Key) == metadata.CanonicalKey(key) {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// Sets the value of every field with the key, only the values are rewritten. If the
+// note has none, a 'key:: value' line is appended to it. Returns true if the note has
+// changed.
+func Set(s api.Set, key, value string) (bool, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return false, fmt.Errorf("the value of '%s' can't have new lines", key)
+	}
+	found := Get(*s.Data(), key)
+	if len(found) == 0 {
+		return appendLines(s, []string{key + ":: " + value})
+	}
+	return update(s, found, value)
+}
+
+// Sets the value of the field, only its value is rewritten. The field must be the
+// same as when it is parsed.
+func SetField(s api.Set, f metadata.InlineField, value string) (bool, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return false, fmt.Errorf("the value of '%s' can't have new lines", f.Key)
+	}
+	if err := check(s, f); err != nil {
+		return false, err
+	}
+	return update(s, []metadata.InlineField{f}, value)
+}
+
+func update(s api.Set, fields []metadata.InlineField, value string) (bool, error) {
+	data := *s.Data()
+	mm := make([]api.Match, 0, len(fields))
+	for _, f := range fields {
+		if f.Value != value {
+			mm = append(mm, f.ValueMatch)
+		}
+	}
+	if len(mm) == 0 {
+		return false, nil
+	}
+	return s.Replace(&mm, func(m api.Match, _ api.Data) ([]byte, bool) {
+		// An empty value of 'key::' needs the space after the colons.
+		if m.Begin == m.End && m.Begin > 0 && data[m.Begin-1] == ':' {
+			return []byte(" " + value), true
+		}
+		return []byte(value), true
+	})
+}
+
+// Removes every field with the key. The lines of the 'key:: value' fields are
+// removed, the other fields are cut out of their lines. Returns true if the note has
+// changed.
+func Delete(s api.Set, key string) (bool, error) {
+	return remove(s, Get(*s.Data(), key))
+}
+
+// Removes the field, which must be the same as when it is parsed.
+func DeleteField(s api.Set, f metadata.InlineField) (bool, error) {
+	if err := check(s, f); err != nil {
+		return false, err
+	}
+	return remove(s, []metadata.InlineField{f})
+}
+
+func remove(s api.Set, fields []metadata.InlineField) (bool, error) {
+	if len(fields) == 0 {
+		return false, nil
+	}
+	data := *s.Data()
+	mm := make([]api.Match, 0, len(fields))
+	for _, f := range fields {
+		m := f.Match
+		if f.Syntax == metadata.FieldLine {
+			// The whole line, with its list marker.
+			m.Begin = bytes.LastIndexByte(data[:m.Begin], '\n') + 1
+			if i := bytes.IndexByte(data[m.End:], '\n'); i >= 0 {
+				m.End += i + 1
+			} else {
+				m.End = len(data)
+			}
+		} else if m.Begin > 0 && data[m.Begin-1] == ' ' {
+			m.Begin--
+		} else if m.End < len(data) && data[m.End] == ' ' {
+			m.End++
+		}
+		// The fields in the same line are removed with the line.
+		if n := len(mm); n > 0 && m.Begin < mm[n-1].End {
+			mm[n-1].End = max(mm[n-1].End, m.End)
+			continue
+		}
+		mm = append(mm, m)
+	}
+	return s.Remove(&mm)
+}
+
+// Moves the fields with the keys, or all of them, into the frontmatter. The fields
+// given more than once become lists. The fields of the tasks are left where they are
+// since they belong to the tasks. It is an error if the frontmatter already has the
+// property. Returns true if the note has changed.
+func ToFrontmatter(s api.Set, keys ...string) (bool, error) {
+	data := *s.Data()
+	fm := metadata.ParseFrontmatter(data)
+	taskLines := make([]api.Match, 0)
+	for _, t := range tasks.Parse("", data) {
+		taskLines = append(taskLines, t.Match)
+	}
+
+	moved := make([]metadata.InlineField, 0)
+	order := make([]string, 0)
+	values := make(map[string][]*yaml.Node)
+	for _, f := range metadata.ParseInlineFields(data) {
+		if len(keys) > 0 && !hasKey(keys, f.Key) || metadata.Inside(taskLines, f.Match.Begin) {
+			continue
+		}
+		if _, ok := fm.Get(f.Key); ok {
+			return false, fmt.Errorf("'%s' is already in the frontmatter", f.Key)
+		}
+		if _, ok := values[f.Key]; !ok {
+			order = append(order, f.Key)
+		}
+		values[f.Key] = append(values[f.Key], node(f.Value))
+		moved = append(moved, f)
+	}
+	if len(moved) == 0 {
+		return false, nil
+	}
+
+	// The fields are removed first, the frontmatter doesn't move them.
+	if _, err := remove(s, moved); err != nil {
+		return false, err
+	}
+	for _, key := range order {
+		var value any = values[key][0]
+		if len(values[key]) > 1 {
+			value = &yaml.Node{Kind: yaml.SequenceNode, Content: values[key]}
+		}
+		if _, err := frontmatter.Set(s, key, value); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Moves the frontmatter properties with the keys into 'key:: value' lines after the
+// frontmatter. The lists are written with commas between their items, and a
+// frontmatter left without properties is removed. Returns true if the note has
+// changed.
+func ToInline(s api.Set, keys ...string) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	lines := make([]string, 0, len(keys))
+	moved := make([]string, 0, len(keys))
+	for _, p := range fm.Properties {
+		if !hasKey(keys, p.Key) {
+			continue
+		}
+		switch p.Kind {
+		case metadata.PropertyNested:
+			return false, fmt.Errorf("'%s' is nested, it can't be an inline field", p.Key)
+		case metadata.PropertyList:
+			lines = append(lines, p.Key+":: "+strings.Join(p.Items, ", "))
+		default:
+			lines = append(lines, strings.TrimSuffix(p.Key+":: "+p.Value, " "))
+		}
+		moved = append(moved, p.Key)
+	}
+	if len(moved) == 0 {
+		return false, nil
+	}
+
+	for _, key := range moved {
+		if _, err := frontmatter.Unset(s, key); err != nil {
+			return false, err
+		}
+	}
+	fm = metadata.ParseFrontmatter(*s.Data())
+	at := api.Match{Begin: fm.Match.End, End: fm.Match.End}
+	text := strings.Join(lines, "\n") + "\n"
+	if len(fm.Properties) == 0 && len(bytes.TrimSpace((*s.Data())[fm.Body.Begin:fm.Body.End])) == 0 {
+		// The empty frontmatter is replaced by the fields.
+		at.Begin = fm.Match.Begin
+	}
+	return s.Replace(&[]api.Match{at}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(text), true
+	})
+}
+
+// Appends the lines to the end of the note.
+func appendLines(s api.Set, lines []string) (bool, error) {
+	data := *s.Data()
+	text := strings.Join(lines, "\n") + "\n"
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		text = "\n" + text
+	}
+	at := api.Match{Begin: len(data), End: len(data)}
+	return s.InsertBefore(&[]api.Match{at}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(text), true
+	})
+}
+
+// Checks that the field is still in the note the way it is parsed.
+func check(s api.Set, f metadata.InlineField) error {
+	data := *s.Data()
+	if f.Match.End > len(data) || string(data[f.ValueMatch.Begin:f.ValueMatch.End]) != f.Value {
+		return fmt.Errorf("the field '%s' on line %d has changed since it is read", f.Key, f.Line)
+	}
+	return nil
+}
+
+// Converts the value of a field to a YAML value. The values which YAML reads as
+// something else than they are written, like the links, stay strings.
+func node(value string) *yaml.Node {
+	if n, err := frontmatter.ParseValue(value); err == nil && n.Kind == yaml.ScalarNode && n.Style == 0 && n.Value == value {
+		return frontmatter.Plain(value)
+	}
+	n, _ := frontmatter.Node(value)
+	return n
+}
+
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if metadata.CanonicalKey(k) == metadata.CanonicalKey(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -141,7 +141,7 @@ func (f *File) SetPermissions(rwx int32) (bool, error) {
 }
 
 func (f *File) Close() error {
-	if f.IsOpen() {
+	if !f.IsOpen() {
 		return errors.New("file is not opened")
 	} else {
 		if err := f.file.Close(); err != nil {
@@ -169,14 +169,80 @@ func (f *File) Write(p []byte) (n int, err error) {
 	return f.file.Write(p)
 }
 
+// Most filesystems don't expose the birth time portably, so the modification time is
+// used as the best effort creation time.
 func (f *File) Created() (time.Time, error) {
-	return time.Time{}, nil
+	return f.ModTime()
 }
 
 func (f *File) Updated() (time.Time, error) {
-	return time.Time{}, nil
+	return f.ModTime()
+}
+
+// Gets the modification time of the file on the disk.
+func (f *File) ModTime() (time.Time, error) {
+	if info, err := os.Stat(f.absPath); err == nil {
+		return info.ModTime(), nil
+	} else {
+		return time.Time{}, err
+	}
+}
+
+// Gets the size of the file on the disk in bytes.
+func (f *File) Size() (int64, error) {
+	if info, err := os.Stat(f.absPath); err == nil {
+		return info.Size(), nil
+	} else {
+		return 0, err
+	}
+}
+
+// Reads the whole file without opening it. This does not create the file.
+func (f *File) ReadAll() ([]byte, error) {
+	return os.ReadFile(f.absPath)
+}
+
+// Writes the whole file, truncating the previous contents. Creates the file if it
+// doesn't exist, but not the parent folders.
+func (f *File) WriteAll(data []byte) error {
+	return os.WriteFile(f.absPath, data, fs.FileMode(DEFAULT_FILE_PERM))
 }
 
 func (f *File) UpdateTimestamp(t time.Time) error {
 	return nil
 }
+
+// Moves the file to the given path, creating the missing parent folders. Fails if there
+// is already a file at the destination. The file must not be open.
+func (f *File) Rename(dest *File) error {
+	if f.IsOpen() {
+		return errors.New("file is open")
+	}
+
+	if ok, err := dest.Exists(); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("'%s' already exists", dest.String())
+	}
+
+	if parent, err := dest.Parent(); err != nil {
+		return err
+	} else if _, err := parent.Create(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.absPath, dest.absPath); err != nil {
+		return err
+	}
+
+	f.absPath = dest.absPath
+	return nil
+}
+
+// Removes the file from the disk permanently.
+func (f *File) Remove() error {
+	if f.IsOpen() {
+		return errors.New("file is open")
+	}
+	return os.Remove(f.absPath)
+}
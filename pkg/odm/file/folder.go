@@ -95,3 +95,54 @@ func (f *Folder) Create() (bool, error) {
 		return false, err
 	}
 }
+
+// Gets the folder under this folder with the given relative path.
+func (f *Folder) Folder(rel string) (*Folder, error) {
+	return NewFolder(filepath.Join(f.absPath, rel))
+}
+
+// Gets the file under this folder with the given relative path.
+func (f *Folder) File(rel string) (*File, error) {
+	return NewFile(filepath.Join(f.absPath, rel))
+}
+
+// Gets the path of the given file relative to this folder. Uses forward slashes
+// regardless of the platform, since that's how the notes refer to each other.
+func (f *Folder) Rel(file *File) (string, error) {
+	if rel, err := filepath.Rel(f.absPath, file.String()); err == nil {
+		return filepath.ToSlash(rel), nil
+	} else {
+		return "", err
+	}
+}
+
+// This is called for each file found by Walk.
+type WalkCallback func(f *File) error
+
+// Walks over every file under this folder recursively. Hidden files and folders, the
+// ones starting with a '.', are skipped. Files are visited in lexical order.
+func (f *Folder) Walk(fn WalkCallback) error {
+	return filepath.WalkDir(f.absPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the hidden entries but not the root itself.
+		if path != f.absPath && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if file, err := NewFile(path); err == nil {
+			return fn(file)
+		} else {
+			return err
+		}
+	})
+}
@@ -0,0 +1,386 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The names of the rules, used to enable or disable them.
+const (
+	RULE_HEADINGS   = "headings"
+	RULE_LISTS      = "lists"
+	RULE_EMPHASIS   = "emphasis"
+	RULE_BLANKLINES = "blank-lines"
+	RULE_TABLES     = "tables"
+	RULE_TRAILING   = "trailing-whitespace"
+	RULE_NEWLINE    = "final-newline"
+)
+
+// Every rule, in the order they are applied.
+var RuleNames = []string{RULE_HEADINGS, RULE_LISTS, RULE_EMPHASIS, RULE_BLANKLINES, RULE_TABLES, RULE_TRAILING, RULE_NEWLINE}
+
+type Options struct {
+	// Disables the rules by their names.
+	Disabled map[string]bool
+
+	// The marker of the bullet lists, one of '-', '*' or '+'.
+	BulletMarker string
+
+	// The indentation of each nesting level of the lists.
+	ListIndent string
+
+	// The emphasis marker, either '*' or '_'.
+	EmphasisMarker string
+
+	// The strong emphasis marker, either '**' or '__'.
+	StrongMarker string
+}
+
+// The defaults follow the defaults of Obsidian.
+func NewDefaultOptions() Options {
+	return Options{
+		Disabled:       make(map[string]bool),
+		BulletMarker:   "-",
+		ListIndent:     "\t",
+		EmphasisMarker: "*",
+		StrongMarker:   "**",
+	}
+}
+
+// Checks the markers and the names of the disabled rules.
+func (o Options) Check() error {
+	for rule := range o.Disabled {
+		if !contains(RuleNames, rule) {
+			return fmt.Errorf("unknown rule '%s', use one of %s", rule, strings.Join(RuleNames, ", "))
+		}
+	}
+	for _, marker := range []struct {
+		name   string
+		value  string
+		values []string
+	}{
+		{"bullet marker", o.BulletMarker, []string{"-", "*", "+"}},
+		{"emphasis marker", o.EmphasisMarker, []string{"*", "_"}},
+		{"strong marker", o.StrongMarker, []string{"**", "__"}},
+	} {
+		if !contains(marker.values, marker.value) {
+			return fmt.Errorf("unknown %s '%s', use one of %s", marker.name, marker.value, strings.Join(marker.values, ", "))
+		}
+	}
+	if strings.Trim(o.ListIndent, " ") != "" && o.ListIndent != "\t" {
+		return fmt.Errorf("the list indentation must be a tab or spaces")
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) enabled(rule string) bool {
+	return !o.Disabled[rule]
+}
+
+// Formats the set in place. The frontmatter is left as it is. Returns false if the set
+// is already formatted, then it is not modified at all.
+func Format(s api.Set, opts Options) (bool, error) {
+	data := *s.Data()
+	fm := metadata.ParseFrontmatter(data)
+	body := data[fm.Match.End:]
+
+	formatted := FormatBody(body, opts)
+	if bytes.Equal(formatted, body) {
+		return false, nil
+	}
+
+	return s.Replace(&[]api.Match{{Begin: fm.Match.End, End: len(data)}}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return formatted, true
+	})
+}
+
+// Checks if the set is already formatted.
+func IsFormatted(s api.Set, opts Options) bool {
+	data := *s.Data()
+	fm := metadata.ParseFrontmatter(data)
+	body := data[fm.Match.End:]
+	return bytes.Equal(FormatBody(body, opts), body)
+}
+
+const (
+	kindBlank     = 0
+	kindText      = 1
+	kindHeading   = 2
+	kindList      = 3
+	kindQuote     = 4
+	kindTable     = 5
+	kindVerbatim  = 6
+	kindBlockID   = 7
+	kindFenceOpen = 8
+	kindFenceEnd  = 9
+	kindBreak     = 10
+)
+
+type line struct {
+	text string
+	kind int
+}
+
+var (
+	fenceRegex    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	headingRegex  = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	emptyHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]*$`)
+	listRegex     = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])([ \t]+|$)(.*)$`)
+	blockIDRegex  = regexp.MustCompile(`^\^[A-Za-z0-9\-]+[ \t]*$`)
+	breakRegex    = regexp.MustCompile(`^ {0,3}([-*_])[ \t]*(?:[-*_][ \t]*){2,}$`)
+	delimRowRegex = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// Formats the body of a note, the part after the frontmatter.
+func FormatBody(body []byte, opts Options) []byte {
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	lines := classify(strings.Split(text, "\n"))
+
+	if opts.enabled(RULE_TRAILING) {
+		for i := range lines {
+			if lines[i].kind != kindVerbatim && lines[i].kind != kindFenceOpen && lines[i].kind != kindFenceEnd {
+				lines[i].text = trimTrailing(lines, i)
+			}
+		}
+	}
+
+	if opts.enabled(RULE_HEADINGS) {
+		for i := range lines {
+			if lines[i].kind == kindHeading {
+				lines[i].text = formatHeading(lines[i].text)
+			}
+		}
+	}
+
+	if opts.enabled(RULE_LISTS) {
+		formatLists(lines, opts)
+	}
+
+	if opts.enabled(RULE_EMPHASIS) {
+		for i := range lines {
+			switch lines[i].kind {
+			case kindText, kindHeading, kindList:
+				lines[i].text = formatEmphasis(lines[i].text, opts)
+			}
+		}
+	}
+
+	if opts.enabled(RULE_TABLES) {
+		formatTables(lines)
+	}
+
+	if opts.enabled(RULE_BLANKLINES) {
+		lines = formatBlankLines(lines)
+	}
+
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.text
+	}
+	result := strings.Join(out, "\n")
+
+	if opts.enabled(RULE_NEWLINE) {
+		if result = strings.TrimRight(result, "\n"); result != "" {
+			result += "\n"
+		}
+	}
+	return []byte(result)
+}
+
+// Finds the kind of each line. Code blocks and the multi line comments are verbatim,
+// nothing is changed in them.
+func classify(texts []string) []line {
+	lines := make([]line, len(texts))
+
+	var fence string
+	comment := ""
+	for i, t := range texts {
+		lines[i].text = t
+
+		if fence != "" {
+			lines[i].kind = kindVerbatim
+			trimmed := strings.TrimLeft(t, " ")
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "" {
+				lines[i].kind = kindFenceEnd
+				fence = ""
+			}
+			continue
+		}
+
+		if comment != "" {
+			lines[i].kind = kindVerbatim
+			if strings.Count(t, comment)%2 == 1 || (comment == "<!--" && strings.Contains(t, "-->")) {
+				comment = ""
+			}
+			continue
+		}
+
+		if m := fenceRegex.FindStringSubmatch(t); m != nil {
+			lines[i].kind = kindFenceOpen
+			fence = m[1]
+			continue
+		}
+
+		// A comment which is opened but not closed on the same line.
+		if strings.Count(stripInlineCode(t), "%%")%2 == 1 {
+			lines[i].kind = kindVerbatim
+			comment = "%%"
+			continue
+		}
+		if open := strings.LastIndex(t, "<!--"); open >= 0 && !strings.Contains(t[open:], "-->") {
+			lines[i].kind = kindVerbatim
+			comment = "<!--"
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(t) == "":
+			lines[i].kind = kindBlank
+		case blockIDRegex.MatchString(t):
+			lines[i].kind = kindBlockID
+		case strings.HasPrefix(strings.TrimLeft(t, " "), ">"):
+			lines[i].kind = kindQuote
+		case headingRegex.MatchString(t) || emptyHeading.MatchString(t):
+			lines[i].kind = kindHeading
+		case breakRegex.MatchString(t):
+			lines[i].kind = kindBreak
+		case listRegex.MatchString(t):
+			lines[i].kind = kindList
+		default:
+			lines[i].kind = kindText
+		}
+	}
+
+	// Tables are a header row, a delimiter row and the rows until a blank line.
+	for i := 0; i+1 < len(lines); i++ {
+		if lines[i].kind != kindText || !strings.Contains(lines[i].text, "|") || !delimRowRegex.MatchString(lines[i+1].text) {
+			continue
+		}
+		if lines[i+1].kind != kindText && lines[i+1].kind != kindBreak && lines[i+1].kind != kindList {
+			continue
+		}
+		j := i
+		for j < len(lines) && (j <= i+1 || (lines[j].kind == kindText && strings.Contains(lines[j].text, "|"))) {
+			lines[j].kind = kindTable
+			j++
+		}
+		i = j - 1
+	}
+
+	return lines
+}
+
+// Removes the trailing whitespace of the line, the hard line breaks keep two spaces.
+func trimTrailing(lines []line, i int) string {
+	trimmed := strings.TrimRight(lines[i].text, " \t")
+	if hardBreak(lines, i) {
+		return trimmed + "  "
+	}
+	return trimmed
+}
+
+// Checks if the line ends with a hard line break, two spaces before the next line of
+// the paragraph.
+func hardBreak(lines []line, i int) bool {
+	t := lines[i].text
+	if strings.TrimRight(t, " \t") == "" || !strings.HasSuffix(t, "  ") || i+1 == len(lines) {
+		return false
+	}
+	switch lines[i].kind {
+	case kindText, kindList, kindQuote:
+		next := lines[i+1].kind
+		return next == kindText || lines[i].kind == kindQuote && next == kindQuote
+	}
+	return false
+}
+
+// Finds the lines of the body ending with a hard line break. The lines are counted
+// from 0.
+func HardBreaks(body []byte) map[int]bool {
+	breaks := make(map[int]bool)
+	lines := classify(strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n"))
+	for i := range lines {
+		if hardBreak(lines, i) {
+			breaks[i] = true
+		}
+	}
+	return breaks
+}
+
+func formatHeading(t string) string {
+	if m := headingRegex.FindStringSubmatch(t); m != nil {
+		return m[1] + " " + m[2]
+	}
+	if m := emptyHeading.FindStringSubmatch(t); m != nil {
+		return m[1]
+	}
+	return t
+}
+
+// Makes sure there is a single blank line around the headings, code blocks and tables,
+// and there are no consecutive blank lines. Block identifiers stay attached to the
+// block above them.
+func formatBlankLines(lines []line) []line {
+	isBlock := func(kind int) bool {
+		return kind == kindHeading || kind == kindFenceOpen || kind == kindTable || kind == kindBreak
+	}
+	endsBlock := func(kind int) bool {
+		return kind == kindHeading || kind == kindFenceEnd || kind == kindTable || kind == kindBreak
+	}
+
+	out := make([]line, 0, len(lines))
+	for _, l := range lines {
+		if l.kind == kindBlank {
+			// Drop the leading and the consecutive blank lines.
+			if len(out) == 0 || out[len(out)-1].kind == kindBlank {
+				continue
+			}
+			out = append(out, l)
+			continue
+		}
+
+		if len(out) > 0 {
+			prev := out[len(out)-1]
+			needBlank := false
+			if isBlock(l.kind) && prev.kind != kindBlank && !(l.kind == kindTable && prev.kind == kindTable) {
+				needBlank = true
+			}
+			if endsBlock(prev.kind) && l.kind != kindBlockID && !(l.kind == kindTable && prev.kind == kindTable) {
+				needBlank = true
+			}
+			if needBlank && prev.kind != kindBlank {
+				out = append(out, line{kind: kindBlank})
+			}
+		}
+		out = append(out, l)
+	}
+
+	// Drop the trailing blank lines, the final new line is handled separately.
+	for len(out) > 0 && out[len(out)-1].kind == kindBlank {
+		out = out[:len(out)-1]
+	}
+	if len(lines) > 0 && lines[len(lines)-1].kind == kindBlank {
+		out = append(out, line{kind: kindBlank})
+	}
+	return out
+}
+
+func stripInlineCode(t string) string {
+	return inlineCodeRegex.ReplaceAllString(t, "")
+}
+
+var inlineCodeRegex = regexp.MustCompile("`+[^`]*`+")
@@ -0,0 +1,249 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Re-indents the lists so each nesting level is indented with the configured unit, and
+// replaces the bullet markers. The nesting is found from the original indentation.
+func formatLists(lines []line, opts Options) {
+	stack := make([]int, 0)
+
+	for i := range lines {
+		switch lines[i].kind {
+		case kindList:
+		case kindBlank:
+			continue
+		case kindText:
+			// An indented text line continues the list, otherwise the list is over.
+			if strings.HasPrefix(lines[i].text, " ") || strings.HasPrefix(lines[i].text, "\t") {
+				continue
+			}
+			stack = stack[:0]
+			continue
+		default:
+			stack = stack[:0]
+			continue
+		}
+
+		m := listRegex.FindStringSubmatch(lines[i].text)
+		column := width(m[1])
+		for len(stack) > 0 && stack[len(stack)-1] > column {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 || stack[len(stack)-1] < column {
+			stack = append(stack, column)
+		}
+
+		marker := m[2]
+		if marker == "-" || marker == "*" || marker == "+" {
+			marker = opts.BulletMarker
+		}
+
+		space := " "
+		if m[3] == "" {
+			space = ""
+		}
+		lines[i].text = strings.Repeat(opts.ListIndent, len(stack)-1) + marker + space + m[4]
+	}
+}
+
+// The width of the indentation, tabs count as four columns.
+func width(indent string) int {
+	w := 0
+	for _, c := range indent {
+		if c == '\t' {
+			w += 4 - w%4
+		} else {
+			w += 1
+		}
+	}
+	return w
+}
+
+var (
+	// The parts of a line where the emphasis markers must not be touched.
+	protectedRegex = regexp.MustCompile("`+[^`]*`+|\\[\\[[^\\]]*\\]\\]|\\]\\([^)]*\\)|<[^>\\s][^>]*>|https?://\\S+|%%.*?%%|\\$[^$\\s][^$]*\\$|\\\\.")
+
+	strongUnderscore = regexp.MustCompile(`(^|[^\p{L}\p{N}_])__(\S|\S.*?\S)__($|[^\p{L}\p{N}_])`)
+	strongStar       = regexp.MustCompile(`(^|[^\p{L}\p{N}*])\*\*(\S|\S.*?\S)\*\*($|[^\p{L}\p{N}*])`)
+	emUnderscore     = regexp.MustCompile(`(^|[^\p{L}\p{N}_])_([^_\s]|[^_\s][^_]*?[^_\s])_($|[^\p{L}\p{N}_])`)
+	emStar           = regexp.MustCompile(`(^|[^\p{L}\p{N}*])\*([^*\s]|[^*\s][^*]*?[^*\s])\*($|[^\p{L}\p{N}*])`)
+)
+
+// Replaces the emphasis markers with the configured ones. Code, links, urls, math and
+// comments are left as they are.
+func formatEmphasis(t string, opts Options) string {
+	// The list marker and the heading hashes are not a part of the text.
+	prefix := ""
+	if m := listRegex.FindStringSubmatchIndex(t); m != nil {
+		prefix, t = t[:m[8]], t[m[8]:]
+	}
+
+	// Hide the protected parts behind placeholders without any markers in them.
+	protected := make([]string, 0)
+	t = protectedRegex.ReplaceAllStringFunc(t, func(s string) string {
+		protected = append(protected, s)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	})
+
+	if opts.StrongMarker == "**" {
+		t = replaceAll(strongUnderscore, t, "${1}**${2}**${3}")
+	} else if opts.StrongMarker == "__" {
+		t = replaceAll(strongStar, t, "${1}__${2}__${3}")
+	}
+
+	if opts.EmphasisMarker == "*" {
+		t = replaceAll(emUnderscore, t, "${1}*${2}*${3}")
+	} else if opts.EmphasisMarker == "_" {
+		t = replaceAll(emStar, t, "${1}_${2}_${3}")
+	}
+
+	for i := len(protected) - 1; i >= 0; i-- {
+		t = strings.Replace(t, fmt.Sprintf("\x00%d\x00", i), protected[i], 1)
+	}
+	return prefix + t
+}
+
+// Replaces until nothing changes, since the matches can't share the boundary runes.
+func replaceAll(r *regexp.Regexp, t, repl string) string {
+	for i := 0; i < 4; i++ {
+		next := r.ReplaceAllString(t, repl)
+		if next == t {
+			break
+		}
+		t = next
+	}
+	return t
+}
+
+// Aligns the columns of the tables. Pipes escaped with a backslash, e.g. in the
+// wikilink aliases, and the ones in code are not column separators.
+func formatTables(lines []line) {
+	for i := 0; i < len(lines); {
+		if lines[i].kind != kindTable {
+			i++
+			continue
+		}
+		j := i
+		for j < len(lines) && lines[j].kind == kindTable {
+			j++
+		}
+		alignTable(lines[i:j])
+		i = j
+	}
+}
+
+func alignTable(rows []line) {
+	cells := make([][]string, len(rows))
+	columns := 0
+	for i, r := range rows {
+		cells[i] = splitRow(r.text)
+		if len(cells[i]) > columns {
+			columns = len(cells[i])
+		}
+	}
+
+	// The alignment of each column from the delimiter row.
+	align := make([]string, columns)
+	for c := range align {
+		if c < len(cells[1]) {
+			d := cells[1][c]
+			left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+			switch {
+			case left && right:
+				align[c] = "center"
+			case right:
+				align[c] = "right"
+			case left:
+				align[c] = "left"
+			}
+		}
+	}
+
+	widths := make([]int, columns)
+	for i, row := range cells {
+		if i == 1 {
+			continue
+		}
+		for c, cell := range row {
+			if w := utf8.RuneCountInString(cell); w > widths[c] {
+				widths[c] = w
+			}
+		}
+	}
+	for c := range widths {
+		if widths[c] < 3 {
+			widths[c] = 3
+		}
+	}
+
+	for i, row := range cells {
+		b := new(strings.Builder)
+		b.WriteString("|")
+		for c := 0; c < columns; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			if i == 1 {
+				b.WriteString(" " + delimiter(align[c], widths[c]) + " |")
+				continue
+			}
+			pad := widths[c] - utf8.RuneCountInString(cell)
+			switch align[c] {
+			case "right":
+				cell = strings.Repeat(" ", pad) + cell
+			case "center":
+				cell = strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+			default:
+				cell = cell + strings.Repeat(" ", pad)
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		rows[i].text = b.String()
+	}
+}
+
+func delimiter(align string, w int) string {
+	switch align {
+	case "center":
+		return ":" + strings.Repeat("-", w-2) + ":"
+	case "right":
+		return strings.Repeat("-", w-1) + ":"
+	case "left":
+		return ":" + strings.Repeat("-", w-1)
+	}
+	return strings.Repeat("-", w)
+}
+
+// Splits a table row into its trimmed cells.
+func splitRow(t string) []string {
+	t = strings.TrimSpace(t)
+	t = strings.TrimPrefix(t, "|")
+	if strings.HasSuffix(t, "|") && !strings.HasSuffix(t, "\\|") {
+		t = t[:len(t)-1]
+	}
+
+	cells := make([]string, 0)
+	begin := 0
+	inCode := false
+	for i := 0; i < len(t); i++ {
+		switch t[i] {
+		case '\\':
+			i++
+		case '`':
+			inCode = !inCode
+		case '|':
+			if !inCode {
+				cells = append(cells, strings.TrimSpace(t[begin:i]))
+				begin = i + 1
+			}
+		}
+	}
+	cells = append(cells, strings.TrimSpace(t[begin:]))
+	return cells
+}
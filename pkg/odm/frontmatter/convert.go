@@ -0,0 +1,148 @@
+package frontmatter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"gopkg.in/yaml.v3"
+)
+
+// The types of the properties.
+const (
+	TYPE_STRING  string = "string"
+	TYPE_NUMBER  string = "number"
+	TYPE_INTEGER string = "integer"
+	TYPE_BOOLEAN string = "boolean"
+	TYPE_DATE    string = "date"
+	TYPE_LIST    string = "list"
+	TYPE_LINK    string = "link"
+)
+
+var Types = []string{TYPE_STRING, TYPE_NUMBER, TYPE_INTEGER, TYPE_BOOLEAN, TYPE_DATE, TYPE_LIST, TYPE_LINK}
+
+// The format of the dates if none is given.
+const DEFAULT_DATE_FORMAT string = "YYYY-MM-DD"
+
+// The ways of writing the dates which are understood besides the given format.
+var dateLayouts = []string{"YYYY-MM-DD", "YYYY-MM-DDTHH:mm:ssZ", "YYYY-MM-DDTHH:mm:ss", "YYYY-MM-DD HH:mm", "YYYY/MM/DD", "DD.MM.YYYY", "MMMM D, YYYY", "MMM D, YYYY", "D MMMM YYYY"}
+
+var linkRegex = regexp.MustCompile(`^!?\[\[[^\[\]]+\]\]$`)
+
+// Parses the date in the moment.js format, or in one of the other common ways of
+// writing the dates.
+func ParseDate(value, format string) (time.Time, bool) {
+	for _, layout := range append([]string{format}, dateLayouts...) {
+		if t, err := moment.Parse(layout, strings.TrimSpace(value), time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Whether the value is a wikilink, e.g. '[[Note]]'.
+func IsLink(value string) bool {
+	return linkRegex.MatchString(value)
+}
+
+// Creates a scalar written without quotes where YAML allows it, e.g. the dates which
+// would be quoted as strings.
+func Plain(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+// Converts the property of the note to the type, one of the TYPE_* constants. The
+// dates are written in the moment.js format. Returns true if the note has changed,
+// the missing and the empty properties are left as they are.
+func Convert(s api.Set, key, typ, format string) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	p, ok := fm.Get(key)
+	if !ok || p.Kind == metadata.PropertyEmpty {
+		return false, nil
+	}
+	n, err := ConvertValue(p, typ, format)
+	if err != nil {
+		return false, fmt.Errorf("'%s' %w", key, err)
+	}
+	text, err := Render(key, n, p.Inline)
+	if err != nil {
+		return false, err
+	}
+	return replace(s, p.Match, text)
+}
+
+// Converts the value of the property to the type. The scalars become lists by
+// splitting them on the commas, and the lists of one item become that item.
+func ConvertValue(p metadata.Property, typ, format string) (*yaml.Node, error) {
+	if format == "" {
+		format = DEFAULT_DATE_FORMAT
+	}
+	if p.Kind == metadata.PropertyNested {
+		return nil, fmt.Errorf("is nested, it can't be a %s", typ)
+	}
+
+	if typ == TYPE_LIST {
+		items := p.List()
+		if p.Kind == metadata.PropertyList {
+			items = p.Items
+		}
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range items {
+			n.Content = append(n.Content, Plain(item))
+		}
+		return n, nil
+	}
+
+	value := p.Value
+	if p.Kind == metadata.PropertyList && !(p.Inline && IsLink(p.Value)) {
+		switch {
+		case typ == TYPE_STRING:
+			value = strings.Join(p.Items, ", ")
+		case len(p.Items) == 1:
+			value = p.Items[0]
+		default:
+			return nil, fmt.Errorf("is a list of %d items, it can't be a %s", len(p.Items), typ)
+		}
+	}
+
+	switch typ {
+	case TYPE_STRING:
+		return Node(value)
+	case TYPE_NUMBER, TYPE_INTEGER:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("is '%s', not a number", value)
+		}
+		if f == float64(int64(f)) {
+			return Node(int64(f))
+		}
+		if typ == TYPE_INTEGER {
+			return nil, fmt.Errorf("is '%s', not an integer", value)
+		}
+		return Node(f)
+	case TYPE_BOOLEAN:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "yes", "on", "y":
+			return Node(true)
+		case "false", "no", "off", "n":
+			return Node(false)
+		}
+		return nil, fmt.Errorf("is '%s', not a boolean", value)
+	case TYPE_DATE:
+		if t, ok := ParseDate(value, format); ok {
+			return Plain(moment.Format(t, format)), nil
+		}
+		return nil, fmt.Errorf("is '%s', not a date", value)
+	case TYPE_LINK:
+		if IsLink(value) {
+			return Node(value)
+		}
+		return Node("[[" + value + "]]")
+	}
+	return nil, fmt.Errorf("unknown type '%s', use one of %s", typ, strings.Join(Types, ", "))
+}
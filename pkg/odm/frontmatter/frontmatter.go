@@ -0,0 +1,146 @@
+package frontmatter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// The fence around the frontmatter.
+const FENCE string = "---"
+
+// Converts the value into a YAML node. Nodes are returned as they are, so the
+// values parsed from the command line keep their style.
+func Node(value any) (*yaml.Node, error) {
+	if n, ok := value.(*yaml.Node); ok {
+		if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+			return n.Content[0], nil
+		}
+		return n, nil
+	}
+
+	n := &yaml.Node{}
+	if err := n.Encode(value); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Parses a value written as YAML, e.g. '[a, b]' is a list and '5' is a number. A plain
+// line which YAML would read as a map or cut at a comment, like 'Meeting: Q3 plan' or
+// 'Foo # bar', is a string. The maps need the flow style then, e.g. '{a: 1}'.
+func ParseValue(text string) (*yaml.Node, error) {
+	var n yaml.Node
+	err := yaml.Unmarshal([]byte(text), &n)
+	if plainLine(text) && (err != nil || len(n.Content) == 0 || n.Content[0].Kind == yaml.MappingNode || n.Content[0].LineComment != "") {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.Kind != yaml.DocumentNode || len(n.Content) == 0 {
+		// An empty text is an empty value.
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	return n.Content[0], nil
+}
+
+// Checks if the text is a single line which isn't quoted or in the flow style.
+func plainLine(text string) bool {
+	text = strings.TrimSpace(text)
+	return text != "" && !strings.ContainsAny(text, "\n") && !strings.ContainsAny(text[:1], "[{\"'")
+}
+
+// Renders the property as YAML lines, including the trailing new line. The lists
+// are written as '[a, b]' if inline is set, as '- a' lines otherwise.
+func Render(key string, value any, inline bool) (string, error) {
+	n, err := Node(value)
+	if err != nil {
+		return "", err
+	}
+	if n.Kind == yaml.SequenceNode {
+		n.Style &^= yaml.FlowStyle
+		if inline {
+			n.Style |= yaml.FlowStyle
+		}
+	}
+
+	m := &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, n},
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(m); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	// Empty values are written as 'key:' like Obsidian does.
+	text := buffer.String()
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), " null") + "\n"
+	}
+	return text, nil
+}
+
+// Sets the property of the note. An existing property is replaced where it is and
+// keeps its list style, a new one is appended to the end of the frontmatter, which
+// is created if the note has none. Returns true if the note has changed.
+func Set(s api.Set, key string, value any) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+
+	if p, ok := fm.Get(key); ok {
+		text, err := Render(key, value, p.Inline)
+		if err != nil {
+			return false, err
+		}
+		return replace(s, p.Match, text)
+	}
+
+	text, err := Render(key, value, false)
+	if err != nil {
+		return false, err
+	}
+	return insert(s, fm, text)
+}
+
+// Removes the property from the note. Returns true if the note had it.
+func Unset(s api.Set, key string) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	p, ok := fm.Get(key)
+	if !ok {
+		return false, nil
+	}
+	return s.Remove(&[]api.Match{p.Match})
+}
+
+// Replaces the matched lines with the text unless they are the same.
+func replace(s api.Set, m api.Match, text string) (bool, error) {
+	if string((*s.Data())[m.Begin:m.End]) == text {
+		return false, nil
+	}
+	return s.Replace(&[]api.Match{m}, func(md api.Match, buffer api.Data) ([]byte, bool) {
+		return []byte(text), true
+	})
+}
+
+// Appends the property lines to the end of the frontmatter, or creates one.
+func insert(s api.Set, fm metadata.Frontmatter, text string) (bool, error) {
+	at := api.Match{Begin: 0, End: 0}
+	if fm.Present {
+		at = api.Match{Begin: fm.Body.End, End: fm.Body.End}
+	} else {
+		text = FENCE + "\n" + text + FENCE + "\n"
+	}
+	return s.InsertBefore(&[]api.Match{at}, func(md api.Match, buffer api.Data) ([]byte, bool) {
+		return []byte(text), true
+	})
+}
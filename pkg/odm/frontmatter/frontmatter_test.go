@@ -0,0 +1,56 @@
+package frontmatter
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		text   string
+		kind   yaml.Kind
+		tag    string
+		render string
+	}{
+		{"", yaml.ScalarNode, "!!null", "key:\n"},
+		{"5", yaml.ScalarNode, "!!int", "key: 5\n"},
+		{"true", yaml.ScalarNode, "!!bool", "key: true\n"},
+		{"plain text", yaml.ScalarNode, "!!str", "key: plain text\n"},
+		{"Meeting: Q3 plan", yaml.ScalarNode, "!!str", "key: 'Meeting: Q3 plan'\n"},
+		{"Foo: bar # baz", yaml.ScalarNode, "!!str", "key: 'Foo: bar # baz'\n"},
+		{"Foo # baz", yaml.ScalarNode, "!!str", "key: 'Foo # baz'\n"},
+		{"#project", yaml.ScalarNode, "!!str", "key: '#project'\n"},
+		{"a: b: c", yaml.ScalarNode, "!!str", "key: 'a: b: c'\n"},
+		{"'quoted # text'", yaml.ScalarNode, "!!str", "key: 'quoted # text'\n"},
+		{"[a, b]", yaml.SequenceNode, "!!seq", "key: [a, b]\n"},
+		{"{a: 1}", yaml.MappingNode, "!!map", "key: {a: 1}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			n, err := ParseValue(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.Kind != tt.kind || n.Tag != tt.tag {
+				t.Fatalf("got kind %d and tag %s, want %d and %s", n.Kind, n.Tag, tt.kind, tt.tag)
+			}
+			text, err := Render("key", n, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.render {
+				t.Fatalf("got %q, want %q", text, tt.render)
+			}
+		})
+	}
+}
+
+func TestParseValueErrors(t *testing.T) {
+	for _, text := range []string{"[a, b", "{a: 1", "'open"} {
+		if _, err := ParseValue(text); err == nil {
+			t.Errorf("no error for %q", text)
+		}
+	}
+}
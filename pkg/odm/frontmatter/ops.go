@@ -0,0 +1,136 @@
+package frontmatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// Renames the property, it keeps its value and its place. Returns true if the note
+// has changed. It is an error if the note has both of the keys.
+func Rename(s api.Set, from, to string) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	p, ok := fm.Get(from)
+	if !ok || from == to {
+		return false, nil
+	}
+	if _, ok := fm.Get(to); ok {
+		return false, fmt.Errorf("'%s' already exists", to)
+	}
+
+	// Only the key is replaced, the value is written as it is.
+	line := string((*s.Data())[p.Match.Begin:p.Match.End])
+	end := strings.Index(line, ":")
+	if q := line[0]; q == '"' || q == '\'' {
+		end = strings.IndexByte(line[1:], q) + 2
+	}
+	key, err := Render(to, nil, false)
+	if err != nil {
+		return false, err
+	}
+	key = strings.TrimSuffix(key, ":\n")
+
+	m := api.Match{Begin: p.Match.Begin, End: p.Match.Begin + end}
+	return s.Replace(&[]api.Match{m}, func(md api.Match, buffer api.Data) ([]byte, bool) {
+		return []byte(key), true
+	})
+}
+
+// Appends the values to the list property, the ones it already has are skipped. A
+// scalar becomes a list of its comma separated values with the values after them,
+// a missing property is created. Returns true if the note has changed.
+func Append(s api.Set, key string, values ...*yaml.Node) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	p, ok := fm.Get(key)
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	if ok {
+		n, err := valueNode(*s.Data(), p)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case n.Kind == yaml.SequenceNode:
+			list = n
+		case n.Kind == yaml.ScalarNode && n.Tag != "!!null":
+			// Like the values of 'tags: a, b' the scalars are split on the commas.
+			for _, item := range p.List() {
+				list.Content = append(list.Content, Plain(item))
+			}
+		case n.Kind != yaml.ScalarNode:
+			return false, fmt.Errorf("'%s' is not a list", key)
+		}
+	}
+
+	changed := false
+	for _, v := range values {
+		if indexOf(list, v) < 0 {
+			list.Content = append(list.Content, v)
+			changed = true
+		}
+	}
+	if !changed && ok && p.Kind == metadata.PropertyList {
+		return false, nil
+	}
+	return Set(s, key, list)
+}
+
+// Removes the values from the list property, it is left as an empty list if none
+// remain. A scalar equal to one of the values becomes empty. Returns true if the
+// note has changed.
+func Remove(s api.Set, key string, values ...*yaml.Node) (bool, error) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	p, ok := fm.Get(key)
+	if !ok || p.Kind == metadata.PropertyEmpty {
+		return false, nil
+	}
+	n, err := valueNode(*s.Data(), p)
+	if err != nil {
+		return false, err
+	}
+
+	switch n.Kind {
+	case yaml.SequenceNode:
+		kept := make([]*yaml.Node, 0, len(n.Content))
+		for _, item := range n.Content {
+			if indexOf(&yaml.Node{Content: values}, item) < 0 {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(n.Content) {
+			return false, nil
+		}
+		n.Content = kept
+		return Set(s, key, n)
+	case yaml.ScalarNode:
+		if indexOf(&yaml.Node{Content: values}, n) < 0 {
+			return false, nil
+		}
+		return Set(s, key, nil)
+	}
+	return false, fmt.Errorf("'%s' is not a list", key)
+}
+
+// Gets the value of the property as a YAML node, the way it is written.
+func valueNode(data []byte, p metadata.Property) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data[p.Match.Begin:p.Match.End], &doc); err != nil {
+		return nil, fmt.Errorf("'%s' can't be read: %w", p.Key, err)
+	}
+	if len(doc.Content) == 0 || len(doc.Content[0].Content) < 2 {
+		return nil, fmt.Errorf("'%s' can't be read", p.Key)
+	}
+	return doc.Content[0].Content[1], nil
+}
+
+// Finds the scalar among the items of the list, -1 if it is not there.
+func indexOf(list *yaml.Node, v *yaml.Node) int {
+	for i, item := range list.Content {
+		if item.Kind == yaml.ScalarNode && v.Kind == yaml.ScalarNode && item.Value == v.Value {
+			return i
+		}
+	}
+	return -1
+}
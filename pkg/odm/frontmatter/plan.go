@@ -0,0 +1,90 @@
+package frontmatter
+
+import (
+	"fmt"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// The operations on the properties.
+const (
+	OP_SET     string = "set"
+	OP_UNSET   string = "unset"
+	OP_RENAME  string = "rename"
+	OP_APPEND  string = "append"
+	OP_REMOVE  string = "remove"
+	OP_CONVERT string = "convert"
+)
+
+// An operation on a property of the notes.
+type Operation struct {
+	// One of the OP_* constants.
+	Op string
+
+	Key string
+
+	// The value of set, the items of append and remove.
+	Values []*yaml.Node
+
+	// The new key of rename, the type of convert.
+	To string
+
+	// The moment.js format of the dates of convert.
+	Format string
+}
+
+// Runs the operation on the note. Returns true if the note has changed.
+func (op Operation) Apply(s api.Set) (bool, error) {
+	switch op.Op {
+	case OP_SET:
+		if len(op.Values) != 1 {
+			return false, fmt.Errorf("set takes a single value")
+		}
+		return Set(s, op.Key, op.Values[0])
+	case OP_UNSET:
+		return Unset(s, op.Key)
+	case OP_RENAME:
+		return Rename(s, op.Key, op.To)
+	case OP_APPEND:
+		return Append(s, op.Key, op.Values...)
+	case OP_REMOVE:
+		return Remove(s, op.Key, op.Values...)
+	case OP_CONVERT:
+		return Convert(s, op.Key, op.To, op.Format)
+	}
+	return false, fmt.Errorf("unknown operation '%s'", op.Op)
+}
+
+// Changes a note, like the operations on the properties. Returns true if the note has
+// changed.
+type Editor interface {
+	Apply(s api.Set) (bool, error)
+}
+
+// The result of running the operations on the notes. The notes are only changed in
+// memory until the plan is committed, the changed ones are in its transaction.
+type Plan struct {
+	*vault.Transaction
+}
+
+// Runs the operations, in their order, on every note. If one of them fails on a note
+// the error names the note, and nothing should be committed.
+func Run(v *vault.Vault, sets []api.Set, ops ...Editor) (*Plan, error) {
+	p := &Plan{Transaction: v.Begin()}
+	for _, s := range sets {
+		changed := false
+		for _, op := range ops {
+			ok, err := op.Apply(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", v.RelPath(s), err)
+			}
+			changed = changed || ok
+		}
+		if changed {
+			p.Write(s)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,291 @@
+package links
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The link styles.
+const (
+	STYLE_WIKILINK string = "wikilink"
+	STYLE_MARKDOWN string = "markdown"
+)
+
+// The ways of writing the path of the target.
+const (
+	// The base name if it is unique in the vault, the full path otherwise.
+	PATH_SHORTEST string = "shortest"
+
+	// Relative to the folder of the note.
+	PATH_RELATIVE string = "relative"
+
+	// Relative to the root of the vault.
+	PATH_ABSOLUTE string = "absolute"
+)
+
+var (
+	Styles = []string{STYLE_WIKILINK, STYLE_MARKDOWN}
+	Paths  = []string{PATH_SHORTEST, PATH_RELATIVE, PATH_ABSOLUTE}
+)
+
+type ConvertOptions struct {
+	// The style every link is written in, one of the STYLE_* constants.
+	Style string
+
+	// How the target is written, one of the PATH_* constants.
+	Path string
+
+	// Whether the heading anchors of the markdown links are written as slugs, e.g.
+	// '#my-heading' instead of '#My%20Heading'.
+	Slugs bool
+}
+
+// Rewrites the links of the notes in a single style. The slugs are turned back into
+// the heading texts when converting to wikilinks, so the links round-trip.
+type Converter struct {
+	vault    *vault.Vault
+	resolver *vault.Resolver
+	options  ConvertOptions
+
+	// The notes by their paths, to look up the headings of the targets.
+	sets map[string]api.Set
+}
+
+// Creates a converter, the group is used to find the headings of the targets.
+func NewConverter(v *vault.Vault, g api.Group, r *vault.Resolver, opts ConvertOptions) (*Converter, error) {
+	if err := checkOption("link style", opts.Style, Styles); err != nil {
+		return nil, err
+	}
+	if err := checkOption("path style", opts.Path, Paths); err != nil {
+		return nil, err
+	}
+
+	c := &Converter{
+		vault:    v,
+		resolver: r,
+		options:  opts,
+		sets:     make(map[string]api.Set),
+	}
+	for _, s := range g.Sets() {
+		c.sets[v.RelPath(s)] = s
+	}
+	return c, nil
+}
+
+func checkOption(name, value string, values []string) error {
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s '%s', use one of %s", name, value, strings.Join(values, ", "))
+}
+
+// Rewrites the internal links of the note. The links which can't be written in the
+// other style, e.g. an alias with a '|' in it, are left as they are.
+func (c *Converter) Convert(s api.Set, md *metadata.Metadata) ([]Change, error) {
+	data := *s.Data()
+	rendered := make(map[int]string)
+	matches := make([]api.Match, 0)
+	changes := make([]Change, 0)
+
+	for _, l := range md.Links {
+		if l.External {
+			continue
+		}
+		after, ok := c.render(l, md.Path)
+		before := string(data[l.Match.Begin:l.Match.End])
+		if !ok || after == before {
+			continue
+		}
+
+		rendered[l.Match.Begin] = after
+		matches = append(matches, l.Match)
+		changes = append(changes, Change{Path: md.Path, Line: l.Line, Match: l.Match, Before: before, After: after})
+	}
+
+	if len(matches) == 0 {
+		return changes, nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Begin < matches[j].Begin
+	})
+	_, err := s.Replace(&matches, func(m api.Match, _ api.Data) ([]byte, bool) {
+		text, ok := rendered[m.Begin]
+		return []byte(text), ok
+	})
+	return changes, err
+}
+
+// Rewrites the links of every note in the group. Returns the changed sets.
+func (c *Converter) ConvertGroup(g api.Group) ([]api.Set, []Change, error) {
+	changed := make([]api.Set, 0)
+	changes := make([]Change, 0)
+	for _, s := range g.Sets() {
+		md, err := c.vault.Metadata(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		cs, err := c.Convert(s, md)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(cs) > 0 {
+			changed = append(changed, s)
+			changes = append(changes, cs...)
+		}
+	}
+	return changed, changes, nil
+}
+
+// Creates a link to the target from the note at the given path, in the style of the
+// converter.
+func (c *Converter) Link(from, target string) string {
+	if text, ok := c.render(metadata.Link{Kind: metadata.LinkWiki, Target: target}, from); ok {
+		return text
+	}
+	return Wikilink(strings.TrimSuffix(target, vault.NOTE_EXTENSION), "", "", false)
+}
+
+// Renders the link in the style of the converter. Returns false if it can't be.
+func (c *Converter) render(l metadata.Link, from string) (string, bool) {
+	resolved, found := "", false
+	if l.Target != "" {
+		resolved, found = c.resolver.Resolve(from, l.Target)
+	}
+
+	target := l.Target
+	if found {
+		switch c.options.Path {
+		case PATH_SHORTEST:
+			target = c.resolver.ShortestTarget(resolved)
+
+			// Keep the target as it is written if it is as short and still resolves to
+			// the same file, e.g. a name several notes have, so the links round-trip.
+			original := l.Target
+			if strings.HasSuffix(strings.ToLower(original), vault.NOTE_EXTENSION) {
+				original = original[:len(original)-len(vault.NOTE_EXTENSION)]
+			}
+			if p, ok := c.resolver.Resolve(from, original); ok && p == resolved && len(original) <= len(target) {
+				target = original
+			}
+		case PATH_RELATIVE:
+			target = RelativePath(from, resolved)
+		case PATH_ABSOLUTE:
+			target = resolved
+		}
+	}
+
+	anchor, heading := l.Anchor, false
+	if anchor != "" && !strings.HasPrefix(anchor, "^") {
+		anchor, heading = c.heading(resolved, from, l.Target == "", anchor)
+	}
+
+	if c.options.Style == STYLE_WIKILINK {
+		if strings.HasSuffix(strings.ToLower(target), vault.NOTE_EXTENSION) {
+			target = target[:len(target)-len(vault.NOTE_EXTENSION)]
+		}
+
+		// The markdown links always have a text, drop it if it is the default. The
+		// embeds show the name with the extension.
+		alias := l.Alias
+		if l.Kind == metadata.LinkMarkdown && (alias == DisplayText(target, anchor, l.Embed) || l.Embed && found && alias == DisplayText(resolved, anchor, true)) {
+			alias = ""
+		}
+		if strings.ContainsAny(alias, "|[]") || strings.ContainsAny(target, "|#^[]") {
+			return "", false
+		}
+		return Wikilink(target, anchor, alias, l.Embed), true
+	}
+
+	if target != "" && path.Ext(target) == "" {
+		// Markdown links need the extension.
+		if found {
+			target += path.Ext(resolved)
+		} else {
+			target += vault.NOTE_EXTENSION
+		}
+	}
+	text := l.Alias
+	if l.Kind == metadata.LinkWiki && text == "" {
+		text = DisplayText(target, anchor, l.Embed)
+	}
+	if strings.ContainsAny(text, "[]") {
+		return "", false
+	}
+	if c.options.Slugs && heading {
+		// Only the known headings, the rest couldn't be turned back.
+		anchor = Slug(anchor)
+	}
+	return MarkdownLink(target, anchor, text, l.Embed), true
+}
+
+// Gets the heading text the anchor refers to in the target note. The anchor can be
+// the text or the slug of the heading. Unknown anchors are returned as they are, with
+// false.
+func (c *Converter) heading(resolved, from string, self bool, anchor string) (string, bool) {
+	if self {
+		resolved = from
+	}
+	s, ok := c.sets[resolved]
+	if !ok {
+		return anchor, false
+	}
+	md, err := c.vault.Metadata(s)
+	if err != nil {
+		return anchor, false
+	}
+
+	for _, h := range md.Headings {
+		if strings.EqualFold(h.Text, anchor) {
+			return h.Text, true
+		}
+	}
+	for _, h := range md.Headings {
+		if Slug(h.Text) == anchor {
+			return h.Text, true
+		}
+	}
+	return anchor, false
+}
+
+// Gets the text Obsidian shows for a link without an alias, e.g. 'Note > Heading'.
+// Embeds show the file name with its extension.
+func DisplayText(target, anchor string, embed bool) string {
+	name := path.Base(target)
+	if target == "" {
+		name = ""
+	} else if !embed {
+		name = strings.TrimSuffix(name, vault.NOTE_EXTENSION)
+	}
+
+	switch {
+	case anchor == "":
+		return name
+	case name == "":
+		return anchor
+	}
+	return name + " > " + anchor
+}
+
+// Converts the heading into the anchor slug used by the common markdown renderers:
+// lower case, the spaces become dashes and the punctuation is dropped.
+func Slug(heading string) string {
+	b := new(strings.Builder)
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package links
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Renders a wikilink, e.g. '![[target#anchor|alias]]'.
+func Wikilink(target, anchor, alias string, embed bool) string {
+	b := new(strings.Builder)
+	if embed {
+		b.WriteString("!")
+	}
+	b.WriteString("[[")
+	b.WriteString(target)
+	if anchor != "" {
+		b.WriteString("#" + anchor)
+	}
+	if alias != "" {
+		b.WriteString("|" + alias)
+	}
+	b.WriteString("]]")
+	return b.String()
+}
+
+// Renders a markdown link, e.g. '![text](dest#anchor)'. The destination and the anchor
+// are encoded.
+func MarkdownLink(dest, anchor, text string, embed bool) string {
+	b := new(strings.Builder)
+	if embed {
+		b.WriteString("!")
+	}
+	b.WriteString("[" + text + "](")
+	b.WriteString(EncodePath(dest))
+	if anchor != "" {
+		b.WriteString("#" + EncodePath(anchor))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Encodes each segment of the path so it can be used in a markdown link. The spaces
+// become '%20' and the parentheses are escaped as well, so the link can't end early.
+func EncodePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		s = url.PathEscape(s)
+		s = strings.ReplaceAll(s, "(", "%28")
+		s = strings.ReplaceAll(s, ")", "%29")
+		segments[i] = s
+	}
+	return strings.Join(segments, "/")
+}
+
+// Gets the path of the target relative to the folder of the note. Both are relative to
+// the vault.
+func RelativePath(from, to string) string {
+	fromParts := splitPath(path.Dir(from))
+	toParts := splitPath(to)
+
+	common := 0
+	for common < len(fromParts) && common < len(toParts)-1 && fromParts[common] == toParts[common] {
+		common++
+	}
+
+	parts := make([]string, 0)
+	for i := common; i < len(fromParts); i++ {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, toParts[common:]...)
+	return strings.Join(parts, "/")
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean(p), "/")
+	if p == "." || p == "" {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,169 @@
+package links
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// A link which is rewritten.
+type Change struct {
+	// The path of the note the link is in.
+	Path string
+	Line int
+
+	// Covers the link before it is rewritten.
+	Match api.Match
+
+	Before string
+	After  string
+}
+
+// A link found in a set, waiting to be rewritten.
+type pending struct {
+	set    api.Set
+	md     *metadata.Metadata
+	link   metadata.Link
+	target string
+
+	// Whether the markdown link is written relative to the vault root.
+	absolute bool
+}
+
+// Rewrites the links in the group when a file moves from the old path to the new one.
+// The links to the file are pointed to the new path, and the relative markdown links in
+// the moved note itself are fixed for its new folder. The resolver is updated with the
+// move. The sets are only modified in memory, the changed ones are returned.
+func Retarget(v *vault.Vault, g api.Group, r *vault.Resolver, oldPath, newPath string) ([]api.Set, []Change, error) {
+	links := make([]pending, 0)
+
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, l := range md.Links {
+			if l.External {
+				continue
+			}
+			target, ok := r.Resolve(md.Path, l.Target)
+			if !ok {
+				continue
+			}
+			if target == oldPath || (md.Path == oldPath && l.Kind == metadata.LinkMarkdown && isRelative(l.Target)) {
+				links = append(links, pending{set: s, md: md, link: l, target: target, absolute: IsVaultAbsolute(l, target)})
+			}
+		}
+	}
+
+	// The links are rendered after the move, so the shortest targets are right.
+	r.Remove(oldPath)
+	r.Add(newPath)
+
+	perSet := make(map[api.Set][]pending)
+	order := make([]api.Set, 0)
+	for _, p := range links {
+		if _, ok := perSet[p.set]; !ok {
+			order = append(order, p.set)
+		}
+		perSet[p.set] = append(perSet[p.set], p)
+	}
+
+	changed := make([]api.Set, 0)
+	changes := make([]Change, 0)
+	for _, s := range order {
+		rendered := make(map[int]string)
+		matches := make([]api.Match, 0)
+
+		for _, p := range perSet[s] {
+			from := p.md.Path
+			if from == oldPath {
+				from = newPath
+			}
+			target := p.target
+			if target == oldPath {
+				target = newPath
+			}
+
+			after := Render(p.link, from, target, p.absolute, r)
+			before := string((*s.Data())[p.link.Match.Begin:p.link.Match.End])
+			if after == before {
+				continue
+			}
+
+			rendered[p.link.Match.Begin] = after
+			matches = append(matches, p.link.Match)
+			changes = append(changes, Change{Path: p.md.Path, Line: p.link.Line, Match: p.link.Match, Before: before, After: after})
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Begin < matches[j].Begin
+		})
+
+		_, err := s.Replace(&matches, func(m api.Match, _ api.Data) ([]byte, bool) {
+			text, ok := rendered[m.Begin]
+			return []byte(text), ok
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, s)
+	}
+
+	return changed, changes, nil
+}
+
+// Renders the link again so it points to the target from the note at the given path,
+// keeping its style, anchor and alias. Markdown links are written relative to the note
+// unless they are absolute.
+func Render(l metadata.Link, from, target string, absolute bool, r *vault.Resolver) string {
+	if l.Kind == metadata.LinkMarkdown {
+		dest := RelativePath(from, target)
+		if absolute {
+			dest = target
+			if strings.HasPrefix(l.Target, "/") {
+				dest = "/" + target
+			}
+		}
+		return MarkdownLink(dest, l.Anchor, l.Alias, l.Embed)
+	}
+
+	name := r.ShortestTarget(target)
+	if strings.Contains(l.Target, "/") {
+		// Keep the full paths if the link used one.
+		name = strings.TrimSuffix(target, vault.NOTE_EXTENSION)
+	}
+	if path.Ext(l.Target) != "" && path.Ext(name) == "" {
+		name += path.Ext(target)
+	}
+	return Wikilink(name, l.Anchor, l.Alias, l.Embed)
+}
+
+// Whether a markdown link target is relative to the note, not to the vault root.
+func isRelative(target string) bool {
+	return !strings.HasPrefix(target, "/")
+}
+
+// Whether the markdown link, which resolves to the given path, is written relative to
+// the vault root rather than to the note.
+func IsVaultAbsolute(l metadata.Link, resolved string) bool {
+	if l.Kind != metadata.LinkMarkdown {
+		return false
+	}
+	if strings.HasPrefix(l.Target, "/") {
+		return true
+	}
+	if strings.HasPrefix(l.Target, "./") || strings.HasPrefix(l.Target, "../") {
+		return false
+	}
+	t := strings.ToLower(l.Target)
+	res := strings.ToLower(resolved)
+	return strings.Contains(t, "/") && (t == res || t+vault.NOTE_EXTENSION == res)
+}
@@ -0,0 +1,109 @@
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The name of the lint configuration file under the '.odm' folder.
+const CONFIG_FILE_NAME = "lint.json"
+
+// The settings of the rules for a folder.
+type Settings struct {
+	// Enables or disables the rules by their names.
+	Rules map[string]bool `json:"rules,omitempty" yaml:"rules,omitempty"`
+
+	// The frontmatter keys every note must have.
+	Required []string `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// The settings for the whole vault with the overrides for the folders. The settings of
+// a folder apply to everything under it, the deeper folders win.
+type Config struct {
+	Settings `yaml:",inline"`
+
+	// Folder paths relative to the vault to their settings.
+	Folders map[string]Settings `json:"folders,omitempty" yaml:"folders,omitempty"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Settings: Settings{Rules: make(map[string]bool), Required: []string{}},
+		Folders:  make(map[string]Settings),
+	}
+}
+
+// Loads the configuration of the vault from its '.odm' folder. If there is none, the
+// default configuration is returned.
+func LoadConfig(v *vault.Vault) (*Config, error) {
+	f, err := v.Root().File(vault.ODM_FOLDER + "/" + CONFIG_FILE_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.ReadAll()
+	if errors.Is(err, os.ErrNotExist) {
+		return NewDefaultConfig(), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	config := NewDefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Gets the effective settings for the note at the given path.
+func (c *Config) For(p string) Settings {
+	settings := Settings{Rules: make(map[string]bool), Required: append([]string{}, c.Required...)}
+	for name, on := range c.Rules {
+		settings.Rules[name] = on
+	}
+
+	folders := make([]string, 0, len(c.Folders))
+	for folder := range c.Folders {
+		folder = strings.Trim(path.Clean(folder), "/")
+		if folder == "." || folder == "" || strings.HasPrefix(p, folder+"/") {
+			folders = append(folders, folder)
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return len(folders[i]) < len(folders[j])
+	})
+
+	for _, folder := range folders {
+		s := c.lookup(folder)
+		for name, on := range s.Rules {
+			settings.Rules[name] = on
+		}
+		if s.Required != nil {
+			settings.Required = s.Required
+		}
+	}
+	return settings
+}
+
+func (c *Config) lookup(folder string) Settings {
+	for key, s := range c.Folders {
+		if strings.Trim(path.Clean(key), "/") == folder {
+			return s
+		}
+	}
+	return Settings{}
+}
+
+// Checks if the rule is enabled.
+func (s Settings) Enabled(r Rule) bool {
+	if on, ok := s.Rules[r.Name()]; ok {
+		return on
+	}
+	return r.Default()
+}
@@ -0,0 +1,110 @@
+package lint
+
+import (
+	"sort"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+const (
+	EDIT_REPLACE       int = 1
+	EDIT_INSERT_BEFORE int = 2
+	EDIT_INSERT_AFTER  int = 3
+	EDIT_REMOVE        int = 4
+)
+
+// A single change, it maps to one of the SetModifier calls.
+type Edit struct {
+	// One of the EDIT_* constants.
+	Mode  int
+	Match api.Match
+
+	// The text to put, not used when removing.
+	Text string
+}
+
+// The edits fixing a diagnostic.
+type Fix struct {
+	Edits []Edit
+}
+
+func replaceFix(m api.Match, text string) *Fix {
+	return &Fix{Edits: []Edit{{Mode: EDIT_REPLACE, Match: m, Text: text}}}
+}
+
+// Applies the fixes of the diagnostics to the set. The diagnostics must be found in the
+// same version of the set. Fixes overlapping with an earlier one are skipped, the
+// skipped ones are found again on the next run. Returns how many diagnostics are fixed.
+func ApplyFixes(s api.Set, diagnostics []Diagnostic) (int, error) {
+	type fixRef struct {
+		edits []Edit
+		begin int
+		end   int
+	}
+
+	fixes := make([]fixRef, 0)
+	for _, d := range diagnostics {
+		if d.Fix == nil || len(d.Fix.Edits) == 0 {
+			continue
+		}
+		ref := fixRef{edits: d.Fix.Edits, begin: d.Fix.Edits[0].Match.Begin, end: d.Fix.Edits[0].Match.End}
+		for _, e := range d.Fix.Edits {
+			if e.Match.Begin < ref.begin {
+				ref.begin = e.Match.Begin
+			}
+			if e.Match.End > ref.end {
+				ref.end = e.Match.End
+			}
+		}
+		fixes = append(fixes, ref)
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		return fixes[i].begin < fixes[j].begin
+	})
+
+	// Drop the overlapping ones, insertions at the same point also count as overlaps.
+	accepted := make([]fixRef, 0, len(fixes))
+	for _, f := range fixes {
+		if n := len(accepted); n > 0 && (f.begin < accepted[n-1].end || f.begin == accepted[n-1].begin) {
+			continue
+		}
+		accepted = append(accepted, f)
+	}
+
+	edits := make([]Edit, 0)
+	for _, f := range accepted {
+		edits = append(edits, f.edits...)
+	}
+
+	// Apply from the end so the earlier offsets stay valid.
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Match.Begin > edits[j].Match.Begin
+	})
+	for _, e := range edits {
+		if err := applyEdit(s, e); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(accepted), nil
+}
+
+func applyEdit(s api.Set, e Edit) error {
+	matches := &[]api.Match{e.Match}
+	text := func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(e.Text), true
+	}
+
+	var err error
+	switch e.Mode {
+	case EDIT_REPLACE:
+		_, err = s.Replace(matches, text)
+	case EDIT_INSERT_BEFORE:
+		_, err = s.InsertBefore(matches, text)
+	case EDIT_INSERT_AFTER:
+		_, err = s.InsertAfter(matches, text)
+	case EDIT_REMOVE:
+		_, err = s.Remove(matches)
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+const (
+	SEVERITY_WARNING int = 1
+	SEVERITY_ERROR   int = 2
+)
+
+// A problem found by a rule.
+type Diagnostic struct {
+	// The name of the rule.
+	Rule string
+
+	// The path of the note relative to the vault.
+	Path string
+
+	// 1 based position of the problem.
+	Line int
+	Col  int
+
+	Severity int
+	Message  string
+
+	// Covers the problematic part of the note.
+	Match api.Match
+
+	// How to fix the problem, nil if it can't be fixed automatically.
+	Fix *Fix
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", d.Path, d.Line, d.Col, d.SeverityName(), d.Message, d.Rule)
+}
+
+// Gets the severity as a word, warning or error.
+func (d Diagnostic) SeverityName() string {
+	if d.Severity == SEVERITY_ERROR {
+		return "error"
+	}
+	return "warning"
+}
+
+// A rule checks a single note, but it can look at the rest of the group through the
+// context.
+type Rule interface {
+	// The name used in the configuration, e.g. 'broken-link'.
+	Name() string
+
+	// A one line explanation of the rule.
+	Description() string
+
+	// Whether the rule runs when the configuration doesn't mention it.
+	Default() bool
+
+	// Reports the problems of the note.
+	Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic
+}
+
+var registry = make(map[string]Rule)
+
+// Registers a rule, the ones with the same name are replaced.
+func Register(r Rule) {
+	registry[r.Name()] = r
+}
+
+// Gets every registered rule sorted by their names.
+func Rules() []Rule {
+	rules := make([]Rule, 0, len(registry))
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name() < rules[j].Name()
+	})
+	return rules
+}
+
+// What the rules can know about the rest of the vault.
+type Context struct {
+	Vault    *vault.Vault
+	Group    api.Group
+	Resolver *vault.Resolver
+	Config   *Config
+
+	// The metadata of every note by their paths.
+	Metadata map[string]*metadata.Metadata
+
+	// Number of links from the other notes to each note.
+	Backlinks map[string]int
+
+	// The paths of the notes with each lower cased title.
+	Titles map[string][]string
+}
+
+// Prepares the context over the group.
+func NewContext(v *vault.Vault, g api.Group, config *Config) (*Context, error) {
+	r, err := v.Resolver()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &Context{
+		Vault:     v,
+		Group:     g,
+		Resolver:  r,
+		Config:    config,
+		Metadata:  make(map[string]*metadata.Metadata),
+		Backlinks: make(map[string]int),
+		Titles:    make(map[string][]string),
+	}
+
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Metadata[md.Path] = md
+
+		title := strings.ToLower(md.Title)
+		ctx.Titles[title] = append(ctx.Titles[title], md.Path)
+	}
+
+	for path, md := range ctx.Metadata {
+		for _, l := range md.Links {
+			if l.External {
+				continue
+			}
+			if target, ok := r.Resolve(path, l.Target); ok && target != path {
+				ctx.Backlinks[target] += 1
+			}
+		}
+	}
+
+	return ctx, nil
+}
+
+// Runs the enabled rules over every note of the group. Diagnostics are sorted by their
+// paths and positions.
+func Run(ctx *Context) ([]Diagnostic, error) {
+	diagnostics := make([]Diagnostic, 0)
+
+	for _, s := range ctx.Group.Sets() {
+		ds, err := RunSet(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, ds...)
+	}
+
+	Sort(diagnostics)
+	return diagnostics, nil
+}
+
+// Runs the enabled rules over a single note.
+func RunSet(ctx *Context, s api.Set) ([]Diagnostic, error) {
+	md, err := ctx.Vault.Metadata(s)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := make([]Diagnostic, 0)
+	settings := ctx.Config.For(md.Path)
+	for _, r := range Rules() {
+		if !settings.Enabled(r) {
+			continue
+		}
+		for _, d := range r.Check(ctx, s, md) {
+			d.Rule = r.Name()
+			d.Path = md.Path
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	Sort(diagnostics)
+	return diagnostics, nil
+}
+
+func Sort(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+}
+
+// Checks the rule names, returns an error for the unknown ones.
+func CheckRuleNames(names []string) error {
+	unknown := make([]string, 0)
+	for _, n := range names {
+		if _, ok := registry[n]; !ok {
+			unknown = append(unknown, n)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.New("unknown lint rules: " + strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Used by the rules to report a problem at the match.
+func diagnose(lines metadata.LineIndex, m api.Match, severity int, fix *Fix, format string, obj ...any) Diagnostic {
+	line, col := lines.Position(m.Begin)
+	return Diagnostic{
+		Line:     line,
+		Col:      col,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, obj...),
+		Match:    m,
+		Fix:      fix,
+	}
+}
@@ -0,0 +1,324 @@
+package lint
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/format"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+func init() {
+	Register(brokenLinkRule{})
+	Register(brokenAnchorRule{})
+	Register(orphanNoteRule{})
+	Register(emptyNoteRule{})
+	Register(duplicateTitleRule{})
+	Register(requiredFrontmatterRule{})
+	Register(trailingWhitespaceRule{})
+	Register(listMarkerRule{})
+	Register(headingJumpRule{})
+}
+
+// Links to the notes or the attachments which don't exist.
+type brokenLinkRule struct{}
+
+func (brokenLinkRule) Name() string        { return "broken-link" }
+func (brokenLinkRule) Description() string { return "links to notes or files which don't exist" }
+func (brokenLinkRule) Default() bool       { return true }
+
+func (brokenLinkRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	lines := metadata.NewLineIndex(*s.Data())
+	for _, l := range md.Links {
+		if l.External || l.Target == "" {
+			continue
+		}
+		if _, ok := ctx.Resolver.Resolve(md.Path, l.Target); !ok {
+			diagnostics = append(diagnostics, diagnose(lines, l.Match, SEVERITY_ERROR, nil, "link target '%s' doesn't exist", l.Target))
+		}
+	}
+	return diagnostics
+}
+
+// Links to the headings or the blocks which don't exist in the target note.
+type brokenAnchorRule struct{}
+
+func (brokenAnchorRule) Name() string        { return "broken-anchor" }
+func (brokenAnchorRule) Description() string { return "links to headings or blocks which don't exist" }
+func (brokenAnchorRule) Default() bool       { return true }
+
+func (brokenAnchorRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	lines := metadata.NewLineIndex(*s.Data())
+	for _, l := range md.Links {
+		if l.External || l.Anchor == "" {
+			continue
+		}
+		target, ok := ctx.Resolver.Resolve(md.Path, l.Target)
+		if !ok {
+			continue
+		}
+		tmd, ok := ctx.Metadata[target]
+		if !ok {
+			// Anchors into attachments, e.g. pdf pages, are not checked.
+			continue
+		}
+		if !HasAnchor(tmd, l.Anchor) {
+			diagnostics = append(diagnostics, diagnose(lines, l.Match, SEVERITY_ERROR, nil, "'%s' has no heading or block '%s'", target, l.Anchor))
+		}
+	}
+	return diagnostics
+}
+
+// Checks if the note has the heading or the block the anchor refers to. Nested heading
+// anchors like 'A#B' are checked by their last heading. The headings are compared
+// ignoring the case and the punctuation, so the slugs of markdown links also match.
+func HasAnchor(md *metadata.Metadata, anchor string) bool {
+	if strings.HasPrefix(anchor, "^") {
+		for _, b := range md.Blocks {
+			if b.ID == anchor[1:] {
+				return true
+			}
+		}
+		return false
+	}
+
+	parts := strings.Split(anchor, "#")
+	want := normalizeAnchor(parts[len(parts)-1])
+	for _, h := range md.Headings {
+		if normalizeAnchor(h.Text) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeAnchor(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// Notes which neither link to nor are linked from another note.
+type orphanNoteRule struct{}
+
+func (orphanNoteRule) Name() string        { return "orphan-note" }
+func (orphanNoteRule) Description() string { return "notes without any incoming or outgoing links" }
+func (orphanNoteRule) Default() bool       { return true }
+
+func (orphanNoteRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	if ctx.Backlinks[md.Path] > 0 {
+		return nil
+	}
+	for _, l := range md.Links {
+		if target, ok := ctx.Resolver.Resolve(md.Path, l.Target); ok && !l.External && target != md.Path {
+			return nil
+		}
+	}
+	return []Diagnostic{diagnose(metadata.NewLineIndex(nil), api.Match{}, SEVERITY_WARNING, nil, "note is not linked to any other note")}
+}
+
+// Notes without any contents besides the frontmatter.
+type emptyNoteRule struct{}
+
+func (emptyNoteRule) Name() string        { return "empty-note" }
+func (emptyNoteRule) Description() string { return "notes without any contents" }
+func (emptyNoteRule) Default() bool       { return true }
+
+func (emptyNoteRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	if len(bytes.TrimSpace((*s.Data())[md.Frontmatter.Match.End:])) > 0 {
+		return nil
+	}
+	return []Diagnostic{diagnose(metadata.NewLineIndex(nil), api.Match{}, SEVERITY_WARNING, nil, "note is empty")}
+}
+
+// Notes with the same title in different folders, the links to them are ambiguous.
+type duplicateTitleRule struct{}
+
+func (duplicateTitleRule) Name() string        { return "duplicate-title" }
+func (duplicateTitleRule) Description() string { return "notes sharing a title across folders" }
+func (duplicateTitleRule) Default() bool       { return true }
+
+func (duplicateTitleRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	paths := ctx.Titles[strings.ToLower(md.Title)]
+	if len(paths) < 2 {
+		return nil
+	}
+	others := make([]string, 0, len(paths)-1)
+	for _, p := range paths {
+		if p != md.Path {
+			others = append(others, p)
+		}
+	}
+	return []Diagnostic{diagnose(metadata.NewLineIndex(nil), api.Match{}, SEVERITY_WARNING, nil, "title '%s' is also used by %s", md.Title, strings.Join(others, ", "))}
+}
+
+// Notes missing the frontmatter keys required for their folder. The fix adds the keys
+// with empty values.
+type requiredFrontmatterRule struct{}
+
+func (requiredFrontmatterRule) Name() string        { return "required-frontmatter" }
+func (requiredFrontmatterRule) Description() string { return "notes missing required frontmatter keys" }
+func (requiredFrontmatterRule) Default() bool       { return true }
+
+func (requiredFrontmatterRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	lines := metadata.NewLineIndex(*s.Data())
+	for _, key := range ctx.Config.For(md.Path).Required {
+		if _, ok := md.Frontmatter.Get(key); ok {
+			continue
+		}
+
+		var fix *Fix
+		if md.Frontmatter.Present {
+			fix = &Fix{Edits: []Edit{{
+				Mode:  EDIT_INSERT_AFTER,
+				Match: api.Match{Begin: md.Frontmatter.Body.End, End: md.Frontmatter.Body.End},
+				Text:  key + ":\n",
+			}}}
+		} else {
+			fix = &Fix{Edits: []Edit{{
+				Mode:  EDIT_INSERT_BEFORE,
+				Match: api.Match{Begin: 0, End: 0},
+				Text:  "---\n" + key + ":\n---\n",
+			}}}
+		}
+		diagnostics = append(diagnostics, diagnose(lines, md.Frontmatter.Match, SEVERITY_ERROR, fix, "frontmatter key '%s' is missing", key))
+	}
+
+	// Merge the fixes of a note without a frontmatter, otherwise each would add one.
+	if !md.Frontmatter.Present && len(diagnostics) > 1 {
+		keys := ""
+		for _, key := range ctx.Config.For(md.Path).Required {
+			if _, ok := md.Frontmatter.Get(key); !ok {
+				keys += key + ":\n"
+			}
+		}
+		diagnostics[0].Fix.Edits[0].Text = "---\n" + keys + "---\n"
+		for i := 1; i < len(diagnostics); i++ {
+			diagnostics[i].Fix = nil
+		}
+	}
+	return diagnostics
+}
+
+// Lines ending with spaces or tabs.
+type trailingWhitespaceRule struct{}
+
+func (trailingWhitespaceRule) Name() string        { return "trailing-whitespace" }
+func (trailingWhitespaceRule) Description() string { return "lines ending with spaces or tabs" }
+func (trailingWhitespaceRule) Default() bool       { return true }
+
+var trailingWhitespaceRegex = regexp.MustCompile(`(?m)[ \t]+\r?$`)
+
+func (trailingWhitespaceRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	data := *s.Data()
+	lines := metadata.NewLineIndex(data)
+
+	// The frontmatter and the code are left alone, like 'odm fmt' does.
+	body := md.Frontmatter.Match.End
+	breaks := format.HardBreaks(data[body:])
+	line, counted := 0, body
+	for _, m := range trailingWhitespaceRegex.FindAllIndex(data[body:], -1) {
+		match := api.Match{Begin: body + m[0], End: body + m[1]}
+		if data[match.End-1] == '\r' {
+			match.End -= 1
+		}
+		if metadata.Inside(md.Verbatim, match.Begin) {
+			continue
+		}
+
+		line += bytes.Count(data[counted:match.Begin], []byte("\n"))
+		counted = match.Begin
+		if breaks[line] {
+			// The hard line breaks keep two spaces.
+			if string(data[match.End-2:match.End]) == "  " {
+				match.End -= 2
+			}
+			if match.Begin == match.End {
+				continue
+			}
+		}
+
+		fix := &Fix{Edits: []Edit{{Mode: EDIT_REMOVE, Match: match}}}
+		diagnostics = append(diagnostics, diagnose(lines, match, SEVERITY_WARNING, fix, "trailing whitespace"))
+	}
+	return diagnostics
+}
+
+// Bullet lists using different markers in the same note. The first marker is taken as
+// the preferred one.
+type listMarkerRule struct{}
+
+func (listMarkerRule) Name() string        { return "list-marker" }
+func (listMarkerRule) Description() string { return "bullet lists with inconsistent markers" }
+func (listMarkerRule) Default() bool       { return true }
+
+var listMarkerRegex = regexp.MustCompile(`(?m)^[ \t]*([-*+])[ \t]+\S`)
+var thematicBreakRegex = regexp.MustCompile(`^[ \t]*([-*_])(?:[ \t]*$|[ \t]*[-*_][ \t]*[-*_][-*_ \t]*$)`)
+
+func (listMarkerRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	data := *s.Data()
+	lines := metadata.NewLineIndex(data)
+	body := md.Frontmatter.Match.End
+
+	var preferred byte
+	for _, m := range listMarkerRegex.FindAllSubmatchIndex(data[body:], -1) {
+		marker := api.Match{Begin: body + m[2], End: body + m[3]}
+		if metadata.Inside(md.Verbatim, marker.Begin) {
+			continue
+		}
+		line, _ := lines.Position(marker.Begin)
+		lineEnd := bytes.IndexByte(data[marker.Begin:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(data) - marker.Begin
+		}
+		if thematicBreakRegex.Match(data[lines.LineStart(line) : marker.Begin+lineEnd]) {
+			continue
+		}
+
+		if preferred == 0 {
+			preferred = data[marker.Begin]
+			continue
+		}
+		if data[marker.Begin] != preferred {
+			diagnostics = append(diagnostics, diagnose(lines, marker, SEVERITY_WARNING, replaceFix(marker, string(preferred)),
+				"list marker '%c' should be '%c'", data[marker.Begin], preferred))
+		}
+	}
+	return diagnostics
+}
+
+// Headings which are more than one level deeper than the previous heading.
+type headingJumpRule struct{}
+
+func (headingJumpRule) Name() string        { return "heading-jump" }
+func (headingJumpRule) Description() string { return "headings skipping levels, e.g. h1 to h3" }
+func (headingJumpRule) Default() bool       { return true }
+
+func (headingJumpRule) Check(ctx *Context, s api.Set, md *metadata.Metadata) []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	lines := metadata.NewLineIndex(*s.Data())
+	previous := 0
+	for _, h := range md.Headings {
+		if previous > 0 && h.Level > previous+1 {
+			hashes := api.Match{Begin: h.Match.Begin, End: h.Match.Begin + h.Level}
+			fix := replaceFix(hashes, strings.Repeat("#", previous+1))
+			diagnostics = append(diagnostics, diagnose(lines, hashes, SEVERITY_WARNING, fix,
+				"heading jumps from level %d to %d", previous, h.Level))
+			previous += 1
+			continue
+		}
+		previous = h.Level
+	}
+	return diagnostics
+}
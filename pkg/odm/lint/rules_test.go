@@ -0,0 +1,46 @@
+package lint
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+func TestTrailingWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"clean", "one\ntwo\n", "one\ntwo\n"},
+		{"spaces", "one \ntwo\t\n", "one\ntwo\n"},
+		{"crlf", "one \r\ntwo\r\n", "one\r\ntwo\r\n"},
+		{"hard break", "one  \ntwo\n", "one  \ntwo\n"},
+		{"long hard break", "one    \ntwo\n", "one  \ntwo\n"},
+		{"end of paragraph", "one  \n\ntwo  \n", "one\n\ntwo\n"},
+		{"before heading", "one  \n# two\n", "one\n# two\n"},
+		{"list hard break", "- one  \n  two\n", "- one  \n  two\n"},
+		{"frontmatter", "---\ntitle: a  \n---\nbody \n", "---\ntitle: a  \n---\nbody\n"},
+		{"code block", "```\ncode  \n```\ntext \n", "```\ncode  \n```\ntext\n"},
+		{"comment", "%%\nhidden \n%%\n", "%%\nhidden \n%%\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := odm.NewSetFromReader(bytes.NewBufferString(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			md := metadata.Parse("Note.md", *s.Data())
+			diagnostics := trailingWhitespaceRule{}.Check(nil, s, md)
+			if _, err := ApplyFixes(s, diagnostics); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(*s.Data()); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package metadata
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// The ways of writing the inline fields.
+const (
+	// A 'key:: value' line.
+	FieldLine int = 0
+
+	// A '[key:: value]' anywhere in the text, the key is shown.
+	FieldBracket int = 1
+
+	// A '(key:: value)' anywhere in the text, the key is hidden.
+	FieldParen int = 2
+)
+
+// A Dataview inline field.
+type InlineField struct {
+	// The key as it is written.
+	Key string
+
+	// The value without the spaces around it.
+	Value string
+
+	// One of the Field* constants.
+	Syntax int
+
+	// Covers the whole field, the brackets included. For the lines it starts at the
+	// key and ends before the new line.
+	Match api.Match
+
+	// Cover the key and the value. The value may be empty.
+	KeyMatch   api.Match
+	ValueMatch api.Match
+
+	Line int
+}
+
+// The list marker, the checkbox and the quote markers a field line can start with.
+var fieldLineRegex = regexp.MustCompile(`(?m)^[ \t]*(?:>[ \t]?)*[ \t]*(?:(?:[-*+]|\d+[.)])[ \t]+(?:\[.\][ \t]+)?)?([^\s\[\]()>:*+\-][^\[\]():\n]*?)::[ \t]*(.*?)[ \t]*\r?$`)
+
+// Gets the key as Dataview refers to it: in lower case, the spaces as dashes and
+// without the other punctuation, e.g. 'Due Date' is 'due-date'.
+func CanonicalKey(key string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(strings.ToLower(key)) {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Parses the inline fields of the note, in the order they are written. The fields in
+// the frontmatter, the code blocks and the comments are skipped.
+func ParseInlineFields(data []byte) []InlineField {
+	fm := ParseFrontmatter(data)
+	return parseInlineFields(data, fm.Match.End, FindVerbatim(data, fm.Match.End), NewLineIndex(data))
+}
+
+func parseInlineFields(data []byte, bodyBegin int, verbatim []api.Match, lines LineIndex) []InlineField {
+	fields := make([]InlineField, 0)
+	for _, m := range fieldLineRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[2]
+		if _, ok := inside(verbatim, begin); ok {
+			continue
+		}
+		key := api.Match{Begin: begin, End: bodyBegin + m[3]}
+		value := api.Match{Begin: bodyBegin + m[4], End: bodyBegin + m[5]}
+		if strings.Contains(string(data[key.Begin:key.End]), "`") {
+			continue
+		}
+		line, _ := lines.Position(begin)
+		fields = append(fields, InlineField{
+			Key:        strings.TrimSpace(string(data[key.Begin:key.End])),
+			Value:      string(data[value.Begin:value.End]),
+			Syntax:     FieldLine,
+			Match:      api.Match{Begin: begin, End: value.End},
+			KeyMatch:   trimMatch(data, key),
+			ValueMatch: value,
+			Line:       line,
+		})
+	}
+
+	for i := bodyBegin; i < len(data); i++ {
+		if j, ok := inside(verbatim, i); ok {
+			i = j - 1
+			continue
+		}
+		if data[i] != '[' && data[i] != '(' {
+			continue
+		}
+		// The wikilinks and the checkboxes are not fields.
+		if data[i] == '[' && (bytes.HasPrefix(data[i:], []byte("[[")) || (i > 0 && data[i-1] == '[')) {
+			continue
+		}
+		if f, ok := parseEnclosedField(data, i); ok {
+			f.Line, _ = lines.Position(i)
+			fields = append(fields, f)
+			i = f.Match.End - 1
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Match.Begin < fields[j].Match.Begin
+	})
+	return fields
+}
+
+// Parses the '[key:: value]' or the '(key:: value)' field starting at the offset.
+func parseEnclosedField(data []byte, begin int) (InlineField, bool) {
+	open := data[begin]
+	close := byte(']')
+	syntax := FieldBracket
+	if open == '(' {
+		close, syntax = ')', FieldParen
+	}
+
+	sep := -1
+	for i := begin + 1; i+1 < len(data); i++ {
+		c := data[i]
+		if c == '\n' || c == '[' || c == ']' || c == '(' || c == ')' {
+			return InlineField{}, false
+		}
+		if c == ':' && data[i+1] == ':' {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 {
+		return InlineField{}, false
+	}
+	key := trimMatch(data, api.Match{Begin: begin + 1, End: sep})
+	if key.Begin == key.End || strings.ContainsAny(string(data[key.Begin:key.End]), "`*") {
+		return InlineField{}, false
+	}
+
+	// The value ends at the closing bracket of the same depth, so it can have links.
+	depth := 0
+	for i := sep + 2; i < len(data); i++ {
+		switch data[i] {
+		case '\n':
+			return InlineField{}, false
+		case '[', '(':
+			depth++
+		case ']', ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if data[i] != close {
+				return InlineField{}, false
+			}
+			value := trimMatch(data, api.Match{Begin: sep + 2, End: i})
+			return InlineField{
+				Key:        string(data[key.Begin:key.End]),
+				Value:      string(data[value.Begin:value.End]),
+				Syntax:     syntax,
+				Match:      api.Match{Begin: begin, End: i + 1},
+				KeyMatch:   key,
+				ValueMatch: value,
+			}, true
+		}
+	}
+	return InlineField{}, false
+}
+
+// Shrinks the match so it doesn't start or end with spaces. An empty match stays where
+// its text starts.
+func trimMatch(data []byte, m api.Match) api.Match {
+	for m.Begin < m.End && (data[m.Begin] == ' ' || data[m.Begin] == '\t') {
+		m.Begin++
+	}
+	for m.End > m.Begin && (data[m.End-1] == ' ' || data[m.End-1] == '\t') {
+		m.End--
+	}
+	return m
+}
@@ -0,0 +1,251 @@
+package metadata
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+const (
+	// The property has no value, e.g. 'key:'.
+	PropertyEmpty int = 0
+
+	// The property has a single value, e.g. 'key: value'.
+	PropertyScalar int = 1
+
+	// The property is a list, either inline '[a, b]' or block '- a'.
+	PropertyList int = 2
+
+	// The property is something else, e.g. a nested map. Only the raw text is kept.
+	PropertyNested int = 3
+)
+
+// A single top level frontmatter property. Only the subset of YAML used by Obsidian is
+// understood, anything else is kept as raw text.
+type Property struct {
+	Key string
+
+	// One of the Property* constants.
+	Kind int
+
+	// The unquoted value for scalars, the raw text otherwise.
+	Value string
+
+	// The items of the list properties.
+	Items []string
+
+	// Whether the list is written as '[a, b]'.
+	Inline bool
+
+	// Covers the whole property including the trailing new line.
+	Match api.Match
+
+	// Covers only the value, for block lists this covers all of the item lines.
+	ValueMatch api.Match
+
+	// The 1 based line of the key.
+	Line int
+}
+
+// The frontmatter of a note, the YAML block between the '---' lines.
+type Frontmatter struct {
+	// Whether the note has a frontmatter at all.
+	Present bool
+
+	// Covers the whole frontmatter including the fences.
+	Match api.Match
+
+	// Covers only the contents between the fences.
+	Body api.Match
+
+	Properties []Property
+}
+
+var propertyRegex = regexp.MustCompile(`^([^\s:#\-][^:]*?|"[^"]*"|'[^']*'):(?:[ \t]+(.*?))?[ \t]*$`)
+
+// Gets the property with the given key.
+func (fm *Frontmatter) Get(key string) (Property, bool) {
+	for _, p := range fm.Properties {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Gets the values of the property as a list. Scalars are split on commas since that's
+// a common way of writing tags and aliases by hand.
+func (p Property) List() []string {
+	switch p.Kind {
+	case PropertyList:
+		return p.Items
+	case PropertyScalar:
+		values := make([]string, 0)
+		for _, v := range strings.Split(p.Value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
+
+// Parses the frontmatter at the very beginning of the data, returns an empty one if
+// there is none.
+func ParseFrontmatter(data []byte) Frontmatter {
+	fm := Frontmatter{Properties: make([]Property, 0)}
+
+	first := bytes.IndexByte(data, '\n')
+	if first < 0 || strings.TrimRight(string(data[:first]), "\r ") != "---" {
+		return fm
+	}
+
+	// Find the closing fence.
+	bodyBegin := first + 1
+	bodyEnd := -1
+	end := -1
+	for pos := bodyBegin; pos < len(data); {
+		next := len(data)
+		if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+			next = pos + i + 1
+		}
+		line := strings.TrimRight(string(data[pos:next]), "\r\n ")
+		if line == "---" || line == "..." {
+			bodyEnd, end = pos, next
+			break
+		}
+		pos = next
+	}
+
+	if end < 0 {
+		return fm
+	}
+
+	fm.Present = true
+	fm.Match = api.Match{Begin: 0, End: end}
+	fm.Body = api.Match{Begin: bodyBegin, End: bodyEnd}
+
+	line := 2
+	var current *Property
+	for pos := bodyBegin; pos < bodyEnd; line++ {
+		next := bodyEnd
+		if i := bytes.IndexByte(data[pos:bodyEnd], '\n'); i >= 0 {
+			next = pos + i + 1
+		}
+		text := strings.TrimRight(string(data[pos:next]), "\r\n")
+
+		if m := propertyRegex.FindStringSubmatchIndex(text); m != nil {
+			if current != nil {
+				fm.Properties = append(fm.Properties, *current)
+			}
+			current = &Property{
+				Key:   unquote(text[m[2]:m[3]]),
+				Kind:  PropertyEmpty,
+				Items: make([]string, 0),
+				Match: api.Match{Begin: pos, End: next},
+				Line:  line,
+			}
+			if m[4] >= 0 && m[5] > m[4] {
+				current.ValueMatch = api.Match{Begin: pos + m[4], End: pos + m[5]}
+				setScalarOrInline(current, text[m[4]:m[5]])
+			} else {
+				current.ValueMatch = api.Match{Begin: next, End: next}
+			}
+		} else if current != nil && strings.TrimSpace(text) != "" && !strings.HasPrefix(text, "#") {
+			// A continuation of the current property.
+			if current.Kind == PropertyEmpty {
+				current.ValueMatch = api.Match{Begin: pos, End: pos}
+				if isListItem(text) {
+					current.Kind = PropertyList
+				} else {
+					current.Kind = PropertyNested
+				}
+			}
+
+			if current.Kind == PropertyList && isListItem(text) {
+				item := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "-"))
+				current.Items = append(current.Items, unquote(item))
+			} else {
+				current.Kind = PropertyNested
+			}
+
+			current.Match.End = next
+			current.ValueMatch.End = next
+			current.Value = strings.TrimRight(string(data[current.ValueMatch.Begin:current.ValueMatch.End]), "\r\n")
+		}
+
+		pos = next
+	}
+	if current != nil {
+		fm.Properties = append(fm.Properties, *current)
+	}
+
+	return fm
+}
+
+func isListItem(text string) bool {
+	t := strings.TrimLeft(text, " \t")
+	return t == "-" || strings.HasPrefix(t, "- ")
+}
+
+func setScalarOrInline(p *Property, raw string) {
+	if strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]") {
+		p.Kind = PropertyList
+		p.Inline = true
+		p.Value = raw
+		for _, item := range splitInlineList(raw[1 : len(raw)-1]) {
+			p.Items = append(p.Items, item)
+		}
+		return
+	}
+
+	p.Kind = PropertyScalar
+	p.Value = unquote(raw)
+}
+
+// Splits an inline YAML list on the commas which are not quoted.
+func splitInlineList(s string) []string {
+	items := make([]string, 0)
+	var quote byte
+	begin := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			c := s[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+				continue
+			}
+			if c != ',' {
+				continue
+			}
+		}
+		if item := strings.TrimSpace(s[begin:i]); item != "" {
+			items = append(items, unquote(item))
+		}
+		begin = i + 1
+	}
+	return items
+}
+
+// Removes the surrounding quotes of a YAML scalar.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		if s[0] == '"' && s[len(s)-1] == '"' {
+			return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+		}
+		if s[0] == '\'' && s[len(s)-1] == '\'' {
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package metadata
+
+import "sort"
+
+// Maps byte offsets to line and column numbers. Both are 1 based and the column is
+// counted in bytes, like most compilers do.
+type LineIndex struct {
+	starts []int
+}
+
+func NewLineIndex(data []byte) LineIndex {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return LineIndex{starts: starts}
+}
+
+// Gets the line and the column of the given offset.
+func (li LineIndex) Position(offset int) (int, int) {
+	line := sort.Search(len(li.starts), func(i int) bool {
+		return li.starts[i] > offset
+	}) - 1
+	if line < 0 {
+		line = 0
+	}
+	return line + 1, offset - li.starts[line] + 1
+}
+
+// Gets the offset of the first byte of the given 1 based line.
+func (li LineIndex) LineStart(line int) int {
+	if line < 1 {
+		return 0
+	}
+	if line > len(li.starts) {
+		return li.starts[len(li.starts)-1]
+	}
+	return li.starts[line-1]
+}
+
+// Returns the number of lines.
+func (li LineIndex) Lines() int {
+	return len(li.starts)
+}
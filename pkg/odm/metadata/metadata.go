@@ -0,0 +1,358 @@
+package metadata
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+const (
+	// A '[[target#anchor|alias]]' link.
+	LinkWiki int = 1
+
+	// A '[alias](target#anchor)' link.
+	LinkMarkdown int = 2
+)
+
+type Heading struct {
+	// Between 1 and 6.
+	Level int
+
+	// The text without the leading hashes.
+	Text string
+
+	// Covers the whole heading line without the new line.
+	Match api.Match
+
+	Line int
+}
+
+type Link struct {
+	// One of the Link* constants.
+	Kind int
+
+	// Whether this is an embed, the ones starting with a '!'.
+	Embed bool
+
+	// The path or the name of the linked note without the anchor. For markdown links
+	// this is url decoded.
+	Target string
+
+	// The heading or the block reference after the '#', block references start
+	// with a '^'.
+	Anchor string
+
+	// The display text. Empty for wikilinks without an alias.
+	Alias string
+
+	// Whether the markdown link points to an url, e.g. 'https://'.
+	External bool
+
+	// Covers the whole link.
+	Match api.Match
+
+	Line int
+}
+
+type Tag struct {
+	// The tag without the '#'.
+	Name string
+
+	// Covers the tag including the '#'. It is empty for the frontmatter tags.
+	Match api.Match
+
+	Line int
+}
+
+type Block struct {
+	// The block identifier without the '^'.
+	ID string
+
+	// Covers the ' ^id' suffix.
+	Match api.Match
+
+	Line int
+}
+
+// The parsed metadata of a single note.
+type Metadata struct {
+	// The path of the note relative to the vault.
+	Path string
+
+	// The base name of the note without the extension.
+	Title string
+
+	Frontmatter Frontmatter
+
+	Headings []Heading
+
+	Links []Link
+
+	// Only the tags in the body, use AllTags to include the frontmatter ones.
+	Tags []Tag
+
+	Blocks []Block
+
+	// The Dataview inline fields, e.g. 'key:: value'.
+	Fields []InlineField
+
+	// The regions where the markdown syntax doesn't apply, e.g. code blocks, inline
+	// code and comments. Sorted and not overlapping.
+	Verbatim []api.Match
+}
+
+var (
+	headingRegex  = regexp.MustCompile(`(?m)^(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*\r?$`)
+	wikilinkRegex = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+	mdLinkRegex   = regexp.MustCompile(`(!?)\[((?:[^\[\]\n]|\[[^\[\]\n]*\])*)\]\(([^()\n]*(?:\([^()\n]*\)[^()\n]*)*)\)`)
+	tagRegex      = regexp.MustCompile(`(?:^|[\s(,;])(#[\p{L}\p{N}_/\-]*[\p{L}_/\-][\p{L}\p{N}_/\-]*)`)
+	blockRegex    = regexp.MustCompile(`(?m)[ \t]\^([A-Za-z0-9\-]+)[ \t]*\r?$`)
+	schemeRegex   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.\-]*:`)
+)
+
+// Parses the metadata of the note. The path is relative to the vault.
+func Parse(notePath string, data []byte) *Metadata {
+	md := &Metadata{
+		Path:     notePath,
+		Title:    strings.TrimSuffix(path.Base(notePath), path.Ext(notePath)),
+		Headings: make([]Heading, 0),
+		Links:    make([]Link, 0),
+		Tags:     make([]Tag, 0),
+		Blocks:   make([]Block, 0),
+	}
+
+	md.Frontmatter = ParseFrontmatter(data)
+	bodyBegin := md.Frontmatter.Match.End
+
+	md.Verbatim = FindVerbatim(data, bodyBegin)
+	lines := NewLineIndex(data)
+
+	for _, m := range headingRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[0]
+		if _, ok := inside(md.Verbatim, begin); ok {
+			continue
+		}
+		line, _ := lines.Position(begin)
+		md.Headings = append(md.Headings, Heading{
+			Level: m[3] - m[2],
+			Text:  string(data[bodyBegin+m[4] : bodyBegin+m[5]]),
+			Match: api.Match{Begin: begin, End: bodyBegin + m[1]},
+			Line:  line,
+		})
+	}
+
+	for _, m := range wikilinkRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[0]
+		if _, ok := inside(md.Verbatim, begin); ok {
+			continue
+		}
+		link := parseWikilink(string(data[bodyBegin+m[4] : bodyBegin+m[5]]))
+		link.Embed = m[3] > m[2]
+		link.Match = api.Match{Begin: begin, End: bodyBegin + m[1]}
+		link.Line, _ = lines.Position(begin)
+		md.Links = append(md.Links, link)
+	}
+
+	for _, m := range mdLinkRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[0]
+		if _, ok := inside(md.Verbatim, begin); ok {
+			continue
+		}
+		link := parseMarkdownLink(string(data[bodyBegin+m[4]:bodyBegin+m[5]]), string(data[bodyBegin+m[6]:bodyBegin+m[7]]))
+		link.Embed = m[3] > m[2]
+		link.Match = api.Match{Begin: begin, End: bodyBegin + m[1]}
+		link.Line, _ = lines.Position(begin)
+		md.Links = append(md.Links, link)
+	}
+	sortLinks(md.Links)
+
+	for _, m := range tagRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[2]
+		if _, ok := inside(md.Verbatim, begin); ok || insideLink(md.Links, begin) {
+			continue
+		}
+		line, _ := lines.Position(begin)
+		md.Tags = append(md.Tags, Tag{
+			Name:  string(data[begin+1 : bodyBegin+m[3]]),
+			Match: api.Match{Begin: begin, End: bodyBegin + m[3]},
+			Line:  line,
+		})
+	}
+
+	for _, m := range blockRegex.FindAllSubmatchIndex(data[bodyBegin:], -1) {
+		begin := bodyBegin + m[0]
+		if _, ok := inside(md.Verbatim, begin); ok {
+			continue
+		}
+		line, _ := lines.Position(begin)
+		md.Blocks = append(md.Blocks, Block{
+			ID:    string(data[bodyBegin+m[2] : bodyBegin+m[3]]),
+			Match: api.Match{Begin: begin, End: bodyBegin + m[3]},
+			Line:  line,
+		})
+	}
+
+	md.Fields = parseInlineFields(data, bodyBegin, md.Verbatim, lines)
+	return md
+}
+
+// Finds the regions starting from the given offset where the markdown syntax doesn't
+// apply, these are code blocks, inline code, '%%' comments and html comments.
+func FindVerbatim(data []byte, from int) []api.Match {
+	verbatim := mergeMatches(findFences(data, from))
+	verbatim = mergeMatches(append(verbatim, findDelimited(data, from, []byte("%%"), []byte("%%"), verbatim)...))
+	verbatim = mergeMatches(append(verbatim, findDelimited(data, from, []byte("<!--"), []byte("-->"), verbatim)...))
+	verbatim = mergeMatches(append(verbatim, findInlineCode(data, from, verbatim)...))
+	return verbatim
+}
+
+// Finds the regions starting from the given offset where the html comments are not
+// comments, these are code blocks, inline code and '%%' comments.
+func FindCode(data []byte, from int) []api.Match {
+	code := mergeMatches(findFences(data, from))
+	code = mergeMatches(append(code, findDelimited(data, from, []byte("%%"), []byte("%%"), code)...))
+	code = mergeMatches(append(code, findInlineCode(data, from, code)...))
+	return code
+}
+
+// Checks if the offset is inside one of the sorted matches.
+func Inside(mm []api.Match, offset int) bool {
+	_, ok := inside(mm, offset)
+	return ok
+}
+
+// Gets all of the tags including the ones in the 'tags' frontmatter property. Tags
+// are returned without the '#' and in the order they appear.
+func (md *Metadata) AllTags() []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(t string) {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "#")
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+
+	for _, key := range []string{"tags", "tag"} {
+		if p, ok := md.Frontmatter.Get(key); ok {
+			for _, t := range p.List() {
+				for _, f := range strings.Fields(t) {
+					add(f)
+				}
+			}
+		}
+	}
+	for _, t := range md.Tags {
+		add(t.Name)
+	}
+	return tags
+}
+
+// Checks if the note has the tag or one of its nested tags, e.g. 'project' matches
+// 'project/active'. The comparison is case insensitive like in Obsidian.
+func (md *Metadata) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	for _, t := range md.AllTags() {
+		t = strings.ToLower(t)
+		if t == tag || strings.HasPrefix(t, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the section under the i-th heading, from the line after the heading until the
+// next heading of the same or a higher level.
+func (md *Metadata) Section(i int, data []byte) api.Match {
+	h := md.Headings[i]
+	begin := h.Match.End
+	if begin < len(data) && data[begin] == '\n' {
+		begin += 1
+	}
+	end := len(data)
+	for _, next := range md.Headings[i+1:] {
+		if next.Level <= h.Level {
+			end = next.Match.Begin
+			break
+		}
+	}
+	return api.Match{Begin: begin, End: end}
+}
+
+// Gets the aliases defined in the frontmatter.
+func (md *Metadata) Aliases() []string {
+	for _, key := range []string{"aliases", "alias"} {
+		if p, ok := md.Frontmatter.Get(key); ok {
+			return p.List()
+		}
+	}
+	return []string{}
+}
+
+func parseWikilink(inner string) Link {
+	link := Link{Kind: LinkWiki}
+
+	if i := strings.Index(inner, "|"); i >= 0 {
+		link.Alias = inner[i+1:]
+		inner = inner[:i]
+	}
+	if i := strings.Index(inner, "#"); i >= 0 {
+		link.Anchor = inner[i+1:]
+		inner = inner[:i]
+	}
+	link.Target = strings.TrimSpace(inner)
+	return link
+}
+
+func parseMarkdownLink(text, dest string) Link {
+	link := Link{Kind: LinkMarkdown, Alias: text}
+
+	dest = strings.TrimSpace(dest)
+	if strings.HasPrefix(dest, "<") {
+		if i := strings.Index(dest, ">"); i > 0 {
+			dest = dest[1:i]
+		}
+	} else if i := strings.IndexAny(dest, " \t"); i >= 0 {
+		// Drop the optional title, e.g. '(target "title")'.
+		dest = dest[:i]
+	}
+
+	link.External = schemeRegex.MatchString(dest)
+	if !link.External {
+		if i := strings.Index(dest, "#"); i >= 0 {
+			link.Anchor = decode(dest[i+1:])
+			dest = dest[:i]
+		}
+		dest = decode(dest)
+	}
+	link.Target = dest
+	return link
+}
+
+func decode(s string) string {
+	if d, err := url.PathUnescape(s); err == nil {
+		return d
+	}
+	return s
+}
+
+func sortLinks(links []Link) {
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Match.Begin < links[j].Match.Begin
+	})
+}
+
+func insideLink(links []Link, offset int) bool {
+	for _, l := range links {
+		if l.Match.Begin <= offset && offset < l.Match.End {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// Finds the fenced code blocks. The returned matches cover the whole block including the
+// fence lines. An unterminated fence runs until the end of the data, like Obsidian does.
+func findFences(data []byte, from int) []api.Match {
+	fences := make([]api.Match, 0)
+
+	open := -1
+	var fenceChar byte
+	fenceLen := 0
+
+	for pos := from; pos < len(data); {
+		end := bytes.IndexByte(data[pos:], '\n')
+		next := len(data)
+		if end >= 0 {
+			next = pos + end + 1
+		}
+		line := bytes.TrimRight(data[pos:next], "\r\n")
+		trimmed := bytes.TrimLeft(line, " ")
+
+		if len(line)-len(trimmed) <= 3 && len(trimmed) >= 3 && (trimmed[0] == '`' || trimmed[0] == '~') {
+			c := trimmed[0]
+			n := 0
+			for n < len(trimmed) && trimmed[n] == c {
+				n++
+			}
+
+			if open < 0 && n >= 3 {
+				open, fenceChar, fenceLen = pos, c, n
+			} else if open >= 0 && c == fenceChar && n >= fenceLen && len(bytes.TrimSpace(trimmed[n:])) == 0 {
+				fences = append(fences, api.Match{Begin: open, End: next})
+				open = -1
+			}
+		}
+
+		pos = next
+	}
+
+	if open >= 0 {
+		fences = append(fences, api.Match{Begin: open, End: len(data)})
+	}
+
+	return fences
+}
+
+// Finds the inline code spans, the ones surrounded by the same number of backticks.
+func findInlineCode(data []byte, from int, skip []api.Match) []api.Match {
+	spans := make([]api.Match, 0)
+
+	for i := from; i < len(data); {
+		if j, ok := inside(skip, i); ok {
+			i = j
+			continue
+		}
+		if data[i] != '`' {
+			i++
+			continue
+		}
+
+		n := 0
+		for i+n < len(data) && data[i+n] == '`' {
+			n++
+		}
+
+		// Look for the closing run with the exact same length, code spans can't
+		// contain empty lines.
+		closed := false
+		for k := i + n; k < len(data); {
+			if data[k] == '\n' && k+1 < len(data) && data[k+1] == '\n' {
+				break
+			}
+			if data[k] != '`' {
+				k++
+				continue
+			}
+			m := 0
+			for k+m < len(data) && data[k+m] == '`' {
+				m++
+			}
+			if m == n {
+				spans = append(spans, api.Match{Begin: i, End: k + m})
+				i = k + m
+				closed = true
+				break
+			}
+			k += m
+		}
+
+		if !closed {
+			i += n
+		}
+	}
+
+	return spans
+}
+
+// Finds the regions between the given open and close markers, e.g. '%%' comments. An
+// unterminated region runs until the end of the data.
+func findDelimited(data []byte, from int, open, close []byte, skip []api.Match) []api.Match {
+	regions := make([]api.Match, 0)
+
+	for i := from; i < len(data); {
+		if j, ok := inside(skip, i); ok {
+			i = j
+			continue
+		}
+		if !bytes.HasPrefix(data[i:], open) {
+			i++
+			continue
+		}
+
+		end := bytes.Index(data[i+len(open):], close)
+		if end < 0 {
+			regions = append(regions, api.Match{Begin: i, End: len(data)})
+			break
+		}
+
+		j := i + len(open) + end + len(close)
+		regions = append(regions, api.Match{Begin: i, End: j})
+		i = j
+	}
+
+	return regions
+}
+
+// Checks if the offset is in one of the sorted matches, if so also returns the end.
+func inside(mm []api.Match, offset int) (int, bool) {
+	i := sort.Search(len(mm), func(i int) bool {
+		return mm[i].End > offset
+	})
+	if i < len(mm) && mm[i].Begin <= offset {
+		return mm[i].End, true
+	}
+	return 0, false
+}
+
+// Sorts and merges the overlapping matches.
+func mergeMatches(mm []api.Match) []api.Match {
+	sort.Slice(mm, func(i, j int) bool {
+		return mm[i].Begin < mm[j].Begin
+	})
+
+	merged := make([]api.Match, 0, len(mm))
+	for _, m := range mm {
+		if n := len(merged); n > 0 && m.Begin <= merged[n-1].End {
+			if m.End > merged[n-1].End {
+				merged[n-1].End = m.End
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
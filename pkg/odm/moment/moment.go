@@ -0,0 +1,281 @@
+package moment
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The week rules of the locale weeks, the ones of the 'en' locale Obsidian uses by
+// default: the weeks start on Sunday and the first week has the 1st of January.
+const (
+	LOCALE_DOW int = 0
+	LOCALE_DOY int = 6
+)
+
+// The week rules of the ISO weeks: the weeks start on Monday and the first week has
+// the 4th of January.
+const (
+	ISO_DOW int = 1
+	ISO_DOY int = 4
+)
+
+var (
+	months   = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	weekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+)
+
+// The tokens of the moment.js format strings, the longer ones first. The text in
+// square brackets is a literal.
+var tokenRegex = regexp.MustCompile(`\[[^\]]*\]|YYYY|YY|Y|gggg|gg|GGGG|GG|Qo|Q|MMMM|MMM|Mo|MM|M|DDDD|DDDo|DDD|Do|DD|D|dddd|ddd|do|dd|d|E|e|Wo|WW|W|wo|ww|w|HH|H|hh|h|kk|k|mm|m|ss|s|S{1,9}|A|a|ZZ|Z|X|x`)
+
+// A piece of a format string, either a token or a literal text.
+type token struct {
+	text    string
+	literal bool
+}
+
+// Splits the format string into its tokens and literals.
+func tokenize(layout string) []token {
+	tokens := make([]token, 0)
+	pos := 0
+	for _, m := range tokenRegex.FindAllStringIndex(layout, -1) {
+		if pos < m[0] {
+			tokens = append(tokens, token{text: layout[pos:m[0]], literal: true})
+		}
+		text := layout[m[0]:m[1]]
+		if strings.HasPrefix(text, "[") {
+			tokens = append(tokens, token{text: text[1 : len(text)-1], literal: true})
+		} else {
+			tokens = append(tokens, token{text: text})
+		}
+		pos = m[1]
+	}
+	if pos < len(layout) {
+		tokens = append(tokens, token{text: layout[pos:], literal: true})
+	}
+	return tokens
+}
+
+// Formats the time with a moment.js format string like 'YYYY-MM-DD' or
+// 'gggg-[W]ww'. The names are in English whatever the locale is.
+func Format(t time.Time, layout string) string {
+	var b strings.Builder
+	for _, tk := range tokenize(layout) {
+		if tk.literal {
+			b.WriteString(tk.text)
+		} else {
+			b.WriteString(formatToken(t, tk.text))
+		}
+	}
+	return b.String()
+}
+
+func formatToken(t time.Time, tk string) string {
+	switch tk {
+	case "YYYY":
+		return pad(t.Year(), 4)
+	case "YY":
+		return pad(t.Year()%100, 2)
+	case "Y":
+		return strconv.Itoa(t.Year())
+	case "gggg", "gg", "GGGG", "GG":
+		year, _ := Week(t, LOCALE_DOW, LOCALE_DOY)
+		if tk[0] == 'G' {
+			year, _ = Week(t, ISO_DOW, ISO_DOY)
+		}
+		if len(tk) == 2 {
+			return pad(year%100, 2)
+		}
+		return pad(year, 4)
+	case "Q":
+		return strconv.Itoa(Quarter(t))
+	case "Qo":
+		return Ordinal(Quarter(t))
+	case "MMMM":
+		return months[t.Month()-1]
+	case "MMM":
+		return months[t.Month()-1][:3]
+	case "MM":
+		return pad(int(t.Month()), 2)
+	case "Mo":
+		return Ordinal(int(t.Month()))
+	case "M":
+		return strconv.Itoa(int(t.Month()))
+	case "DDDD":
+		return pad(t.YearDay(), 3)
+	case "DDDo":
+		return Ordinal(t.YearDay())
+	case "DDD":
+		return strconv.Itoa(t.YearDay())
+	case "DD":
+		return pad(t.Day(), 2)
+	case "Do":
+		return Ordinal(t.Day())
+	case "D":
+		return strconv.Itoa(t.Day())
+	case "dddd":
+		return weekdays[t.Weekday()]
+	case "ddd":
+		return weekdays[t.Weekday()][:3]
+	case "dd":
+		return weekdays[t.Weekday()][:2]
+	case "do":
+		return Ordinal(int(t.Weekday()))
+	case "d":
+		return strconv.Itoa(int(t.Weekday()))
+	case "e":
+		return strconv.Itoa((int(t.Weekday()) - LOCALE_DOW + 7) % 7)
+	case "E":
+		return strconv.Itoa((int(t.Weekday())+6)%7 + 1)
+	case "WW", "W", "Wo":
+		_, week := Week(t, ISO_DOW, ISO_DOY)
+		return formatNumber(week, tk)
+	case "ww", "w", "wo":
+		_, week := Week(t, LOCALE_DOW, LOCALE_DOY)
+		return formatNumber(week, tk)
+	case "HH":
+		return pad(t.Hour(), 2)
+	case "H":
+		return strconv.Itoa(t.Hour())
+	case "hh", "h":
+		h := t.Hour() % 12
+		if h == 0 {
+			h = 12
+		}
+		return formatNumber(h, tk)
+	case "kk", "k":
+		k := t.Hour()
+		if k == 0 {
+			k = 24
+		}
+		return formatNumber(k, tk)
+	case "mm":
+		return pad(t.Minute(), 2)
+	case "m":
+		return strconv.Itoa(t.Minute())
+	case "ss":
+		return pad(t.Second(), 2)
+	case "s":
+		return strconv.Itoa(t.Second())
+	case "A":
+		if t.Hour() < 12 {
+			return "AM"
+		}
+		return "PM"
+	case "a":
+		if t.Hour() < 12 {
+			return "am"
+		}
+		return "pm"
+	case "Z", "ZZ":
+		_, offset := t.Zone()
+		sign := "+"
+		if offset < 0 {
+			sign, offset = "-", -offset
+		}
+		sep := ":"
+		if tk == "ZZ" {
+			sep = ""
+		}
+		return fmt.Sprintf("%s%02d%s%02d", sign, offset/3600, sep, offset/60%60)
+	case "X":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "x":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	// The fractions of a second.
+	if tk[0] == 'S' {
+		return pad(t.Nanosecond(), 9)[:len(tk)]
+	}
+	return tk
+}
+
+// Formats the number padded to two digits for the doubled tokens, as an ordinal for
+// the ones ending with 'o'.
+func formatNumber(n int, tk string) string {
+	if strings.HasSuffix(tk, "o") {
+		return Ordinal(n)
+	}
+	if len(tk) == 2 {
+		return pad(n, 2)
+	}
+	return strconv.Itoa(n)
+}
+
+func pad(n, width int) string {
+	s := strconv.Itoa(n)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
+
+// Gets the English ordinal of the number, e.g. '1st' or '12th'.
+func Ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// Gets the quarter of the year, between 1 and 4.
+func Quarter(t time.Time) int {
+	return (int(t.Month())-1)/3 + 1
+}
+
+// Gets the week year and the week of the day with the week rules: the weeks start on
+// the dow day of the week, and the first week of the year has the doy+1-dow th of
+// January, the way moment.js counts them.
+func Week(t time.Time, dow, doy int) (int, int) {
+	year := t.Year()
+	offset := firstWeekOffset(year, dow, doy)
+	week := (t.YearDay()-offset-1)/7 + 1
+	if t.YearDay()-offset-1 < 0 {
+		week = 0
+	}
+
+	if week < 1 {
+		return year - 1, week + weeksInYear(year-1, dow, doy)
+	}
+	if n := weeksInYear(year, dow, doy); week > n {
+		return year + 1, week - n
+	}
+	return year, week
+}
+
+// Gets how many days after the 1st of January the first week starts, negative if it
+// starts in December.
+func firstWeekOffset(year, dow, doy int) int {
+	fwd := 7 + dow - doy
+	weekday := int(time.Date(year, time.January, fwd, 0, 0, 0, 0, time.UTC).Weekday())
+	return -((7 + weekday - dow) % 7) + fwd - 1
+}
+
+// Gets the number of the weeks in the week year.
+func weeksInYear(year, dow, doy int) int {
+	offset := firstWeekOffset(year, dow, doy)
+	next := firstWeekOffset(year+1, dow, doy)
+	return (daysInYear(year) - offset + next) / 7
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// Gets the first day of the week of the week year, with the week rules of Week.
+func WeekStart(year, week, dow, doy int, loc *time.Location) time.Time {
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	return first.AddDate(0, 0, firstWeekOffset(year, dow, doy)+7*(week-1))
+}
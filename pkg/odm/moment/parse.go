@@ -0,0 +1,320 @@
+package moment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The parts of a date read by Parse, -1 where they are not given.
+type parts struct {
+	year, month, day, yearDay         int
+	hour, minute, second, nanos       int
+	pm                                int
+	isoYear, isoWeek, isoDay          int
+	localeYear, localeWeek, localeDay int
+	quarter                           int
+	zone                              *time.Location
+	unix                              int64
+	unixSet                           bool
+}
+
+// Parses the value with a moment.js format string, the reverse of Format. The value
+// must match the whole format, the literal parts as they are and the names in any
+// case. The parts which are not in the format are the start of the period, e.g. a
+// 'gggg-[W]ww' value is the first day of the week; a missing year is this year. The
+// time is in the location unless the format has a time zone.
+func Parse(layout, value string, loc *time.Location) (time.Time, error) {
+	p := parts{
+		year: -1, month: -1, day: -1, yearDay: -1,
+		hour: -1, minute: -1, second: -1, nanos: -1, pm: -1,
+		isoYear: -1, isoWeek: -1, isoDay: -1,
+		localeYear: -1, localeWeek: -1, localeDay: -1,
+		quarter: -1,
+	}
+	fail := func() (time.Time, error) {
+		return time.Time{}, fmt.Errorf("'%s' doesn't match the date format '%s'", value, layout)
+	}
+
+	rest := value
+	for _, tk := range tokenize(layout) {
+		if tk.literal {
+			if !strings.HasPrefix(rest, tk.text) {
+				return fail()
+			}
+			rest = rest[len(tk.text):]
+			continue
+		}
+		var ok bool
+		if rest, ok = p.read(tk.text, rest); !ok {
+			return fail()
+		}
+	}
+	if rest != "" {
+		return fail()
+	}
+
+	t, ok := p.time(loc)
+	if !ok {
+		return fail()
+	}
+	return t, nil
+}
+
+// Reads the token from the start of the text into the parts, returns the rest.
+func (p *parts) read(tk, text string) (string, bool) {
+	switch tk {
+	case "MMMM", "MMM":
+		if i, rest, ok := readName(text, months, len(tk)); ok {
+			p.month = i + 1
+			return rest, true
+		}
+		return text, false
+	case "dddd", "ddd", "dd":
+		_, rest, ok := readName(text, weekdays, len(tk))
+		return rest, ok
+	case "A", "a":
+		switch strings.ToLower(prefix(text, 2)) {
+		case "am":
+			p.pm = 0
+		case "pm":
+			p.pm = 1
+		default:
+			return text, false
+		}
+		return text[2:], true
+	case "Z", "ZZ":
+		return p.readZone(text)
+	case "X", "x":
+		n, rest, ok := readNumber(text, 1, 20)
+		if !ok {
+			return text, false
+		}
+		p.unix, p.unixSet = int64(n), true
+		if tk == "x" {
+			p.unix /= 1000
+		}
+		return rest, true
+	}
+
+	if tk[0] == 'S' {
+		n, rest, ok := readNumber(text, len(tk), len(tk))
+		if !ok {
+			return text, false
+		}
+		for i := len(tk); i < 9; i++ {
+			n *= 10
+		}
+		p.nanos = n
+		return rest, true
+	}
+
+	// The numbers, the doubled tokens have exactly two digits, the ordinals a suffix.
+	min, max := 1, 2
+	switch tk {
+	case "YYYY", "gggg", "GGGG":
+		min, max = 4, 4
+	case "Y":
+		max = 6
+	case "DDDD":
+		min, max = 3, 3
+	case "DDD", "DDDo":
+		max = 3
+	case "Q", "Qo", "d", "do", "e", "E":
+		max = 1
+	default:
+		if len(tk) == 2 && tk[0] == tk[1] {
+			min = 2
+		}
+	}
+	n, rest, ok := readNumber(text, min, max)
+	if !ok {
+		return text, false
+	}
+	if strings.HasSuffix(tk, "o") {
+		suffix := strings.TrimPrefix(Ordinal(n), strconv.Itoa(n))
+		if !strings.HasPrefix(strings.ToLower(rest), suffix) {
+			return text, false
+		}
+		rest = rest[len(suffix):]
+	}
+
+	switch strings.TrimSuffix(tk, "o") {
+	case "YYYY", "Y":
+		p.year = n
+	case "YY":
+		p.year = twoDigitYear(n)
+	case "gggg":
+		p.localeYear = n
+	case "gg":
+		p.localeYear = twoDigitYear(n)
+	case "GGGG":
+		p.isoYear = n
+	case "GG":
+		p.isoYear = twoDigitYear(n)
+	case "Q":
+		p.quarter = n
+	case "MM", "M":
+		p.month = n
+	case "DDDD", "DDD":
+		p.yearDay = n
+	case "DD", "D":
+		p.day = n
+	case "d":
+		// The day of the week is only checked by the date itself.
+	case "e":
+		p.localeDay = n
+	case "E":
+		p.isoDay = n
+	case "WW", "W":
+		p.isoWeek = n
+	case "ww", "w":
+		p.localeWeek = n
+	case "HH", "H", "hh", "h":
+		p.hour = n
+	case "kk", "k":
+		p.hour = n % 24
+	case "mm", "m":
+		p.minute = n
+	case "ss", "s":
+		p.second = n
+	}
+	return rest, true
+}
+
+// Reads the '+01:00' or the '+0100' time zone offset, or a 'Z'.
+func (p *parts) readZone(text string) (string, bool) {
+	if strings.HasPrefix(text, "Z") {
+		p.zone = time.UTC
+		return text[1:], true
+	}
+	if text == "" || (text[0] != '+' && text[0] != '-') {
+		return text, false
+	}
+	hours, rest, ok := readNumber(text[1:], 2, 2)
+	if !ok {
+		return text, false
+	}
+	rest = strings.TrimPrefix(rest, ":")
+	minutes, rest, ok := readNumber(rest, 2, 2)
+	if !ok {
+		return text, false
+	}
+	offset := hours*3600 + minutes*60
+	if text[0] == '-' {
+		offset = -offset
+	}
+	p.zone = time.FixedZone("", offset)
+	return rest, true
+}
+
+// Builds the time from the parts, false if they don't make a valid date.
+func (p *parts) time(loc *time.Location) (time.Time, bool) {
+	if p.zone != nil {
+		loc = p.zone
+	}
+	if p.unixSet {
+		return time.Unix(p.unix, 0).In(loc), true
+	}
+
+	hour := or(p.hour, 0)
+	if p.pm >= 0 {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false
+		}
+		hour = hour%12 + 12*p.pm
+	}
+	if hour > 23 || or(p.minute, 0) > 59 || or(p.second, 0) > 59 {
+		return time.Time{}, false
+	}
+	var day time.Time
+	switch {
+	case p.isoWeek >= 0:
+		year := or(p.isoYear, or(p.year, time.Now().Year()))
+		weekday := or(p.isoDay, 1)
+		if p.isoWeek < 1 || p.isoWeek > weeksInYear(year, ISO_DOW, ISO_DOY) || weekday < 1 || weekday > 7 {
+			return time.Time{}, false
+		}
+		day = WeekStart(year, p.isoWeek, ISO_DOW, ISO_DOY, loc).AddDate(0, 0, weekday-1)
+	case p.localeWeek >= 0:
+		year := or(p.localeYear, or(p.year, time.Now().Year()))
+		weekday := or(p.localeDay, 0)
+		if p.localeWeek < 1 || p.localeWeek > weeksInYear(year, LOCALE_DOW, LOCALE_DOY) || weekday > 6 {
+			return time.Time{}, false
+		}
+		day = WeekStart(year, p.localeWeek, LOCALE_DOW, LOCALE_DOY, loc).AddDate(0, 0, weekday)
+	case p.yearDay >= 0:
+		year := or(p.year, time.Now().Year())
+		if p.yearDay < 1 || p.yearDay > daysInYear(year) {
+			return time.Time{}, false
+		}
+		day = time.Date(year, time.January, p.yearDay, 0, 0, 0, 0, loc)
+	default:
+		year := or(p.year, time.Now().Year())
+		month := or(p.month, 1)
+		if p.month < 0 && p.quarter >= 0 {
+			if p.quarter < 1 || p.quarter > 4 {
+				return time.Time{}, false
+			}
+			month = 3*(p.quarter-1) + 1
+		}
+		d := or(p.day, 1)
+		day = time.Date(year, time.Month(month), d, 0, 0, 0, 0, loc)
+		if month < 1 || month > 12 || day.Day() != d {
+			return time.Time{}, false
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, or(p.minute, 0), or(p.second, 0), or(p.nanos, 0), loc), true
+}
+
+func or(n, def int) int {
+	if n < 0 {
+		return def
+	}
+	return n
+}
+
+// The years of the two digit years like moment.js reads them, '69' is 1969 and '68'
+// is 2068.
+func twoDigitYear(n int) int {
+	if n > 68 {
+		return 1900 + n
+	}
+	return 2000 + n
+}
+
+// Reads a number with at least min and at most max digits.
+func readNumber(text string, min, max int) (int, string, bool) {
+	i := 0
+	for i < len(text) && i < max && text[i] >= '0' && text[i] <= '9' {
+		i++
+	}
+	if i < min {
+		return 0, text, false
+	}
+	n, err := strconv.Atoi(text[:i])
+	return n, text[i:], err == nil
+}
+
+// Reads one of the names in any case. The names are cut to the length of the token
+// for the short ones, e.g. 'MMM' reads 'Jan' and 'dd' reads 'Mo'. The long tokens
+// take the whole names.
+func readName(text string, names []string, token int) (int, string, bool) {
+	for i, name := range names {
+		if token < 4 {
+			name = name[:token]
+		}
+		if strings.EqualFold(prefix(text, len(name)), name) {
+			return i, text[len(name):], true
+		}
+	}
+	return 0, text, false
+}
+
+func prefix(text string, n int) string {
+	if len(text) < n {
+		return text
+	}
+	return text[:n]
+}
@@ -0,0 +1,81 @@
+package obsidian
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The folder Obsidian keeps its settings in.
+const CONFIG_FOLDER string = ".obsidian"
+
+// The settings file of the app.
+const APP_FILE_NAME string = "app.json"
+
+// The settings file of the core templates plugin.
+const TEMPLATES_FILE_NAME string = "templates.json"
+
+// Where the new notes go.
+const (
+	LOCATION_ROOT    string = "root"
+	LOCATION_CURRENT string = "current"
+	LOCATION_FOLDER  string = "folder"
+)
+
+// The settings of the app which odm cares about.
+type App struct {
+	// Where the new attachments go: '/' is the vault root, './' is the folder of the
+	// note, './name' is a sub folder of it and anything else is a vault folder.
+	AttachmentFolderPath string `json:"attachmentFolderPath"`
+
+	UseMarkdownLinks bool   `json:"useMarkdownLinks"`
+	NewLinkFormat    string `json:"newLinkFormat"`
+
+	// One of the LOCATION_* constants, the folder is used with LOCATION_FOLDER.
+	NewFileLocation   string `json:"newFileLocation"`
+	NewFileFolderPath string `json:"newFileFolderPath"`
+}
+
+// The settings of the core templates plugin.
+type Templates struct {
+	Folder     string `json:"folder"`
+	DateFormat string `json:"dateFormat"`
+	TimeFormat string `json:"timeFormat"`
+}
+
+// Reads a settings file of the vault into the value. Returns false if the file
+// doesn't exist, which is normal for the settings never changed from the defaults.
+func Read(v *vault.Vault, name string, value any) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(v.Root().String(), CONFIG_FOLDER, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reads the app settings, the defaults are used if there is no settings file.
+func LoadApp(v *vault.Vault) (*App, error) {
+	app := &App{AttachmentFolderPath: "/", NewLinkFormat: "shortest", NewFileLocation: LOCATION_ROOT}
+	if _, err := Read(v, APP_FILE_NAME, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Reads the settings of the templates plugin, the fields are empty if they are not set.
+func LoadTemplates(v *vault.Vault) (*Templates, error) {
+	t := &Templates{}
+	if _, err := Read(v, TEMPLATES_FILE_NAME, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The version of the JSON schema of the records. It is bumped whenever a field is
+// removed or changes its meaning, adding fields doesn't change it.
+const SCHEMA_VERSION int = 1
+
+// The kinds of the records.
+const (
+	KIND_MATCH       string = "match"
+	KIND_REPLACEMENT string = "replacement"
+	KIND_MENTION     string = "mention"
+	KIND_DIAGNOSTIC  string = "diagnostic"
+	KIND_LINK        string = "link"
+	KIND_NOTE        string = "note"
+	KIND_TAG         string = "tag"
+	KIND_RULE        string = "rule"
+	KIND_FILE        string = "file"
+	KIND_TASK        string = "task"
+	KIND_ERROR       string = "error"
+	KIND_ROW         string = "row"
+	KIND_FIELD       string = "field"
+	KIND_STATS       string = "stats"
+	KIND_SETTING     string = "setting"
+)
+
+// A single result of a command. The positions are byte offsets into the note, the
+// lines and the columns are 1 based and the end is exclusive. The kind specific
+// values, e.g. the rule of a diagnostic, are kept in the fields.
+type Record struct {
+	Version int            `json:"version"`
+	Kind    string         `json:"kind"`
+	Path    string         `json:"path"`
+	Begin   int            `json:"begin"`
+	End     int            `json:"end"`
+	Line    int            `json:"line"`
+	Col     int            `json:"col"`
+	EndLine int            `json:"end_line"`
+	EndCol  int            `json:"end_col"`
+	Match   string         `json:"match"`
+	Context string         `json:"context"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// An error of a command, written to stderr. The code is the exit code.
+type ErrorRecord struct {
+	Version int    `json:"version"`
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// The columns of the TSV output, before the fields.
+var Columns = []string{"version", "kind", "path", "begin", "end", "line", "col", "end_line", "end_col", "match", "context"}
+
+// Creates a record of a match in the data of a note. The context is the full
+// lines the match is on.
+func NewMatchRecord(kind, path string, data []byte, lines metadata.LineIndex, m api.Match) Record {
+	line, col := lines.Position(m.Begin)
+	endLine, endCol := lines.Position(m.End)
+
+	begin := lines.LineStart(line)
+	end := lineEnd(data, lines, endLine)
+	if m.End > end {
+		end = m.End
+	}
+	context := data[begin:end]
+	if n := len(context); n > 0 && context[n-1] == '\r' {
+		context = context[:n-1]
+	}
+
+	return Record{
+		Version: SCHEMA_VERSION,
+		Kind:    kind,
+		Path:    path,
+		Begin:   m.Begin,
+		End:     m.End,
+		Line:    line,
+		Col:     col,
+		EndLine: endLine,
+		EndCol:  endCol,
+		Match:   string(data[m.Begin:m.End]),
+		Context: string(context),
+	}
+}
+
+// Creates a record without a position, e.g. of a whole note or a tag.
+func NewRecord(kind, path string) Record {
+	return Record{
+		Version: SCHEMA_VERSION,
+		Kind:    kind,
+		Path:    path,
+	}
+}
+
+// Sets a kind specific field and returns the record.
+func (r Record) With(key string, value any) Record {
+	fields := make(map[string]any, len(r.Fields)+1)
+	for k, v := range r.Fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	r.Fields = fields
+	return r
+}
+
+// Gets the offset of the end of the 1 based line, without the new line.
+func lineEnd(data []byte, lines metadata.LineIndex, line int) int {
+	if line >= lines.Lines() {
+		return len(data)
+	}
+	return lines.LineStart(line+1) - 1
+}
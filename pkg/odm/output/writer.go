@@ -0,0 +1,204 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// The output formats.
+const (
+	FORMAT_TEXT   string = "text"
+	FORMAT_JSON   string = "json"
+	FORMAT_NDJSON string = "ndjson"
+	FORMAT_TSV    string = "tsv"
+)
+
+var Formats = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_NDJSON, FORMAT_TSV}
+
+// The JSON document written with the json format.
+type Document struct {
+	Version int      `json:"version"`
+	Command string   `json:"command"`
+	Records []Record `json:"records"`
+}
+
+// Writes the records of a command in the selected format. In the text format the
+// human readable line given along with each record is written instead, unless a
+// template is given, which is executed for every record.
+type Writer struct {
+	out      io.Writer
+	format   string
+	command  string
+	template *template.Template
+	records  []Record
+	fields   []string
+	header   bool
+}
+
+// Creates a writer, the template is optional.
+func NewWriter(out io.Writer, format, command, tmpl string) (*Writer, error) {
+	if err := CheckFormat(format); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		out:     out,
+		format:  format,
+		command: command,
+		records: []Record{},
+	}
+	if tmpl != "" {
+		t, err := template.New("format").Funcs(funcs).Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		w.template = t
+	}
+	return w, nil
+}
+
+// Checks if the format is known.
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s', use one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Checks if the records are written instead of the text lines.
+func (w *Writer) Structured() bool {
+	return w.template != nil || w.format != FORMAT_TEXT
+}
+
+// Writes the record, or the text in the text format. The text may span several
+// lines and an empty text writes nothing.
+func (w *Writer) Write(r Record, text string) error {
+	r.Version = SCHEMA_VERSION
+
+	if w.template != nil {
+		if err := w.template.Execute(w.out, r); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w.out)
+		return err
+	}
+
+	switch w.format {
+	case FORMAT_JSON:
+		w.records = append(w.records, r)
+		return nil
+	case FORMAT_NDJSON:
+		return writeJSON(w.out, r)
+	case FORMAT_TSV:
+		return w.writeTSV(r)
+	}
+
+	if text == "" {
+		return nil
+	}
+	_, err := fmt.Fprintln(w.out, text)
+	return err
+}
+
+// Flushes the records, the json format is only written here.
+func (w *Writer) Close() error {
+	if w.template == nil && w.format == FORMAT_JSON {
+		return writeJSON(w.out, Document{
+			Version: SCHEMA_VERSION,
+			Command: w.command,
+			Records: w.records,
+		})
+	}
+	return nil
+}
+
+// Writes a row, with a header before the first one. The fields of the first
+// record become the extra columns.
+func (w *Writer) writeTSV(r Record) error {
+	if !w.header {
+		w.header = true
+		for k := range r.Fields {
+			w.fields = append(w.fields, k)
+		}
+		sort.Strings(w.fields)
+		if _, err := fmt.Fprintln(w.out, strings.Join(append(append([]string{}, Columns...), w.fields...), "\t")); err != nil {
+			return err
+		}
+	}
+
+	row := []string{
+		strconv.Itoa(r.Version),
+		r.Kind,
+		r.Path,
+		strconv.Itoa(r.Begin),
+		strconv.Itoa(r.End),
+		strconv.Itoa(r.Line),
+		strconv.Itoa(r.Col),
+		strconv.Itoa(r.EndLine),
+		strconv.Itoa(r.EndCol),
+		r.Match,
+		r.Context,
+	}
+	for _, k := range w.fields {
+		if v, ok := r.Fields[k]; ok {
+			row = append(row, fmt.Sprint(v))
+		} else {
+			row = append(row, "")
+		}
+	}
+	for i := range row {
+		row[i] = escapeTSV(row[i])
+	}
+	_, err := fmt.Fprintln(w.out, strings.Join(row, "\t"))
+	return err
+}
+
+// Writes an error as a record to the output, in the text format it is written as
+// is. The code is the exit code of the command.
+func WriteError(out io.Writer, format string, err error, code int) {
+	switch format {
+	case FORMAT_JSON, FORMAT_NDJSON:
+		writeJSON(out, ErrorRecord{
+			Version: SCHEMA_VERSION,
+			Kind:    KIND_ERROR,
+			Code:    code,
+			Message: err.Error(),
+		})
+	case FORMAT_TSV:
+		fmt.Fprintf(out, "%s\t%d\t%s\n", KIND_ERROR, code, escapeTSV(err.Error()))
+	default:
+		fmt.Fprintln(out, err.Error())
+	}
+}
+
+// Writes the value as a single line of JSON.
+func writeJSON(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(value)
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// Escapes the characters which would break the rows and the columns.
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+// The functions available in the templates.
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"field": func(r Record, key string) any {
+		return r.Fields[key]
+	},
+}
@@ -0,0 +1,211 @@
+package periodic
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/obsidian"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The periods of the periodic notes.
+const (
+	PERIOD_DAY     string = "day"
+	PERIOD_WEEK    string = "week"
+	PERIOD_MONTH   string = "month"
+	PERIOD_QUARTER string = "quarter"
+	PERIOD_YEAR    string = "year"
+)
+
+var Periods = []string{PERIOD_DAY, PERIOD_WEEK, PERIOD_MONTH, PERIOD_QUARTER, PERIOD_YEAR}
+
+// The literals of the format strings.
+var literalRegex = regexp.MustCompile(`\[[^\]]*\]`)
+
+// The settings file of the core daily notes plugin.
+const DAILY_NOTES_FILE_NAME string = "daily-notes.json"
+
+// The settings file of the Periodic Notes plugin, under the config folder.
+const PERIODIC_NOTES_FILE_NAME string = "plugins/periodic-notes/data.json"
+
+// The formats of the file names the plugins use by default.
+var DefaultFormats = map[string]string{
+	PERIOD_DAY:     "YYYY-MM-DD",
+	PERIOD_WEEK:    "gggg-[W]ww",
+	PERIOD_MONTH:   "YYYY-MM",
+	PERIOD_QUARTER: "YYYY-[Q]Q",
+	PERIOD_YEAR:    "YYYY",
+}
+
+// Where the notes of a period are and how they are named.
+type Settings struct {
+	// One of the PERIOD_* constants.
+	Period string
+
+	// The folder relative to the vault, empty for the root.
+	Folder string
+
+	// The moment.js format of the file names, it may have folders in it like
+	// 'YYYY/MM/YYYY-MM-DD'.
+	Format string
+
+	// The path of the template note relative to the vault, empty for none.
+	Template string
+}
+
+// The settings of a period in the plugin files.
+type pluginSettings struct {
+	Enabled  *bool  `json:"enabled"`
+	Folder   string `json:"folder"`
+	Format   string `json:"format"`
+	Template string `json:"template"`
+}
+
+// Reads the settings of every period. The daily notes settings come from the core
+// plugin, the Periodic Notes plugin wins over it for the periods it has. The unset
+// ones are the defaults of the plugins.
+func Load(v *vault.Vault) (map[string]Settings, error) {
+	all := make(map[string]Settings)
+	for _, p := range Periods {
+		all[p] = Settings{Period: p, Format: DefaultFormats[p]}
+	}
+
+	apply := func(period string, ps pluginSettings) {
+		s := all[period]
+		s.Folder = strings.Trim(ps.Folder, "/")
+		if ps.Format != "" {
+			s.Format = ps.Format
+		}
+		s.Template = strings.TrimPrefix(ps.Template, "/")
+		all[period] = s
+	}
+
+	daily := pluginSettings{}
+	if ok, err := obsidian.Read(v, DAILY_NOTES_FILE_NAME, &daily); err != nil {
+		return nil, err
+	} else if ok {
+		apply(PERIOD_DAY, daily)
+	}
+
+	plugin := make(map[string]pluginSettings)
+	if _, err := obsidian.Read(v, PERIODIC_NOTES_FILE_NAME, &plugin); err != nil {
+		return nil, fmt.Errorf("%s: %w", PERIODIC_NOTES_FILE_NAME, err)
+	}
+	for _, p := range Periods {
+		if ps, ok := plugin[key(p)]; ok && (ps.Enabled == nil || *ps.Enabled) {
+			apply(p, ps)
+		}
+	}
+	return all, nil
+}
+
+// Gets the key of the period in the Periodic Notes settings.
+func key(period string) string {
+	if period == PERIOD_DAY {
+		return "daily"
+	}
+	return period + "ly"
+}
+
+// Gets the first day of the period the time is in. The weeks start on Monday if the
+// format has ISO weeks, like 'GGGG-[W]WW', on Sunday otherwise.
+func (s Settings) Start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch s.Period {
+	case PERIOD_WEEK:
+		day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		first := time.Sunday
+		if s.isoWeeks() {
+			first = time.Monday
+		}
+		return day.AddDate(0, 0, -((int(day.Weekday()) - int(first) + 7) % 7))
+	case PERIOD_MONTH:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case PERIOD_QUARTER:
+		return time.Date(y, time.Month(3*(moment.Quarter(t)-1)+1), 1, 0, 0, 0, 0, t.Location())
+	case PERIOD_YEAR:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Whether the weeks of the format are ISO weeks, the literals in brackets aside.
+func (s Settings) isoWeeks() bool {
+	return strings.ContainsAny(literalRegex.ReplaceAllString(s.Format, ""), "GWE")
+}
+
+// Gets the first day of the period n periods after the one the time is in, or before
+// it if n is negative.
+func (s Settings) Add(t time.Time, n int) time.Time {
+	start := s.Start(t)
+	switch s.Period {
+	case PERIOD_WEEK:
+		return start.AddDate(0, 0, 7*n)
+	case PERIOD_MONTH:
+		return start.AddDate(0, n, 0)
+	case PERIOD_QUARTER:
+		return start.AddDate(0, 3*n, 0)
+	case PERIOD_YEAR:
+		return start.AddDate(n, 0, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// Gets the path of the note of the period the time is in, relative to the vault.
+func (s Settings) Path(t time.Time) string {
+	return path.Join(s.Folder, moment.Format(s.Start(t), s.Format)+vault.NOTE_EXTENSION)
+}
+
+// Gets the first day of the period of the note at the path, false if the path is not
+// the path of a note of the period.
+func (s Settings) Date(notePath string, loc *time.Location) (time.Time, bool) {
+	rel := notePath
+	if s.Folder != "" {
+		if !strings.HasPrefix(rel, s.Folder+"/") {
+			return time.Time{}, false
+		}
+		rel = rel[len(s.Folder)+1:]
+	}
+	if !strings.HasSuffix(rel, vault.NOTE_EXTENSION) {
+		return time.Time{}, false
+	}
+
+	t, err := moment.Parse(s.Format, strings.TrimSuffix(rel, vault.NOTE_EXTENSION), loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// The name must be the one written for the period, e.g. not a day of a week.
+	if s.Path(t) != notePath {
+		return time.Time{}, false
+	}
+	return s.Start(t), true
+}
+
+// Gets the first days of the periods from the one of the first time to the one of the
+// last, both included.
+func (s Settings) Range(from, to time.Time) []time.Time {
+	starts := make([]time.Time, 0)
+	for t := s.Start(from); !t.After(to); t = s.Add(t, 1) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+// Gets the first days of the periods which have a note among the paths, sorted.
+func (s Settings) Existing(paths []string, loc *time.Location) []time.Time {
+	starts := make([]time.Time, 0)
+	for _, p := range paths {
+		if t, ok := s.Date(p, loc); ok {
+			starts = append(starts, t)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+	return starts
+}
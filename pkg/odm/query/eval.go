@@ -0,0 +1,401 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// An expression of a query.
+type expr interface {
+	eval(e *env) (any, error)
+}
+
+// The context the expressions are evaluated in.
+type env struct {
+	engine *Engine
+
+	// The fields of the row, a note, a task or a group.
+	row Object
+
+	// The path of the note the query is run from, the links are resolved from it.
+	from string
+
+	// The fields of the note the query is run from, for 'this'.
+	this Object
+}
+
+type literal struct {
+	value any
+}
+
+type identifier struct {
+	name string
+}
+
+type linkLiteral struct {
+	target  string
+	display string
+}
+
+type list struct {
+	items []expr
+}
+
+type member struct {
+	object expr
+	name   string
+}
+
+type index struct {
+	object expr
+	index  expr
+}
+
+type call struct {
+	name string
+	args []expr
+}
+
+type unary struct {
+	op      string
+	operand expr
+}
+
+type binary struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (l literal) eval(e *env) (any, error) {
+	return l.value, nil
+}
+
+func (i identifier) eval(e *env) (any, error) {
+	if strings.EqualFold(i.name, "this") {
+		return e.this, nil
+	}
+	v, _ := e.row.Get(i.name)
+	return v, nil
+}
+
+func (l linkLiteral) eval(e *env) (any, error) {
+	return e.engine.link(e.from, l.target, l.display), nil
+}
+
+func (l list) eval(e *env) (any, error) {
+	values := make([]any, 0, len(l.items))
+	for _, item := range l.items {
+		v, err := item.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (m member) eval(e *env) (any, error) {
+	v, err := m.object.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return e.engine.field(v, m.name), nil
+}
+
+func (i index) eval(e *env) (any, error) {
+	v, err := i.object.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	k, err := i.index.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch k := k.(type) {
+	case float64:
+		if l, ok := v.([]any); ok {
+			n := int(k)
+			if n < 0 {
+				n += len(l)
+			}
+			if n >= 0 && n < len(l) {
+				return l[n], nil
+			}
+			return nil, nil
+		}
+	case string:
+		return e.engine.field(v, k), nil
+	}
+	return nil, nil
+}
+
+func (c call) eval(e *env) (any, error) {
+	f, ok := functions[c.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s'", c.name)
+	}
+	args := make([]any, 0, len(c.args))
+	for _, arg := range c.args {
+		v, err := arg.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	v, err := f(e, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return v, nil
+}
+
+func (u unary) eval(e *env) (any, error) {
+	v, err := u.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if u.op == "!" {
+		return !truthy(v), nil
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return -v, nil
+	case Duration:
+		return Duration{Months: -v.Months, Days: -v.Days, Clock: -v.Clock}, nil
+	}
+	return nil, fmt.Errorf("can't negate %s", typeName(v))
+}
+
+func (b binary) eval(e *env) (any, error) {
+	left, err := b.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "and":
+		if !truthy(left) {
+			return false, nil
+		}
+	case "or":
+		if truthy(left) {
+			return true, nil
+		}
+	}
+	right, err := b.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "and", "or":
+		return truthy(right), nil
+	case "=":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<":
+		return compare(left, right) < 0, nil
+	case "<=":
+		return compare(left, right) <= 0, nil
+	case ">":
+		return compare(left, right) > 0, nil
+	case ">=":
+		return compare(left, right) >= 0, nil
+	}
+	return arithmetic(b.op, left, right)
+}
+
+// Applies the arithmetic operator. The numbers are added up, the strings and the
+// lists joined, the durations added to the dates and the dates subtracted from each
+// other. Anything with nil is nil.
+func arithmetic(op string, left, right any) (any, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			switch op {
+			case "+":
+				return l + r, nil
+			case "-":
+				return l - r, nil
+			case "*":
+				return l * r, nil
+			case "/":
+				if r == 0 {
+					return nil, nil
+				}
+				return l / r, nil
+			case "%":
+				if r == 0 {
+					return nil, nil
+				}
+				return math.Mod(l, r), nil
+			}
+		}
+		if r, ok := right.(Duration); ok && op == "*" {
+			return scale(r, l), nil
+		}
+	case time.Time:
+		switch r := right.(type) {
+		case Duration:
+			switch op {
+			case "+":
+				return addDuration(l, r, 1), nil
+			case "-":
+				return addDuration(l, r, -1), nil
+			}
+		case time.Time:
+			if op == "-" {
+				diff := l.Sub(r)
+				days := diff / (24 * time.Hour)
+				return Duration{Days: int(days), Clock: diff - days*24*time.Hour}, nil
+			}
+		}
+	case Duration:
+		switch r := right.(type) {
+		case Duration:
+			switch op {
+			case "+":
+				return Duration{Months: l.Months + r.Months, Days: l.Days + r.Days, Clock: l.Clock + r.Clock}, nil
+			case "-":
+				return Duration{Months: l.Months - r.Months, Days: l.Days - r.Days, Clock: l.Clock - r.Clock}, nil
+			}
+		case time.Time:
+			if op == "+" {
+				return addDuration(r, l, 1), nil
+			}
+		case float64:
+			switch op {
+			case "*":
+				return scale(l, r), nil
+			case "/":
+				if r != 0 {
+					return scale(l, 1/r), nil
+				}
+				return nil, nil
+			}
+		}
+	case []any:
+		if r, ok := right.([]any); ok && op == "+" {
+			return append(append(make([]any, 0, len(l)+len(r)), l...), r...), nil
+		}
+	}
+
+	if op == "+" {
+		if _, ok := left.(string); ok {
+			return left.(string) + toString(right), nil
+		}
+		if r, ok := right.(string); ok {
+			return toString(left) + r, nil
+		}
+	}
+	return nil, fmt.Errorf("can't apply '%s' to %s and %s", op, typeName(left), typeName(right))
+}
+
+// Multiplies the duration, the fractions of the days go to the clock.
+func scale(d Duration, n float64) Duration {
+	days := float64(d.Days) * n
+	return Duration{
+		Months: int(float64(d.Months) * n),
+		Days:   int(days),
+		Clock:  time.Duration(float64(d.Clock)*n) + time.Duration((days-math.Trunc(days))*float64(24*time.Hour)),
+	}
+}
+
+// Gets the field of the value. The objects have their fields, the links the fields of
+// their notes and the dates and the durations their parts. The fields of a list are
+// the fields of its items, e.g. rows.file.link.
+func (en *Engine) field(v any, name string) any {
+	switch v := v.(type) {
+	case Object:
+		f, _ := v.Get(name)
+		return f
+	case Link:
+		if p, ok := en.byPath[v.Path]; ok {
+			f, _ := p.Fields.Get(name)
+			return f
+		}
+	case []any:
+		values := make([]any, 0, len(v))
+		for _, item := range v {
+			values = append(values, en.field(item, name))
+		}
+		return values
+	case time.Time:
+		switch strings.ToLower(name) {
+		case "year":
+			return float64(v.Year())
+		case "month":
+			return float64(v.Month())
+		case "day":
+			return float64(v.Day())
+		case "hour":
+			return float64(v.Hour())
+		case "minute":
+			return float64(v.Minute())
+		case "second":
+			return float64(v.Second())
+		case "weekday":
+			// Monday is 1 and Sunday 7, like in Luxon.
+			return float64((int(v.Weekday())+6)%7 + 1)
+		case "week", "weekyear":
+			year, week := v.ISOWeek()
+			if strings.EqualFold(name, "week") {
+				return float64(week)
+			}
+			return float64(year)
+		}
+	case Duration:
+		switch strings.ToLower(name) {
+		case "years":
+			return float64(v.Months) / 12
+		case "months":
+			return float64(v.Months)
+		case "weeks":
+			return v.days() / 7
+		case "days":
+			return v.days()
+		case "hours":
+			return v.days() * 24
+		case "minutes":
+			return v.days() * 24 * 60
+		case "seconds":
+			return v.days() * 24 * 60 * 60
+		}
+	case string:
+		if strings.EqualFold(name, "length") {
+			return float64(len([]rune(v)))
+		}
+	}
+	return nil
+}
+
+// Gets the name of the type of the value for the errors and typeof.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "date"
+	case Duration:
+		return "duration"
+	case Link:
+		return "link"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
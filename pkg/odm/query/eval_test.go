@@ -0,0 +1,186 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+var testNotes = map[string]string{
+	"Projects/Alpha.md": "---\nstatus: active\nrating: 4\ndue: 2024-05-01\ntags: [project]\n---\n- [ ] write docs\n- [x] plan ✅ 2024-04-01\n",
+	"Projects/Beta.md":  "---\nstatus: done\nrating: 2\ntags: [project]\n---\nSee [[Alpha]].\n",
+	"Notes/Gamma.md":    "Links to [[Alpha]].\nkind:: idea\n",
+}
+
+// Creates an engine over a vault of the notes.
+func newTestEngine(t *testing.T, notes map[string]string) *Engine {
+	dir := t.TempDir()
+	for name, text := range notes {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := vault.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+	g, err := v.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	en, err := NewEngine(v, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	en.Now = time.Date(2024, 5, 10, 15, 30, 0, 0, time.Local)
+	return en
+}
+
+func TestEval(t *testing.T) {
+	en := newTestEngine(t, testNotes)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`1 + 2 * 3`, "7"},
+		{`(1 + 2) * 3`, "9"},
+		{`10 % 4`, "2"},
+		{`7 / 2`, "3.5"},
+		{`-rating`, "-4"},
+		{`"a" + "b"`, "ab"},
+		{`"n" + 1`, "n1"},
+		{`rating > 3`, "true"},
+		{`status = "active" and rating >= 4`, "true"},
+		{`status = "done" or rating < 3`, "false"},
+		{`!(rating < 3)`, "true"},
+		{`null`, ""},
+		{`missing`, ""},
+		{`file.name`, "Alpha"},
+		{`file.folder`, "Projects"},
+		{`file.path`, "Projects/Alpha.md"},
+		{`length(file.tasks)`, "2"},
+		{`length(file.inlinks)`, "2"},
+		{`upper(status)`, "ACTIVE"},
+		{`contains(file.tags, "#project")`, "true"},
+		{`default(missing, 5)`, "5"},
+		{`choice(rating > 3, "high", "low")`, "high"},
+		{`[1, 2, 3][1]`, "2"},
+		{`sum([1, 2, 3])`, "6"},
+		{`join(["a", "b"], "-")`, "a-b"},
+		{`due`, "2024-05-01"},
+		{`due + dur(1 day)`, "2024-05-02"},
+		{`date(today)`, "2024-05-10"},
+		{`date(today) - due`, "1 week, 2 days"},
+		{`due < date(today)`, "true"},
+		{`dateformat(due, "yyyy-MM-dd")`, "2024-05-01"},
+		{`dateformat(due, "dd.LL.yy")`, "01.05.24"},
+		{`dateformat(due, "LLL d, yyyy")`, "May 1, 2024"},
+		{`dateformat(due, "EEEE 'the' d")`, "Wednesday the 1"},
+		{`dateformat(due, "DDD")`, "May 1, 2024"},
+		{`dateformat(date(now), "HH:mm a")`, "15:30 PM"},
+		{`dateformat(due, "o 'day of' kkkk-'W'WW")`, "122 day of 2024-W18"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			q, err := Parse(`TABLE WITHOUT ID ` + tt.expr + ` WHERE file.name = "Alpha"`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := en.Run(q, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(res.Rows))
+			}
+			if got := toString(res.Rows[0].Values[0]); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	en := newTestEngine(t, testNotes)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			`LIST FROM #project SORT rating DESC`,
+			"- [[Projects/Alpha.md]]\n- [[Projects/Beta.md]]",
+		},
+		{
+			`LIST FROM "Projects" SORT rating`,
+			"- [[Projects/Beta.md]]\n- [[Projects/Alpha.md]]",
+		},
+		{
+			`LIST FROM [[Alpha]]`,
+			"- [[Notes/Gamma.md]]\n- [[Projects/Beta.md]]",
+		},
+		{
+			`LIST FROM #project AND -"Projects/Beta"`,
+			"- [[Projects/Alpha.md]]",
+		},
+		{
+			`TABLE rating AS "R", status FROM "Projects" WHERE rating > 3`,
+			"| File | R | status |\n| --- | --- | --- |\n| [[Projects/Alpha.md]] | 4 | active |",
+		},
+		{
+			`TABLE WITHOUT ID file.name, kind WHERE kind`,
+			"| file.name | kind |\n| --- | --- |\n| Gamma | idea |",
+		},
+		{
+			`LIST GROUP BY status`,
+			"- \\-\n\t- [[Notes/Gamma.md]]\n- active\n\t- [[Projects/Alpha.md]]\n- done\n\t- [[Projects/Beta.md]]",
+		},
+		{
+			`TABLE length(rows) AS "Notes" GROUP BY status`,
+			"| status | Notes |\n| --- | --- |\n| \\- | 1 |\n| active | 1 |\n| done | 1 |",
+		},
+		{
+			`LIST t FLATTEN file.tags AS t WHERE t`,
+			"- [[Projects/Alpha.md]]: #project\n- [[Projects/Beta.md]]: #project",
+		},
+		{
+			`LIST SORT file.name DESC LIMIT 1`,
+			"- [[Notes/Gamma.md]]",
+		},
+		{
+			`TASK WHERE !completed`,
+			"- [ ] write docs ([[Projects/Alpha.md]])",
+		},
+		{
+			`TASK WHERE completed GROUP BY file.name`,
+			"- Alpha\n\t- [x] plan ([[Projects/Alpha.md]])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := en.Run(q, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Markdown(RenderOptions{}); got != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,589 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+)
+
+// A function of the queries, it gets the evaluated arguments.
+type function func(e *env, args []any) (any, error)
+
+// The functions of the queries by their names in lower case.
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"date":         dateFunction,
+		"dur":          durFunction,
+		"dateformat":   dateFormatFunction,
+		"striptime":    stripTimeFunction,
+		"link":         linkFunction,
+		"length":       lengthFunction,
+		"contains":     containsFunction(false),
+		"icontains":    containsFunction(true),
+		"lower":        stringFunction(strings.ToLower),
+		"upper":        stringFunction(strings.ToUpper),
+		"trim":         stringFunction(strings.TrimSpace),
+		"startswith":   stringTest(strings.HasPrefix),
+		"endswith":     stringTest(strings.HasSuffix),
+		"replace":      replaceFunction,
+		"regextest":    regexFunction(false),
+		"regexmatch":   regexFunction(true),
+		"regexreplace": regexReplaceFunction,
+		"split":        splitFunction,
+		"join":         joinFunction,
+		"default":      defaultFunction,
+		"choice":       choiceFunction,
+		"round":        roundFunction,
+		"number":       numberFunction,
+		"string":       toStringFunction,
+		"typeof":       typeofFunction,
+		"list":         listFunction,
+		"array":        listFunction,
+		"nonnull":      nonNullFunction,
+		"flat":         flatFunction,
+		"sort":         sortFunction,
+		"reverse":      reverseFunction,
+		"unique":       uniqueFunction,
+		"min":          extremeFunction(-1),
+		"max":          extremeFunction(1),
+		"sum":          sumFunction,
+		"average":      averageFunction,
+		"all":          allFunction,
+		"any":          anyFunction,
+	}
+}
+
+// Checks the number of the arguments.
+func arity(args []any, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("takes %d arguments, got %d", min, len(args))
+		}
+		return fmt.Errorf("takes %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+// Gets the values of a list argument, a single value is a list of one.
+func items(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return []any{}
+	case []any:
+		return v
+	}
+	return []any{v}
+}
+
+// Parses the argument of date(): today, now, tomorrow, yesterday, the starts and the
+// ends of the weeks (sow, eow), the months (som, eom) and the years (soy, eoy), the ISO
+// dates and the names of the daily notes. With a second argument the text is parsed
+// with the moment.js format.
+func dateFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 2); err != nil {
+		return nil, err
+	}
+	if len(args) == 2 {
+		text, ok1 := args[0].(string)
+		format, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		if t, err := moment.Parse(format, text, time.Local); err == nil {
+			return t, nil
+		}
+		return nil, nil
+	}
+
+	switch v := args[0].(type) {
+	case time.Time:
+		return v, nil
+	case Link:
+		return e.engine.field(e.engine.field(v, "file"), "day"), nil
+	case string:
+		now := e.engine.Now
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		weekday := (int(today.Weekday()) + 6) % 7
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "now":
+			return now, nil
+		case "today":
+			return today, nil
+		case "tomorrow":
+			return today.AddDate(0, 0, 1), nil
+		case "yesterday":
+			return today.AddDate(0, 0, -1), nil
+		case "sow":
+			return today.AddDate(0, 0, -weekday), nil
+		case "eow":
+			return today.AddDate(0, 0, 6-weekday), nil
+		case "som":
+			return today.AddDate(0, 0, 1-today.Day()), nil
+		case "eom":
+			return today.AddDate(0, 1, -today.Day()), nil
+		case "soy":
+			return today.AddDate(0, 1-int(today.Month()), 1-today.Day()), nil
+		case "eoy":
+			return time.Date(today.Year(), 12, 31, 0, 0, 0, 0, today.Location()), nil
+		}
+		if t, ok := parseDate(strings.TrimSpace(v)); ok {
+			return t, nil
+		}
+		if m := dayRegex.FindString(v); m != "" {
+			t, _ := parseDate(m)
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func durFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case Duration:
+		return v, nil
+	case string:
+		if d, err := ParseDuration(v); err == nil {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+// Formats the date with a Luxon format like Dataview, e.g. 'yyyy-MM-dd'.
+func dateFormatFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 2, 2); err != nil {
+		return nil, err
+	}
+	t, ok1 := args[0].(time.Time)
+	format, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+	return formatLuxon(t, format), nil
+}
+
+func stripTimeFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	if t, ok := args[0].(time.Time); ok {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	}
+	return nil, nil
+}
+
+func linkFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 2); err != nil {
+		return nil, err
+	}
+	display := ""
+	if len(args) == 2 {
+		display = toString(args[1])
+	}
+	switch v := args[0].(type) {
+	case Link:
+		if display != "" {
+			v.Display = display
+		}
+		return v, nil
+	case string:
+		return e.engine.link(e.from, v, display), nil
+	}
+	return nil, nil
+}
+
+func lengthFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case string:
+		return float64(len([]rune(v))), nil
+	case []any:
+		return float64(len(v)), nil
+	case Object:
+		return float64(len(v)), nil
+	}
+	return 1.0, nil
+}
+
+// Checks if the list has the value, the string the text or the object the key.
+func containsFunction(fold bool) function {
+	return func(e *env, args []any) (any, error) {
+		if err := arity(args, 2, 2); err != nil {
+			return nil, err
+		}
+		return contains(args[0], args[1], fold), nil
+	}
+}
+
+func contains(haystack, needle any, fold bool) bool {
+	switch h := haystack.(type) {
+	case []any:
+		for _, item := range h {
+			if contains(item, needle, fold) {
+				return true
+			}
+		}
+		return false
+	case string:
+		n := toString(needle)
+		if fold {
+			return strings.Contains(strings.ToLower(h), strings.ToLower(n))
+		}
+		return strings.Contains(h, n)
+	case Object:
+		_, ok := h.Get(toString(needle))
+		return ok
+	case Link:
+		if s, ok := needle.(string); ok && fold {
+			return strings.EqualFold(h.Name(), s)
+		}
+	}
+	return equal(haystack, needle)
+}
+
+// Applies the function to a string, or to each string of a list.
+func stringFunction(f func(string) string) function {
+	var apply func(v any) any
+	apply = func(v any) any {
+		switch v := v.(type) {
+		case string:
+			return f(v)
+		case []any:
+			values := make([]any, 0, len(v))
+			for _, item := range v {
+				values = append(values, apply(item))
+			}
+			return values
+		}
+		return v
+	}
+	return func(e *env, args []any) (any, error) {
+		if err := arity(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return apply(args[0]), nil
+	}
+}
+
+func stringTest(f func(s, part string) bool) function {
+	return func(e *env, args []any) (any, error) {
+		if err := arity(args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, ok1 := args[0].(string)
+		part, ok2 := args[1].(string)
+		return ok1 && ok2 && f(s, part), nil
+	}
+}
+
+func replaceFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 3, 3); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return args[0], nil
+	}
+	return strings.ReplaceAll(s, toString(args[1]), toString(args[2])), nil
+}
+
+// Checks the string against the regular expression, the whole of the string with
+// regexmatch and any part of it with regextest.
+func regexFunction(whole bool) function {
+	return func(e *env, args []any) (any, error) {
+		if err := arity(args, 2, 2); err != nil {
+			return nil, err
+		}
+		pattern, ok1 := args[0].(string)
+		s, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		if whole {
+			pattern = "^(?:" + pattern + ")$"
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a regular expression", args[0])
+		}
+		return re.MatchString(s), nil
+	}
+}
+
+func regexReplaceFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 3, 3); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return args[0], nil
+	}
+	re, err := regexp.Compile(toString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a regular expression", args[1])
+	}
+	return re.ReplaceAllString(s, toString(args[2])), nil
+}
+
+func splitFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 2, 2); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, nil
+	}
+	re, err := regexp.Compile(toString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a regular expression", args[1])
+	}
+	parts := make([]any, 0)
+	for _, part := range re.Split(s, -1) {
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func joinFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 2); err != nil {
+		return nil, err
+	}
+	sep := ", "
+	if len(args) == 2 {
+		sep = toString(args[1])
+	}
+	parts := make([]string, 0)
+	for _, item := range items(args[0]) {
+		parts = append(parts, toString(item))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func defaultFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 2, 2); err != nil {
+		return nil, err
+	}
+	if args[0] == nil {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func choiceFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 3, 3); err != nil {
+		return nil, err
+	}
+	if truthy(args[0]) {
+		return args[1], nil
+	}
+	return args[2], nil
+}
+
+func roundFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 2); err != nil {
+		return nil, err
+	}
+	n, ok := args[0].(float64)
+	if !ok {
+		return nil, nil
+	}
+	digits := 0.0
+	if len(args) == 2 {
+		digits, _ = args[1].(float64)
+	}
+	scale := math.Pow(10, digits)
+	return math.Round(n*scale) / scale, nil
+}
+
+// Gets the number of a number or the first number in a string.
+func numberFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case float64:
+		return v, nil
+	case string:
+		if m := numberRegex.FindString(v); m != "" {
+			n, _ := strconv.ParseFloat(m, 64)
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+var (
+	numberRegex = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+	dayRegex    = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+)
+
+func toStringFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return toString(args[0]), nil
+}
+
+func typeofFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return typeName(args[0]), nil
+}
+
+func listFunction(e *env, args []any) (any, error) {
+	return append(make([]any, 0, len(args)), args...), nil
+}
+
+func nonNullFunction(e *env, args []any) (any, error) {
+	values := make([]any, 0)
+	for _, arg := range args {
+		for _, item := range items(arg) {
+			if item != nil {
+				values = append(values, item)
+			}
+		}
+	}
+	return values, nil
+}
+
+func flatFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	values := make([]any, 0)
+	for _, item := range items(args[0]) {
+		values = append(values, items(item)...)
+	}
+	return values, nil
+}
+
+func sortFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	values := append(make([]any, 0), items(args[0])...)
+	sort.SliceStable(values, func(i, j int) bool {
+		return compare(values[i], values[j]) < 0
+	})
+	return values, nil
+}
+
+func reverseFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	values := items(args[0])
+	reversed := make([]any, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		reversed = append(reversed, values[i])
+	}
+	return reversed, nil
+}
+
+func uniqueFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	values := make([]any, 0)
+	for _, item := range items(args[0]) {
+		if !contains(values, item, false) {
+			values = append(values, item)
+		}
+	}
+	return values, nil
+}
+
+// Gets the smallest or the largest value of the arguments or of the list, the nils
+// are skipped.
+func extremeFunction(sign int) function {
+	return func(e *env, args []any) (any, error) {
+		if len(args) == 1 {
+			args = items(args[0])
+		}
+		var best any
+		for _, v := range args {
+			if v != nil && (best == nil || compare(v, best)*sign > 0) {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
+
+func sumFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	var sum any
+	for _, item := range items(args[0]) {
+		if item == nil {
+			continue
+		}
+		if sum == nil {
+			sum = item
+			continue
+		}
+		var err error
+		if sum, err = arithmetic("+", sum, item); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
+
+func averageFunction(e *env, args []any) (any, error) {
+	if err := arity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	sum, n := 0.0, 0
+	for _, item := range items(args[0]) {
+		if f, ok := item.(float64); ok {
+			sum += f
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return sum / float64(n), nil
+}
+
+func allFunction(e *env, args []any) (any, error) {
+	if len(args) == 1 {
+		args = items(args[0])
+	}
+	for _, v := range args {
+		if !truthy(v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func anyFunction(e *env, args []any) (any, error) {
+	if len(args) == 1 {
+		args = items(args[0])
+	}
+	for _, v := range args {
+		if truthy(v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+)
+
+// The tokens of the Luxon format strings Dataview uses, with the moment.js formats
+// they are written with. The localized ones are written like the 'en-US' locale.
+var luxonTokens = map[string]string{
+	"y":    "Y",
+	"yy":   "YY",
+	"yyyy": "YYYY",
+	"kk":   "GG",
+	"kkkk": "GGGG",
+	"ii":   "gg",
+	"iiii": "gggg",
+	"q":    "Q",
+	"M":    "M",
+	"MM":   "MM",
+	"MMM":  "MMM",
+	"MMMM": "MMMM",
+	"L":    "M",
+	"LL":   "MM",
+	"LLL":  "MMM",
+	"LLLL": "MMMM",
+	"W":    "W",
+	"WW":   "WW",
+	"n":    "w",
+	"nn":   "ww",
+	"d":    "D",
+	"dd":   "DD",
+	"o":    "DDD",
+	"ooo":  "DDDD",
+	"E":    "E",
+	"EEE":  "ddd",
+	"EEEE": "dddd",
+	"c":    "E",
+	"ccc":  "ddd",
+	"cccc": "dddd",
+	"a":    "A",
+	"H":    "H",
+	"HH":   "HH",
+	"h":    "h",
+	"hh":   "hh",
+	"m":    "m",
+	"mm":   "mm",
+	"s":    "s",
+	"ss":   "ss",
+	"SSS":  "SSS",
+	"u":    "SSS",
+	"uu":   "SS",
+	"uuu":  "S",
+	"ZZ":   "Z",
+	"ZZZ":  "ZZ",
+	"X":    "X",
+	"x":    "x",
+	"D":    "M/D/YYYY",
+	"DD":   "MMM D, YYYY",
+	"DDD":  "MMMM D, YYYY",
+	"DDDD": "dddd, MMMM D, YYYY",
+	"t":    "h:mm A",
+	"tt":   "h:mm:ss A",
+	"T":    "HH:mm",
+	"TT":   "HH:mm:ss",
+	"f":    "M/D/YYYY, h:mm A",
+	"ff":   "MMM D, YYYY, h:mm A",
+	"F":    "M/D/YYYY, h:mm:ss A",
+	"FF":   "MMM D, YYYY, h:mm:ss A",
+}
+
+// Formats the time with a Luxon format string like 'yyyy-MM-dd', the ones of
+// dateformat() in Dataview. The tokens are runs of the same letter, the text in
+// single quotes is a literal and the unknown tokens are written as they are.
+func formatLuxon(t time.Time, layout string) string {
+	var b strings.Builder
+	runes := []rune(layout)
+	for i := 0; i < len(runes); {
+		if runes[i] == '\'' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == i+1 {
+				// Two quotes are a quote.
+				b.WriteRune('\'')
+			} else {
+				b.WriteString(string(runes[i+1 : end]))
+			}
+			i = end + 1
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && runes[end] == runes[i] {
+			end++
+		}
+		b.WriteString(formatLuxonToken(t, string(runes[i:end])))
+		i = end
+	}
+	return b.String()
+}
+
+func formatLuxonToken(t time.Time, tk string) string {
+	if format, ok := luxonTokens[tk]; ok {
+		return moment.Format(t, format)
+	}
+	switch tk {
+	case "yyyyyy":
+		return fmt.Sprintf("%06d", t.Year())
+	case "qq":
+		return fmt.Sprintf("%02d", moment.Quarter(t))
+	case "MMMMM", "LLLLL":
+		return moment.Format(t, "MMMM")[:1]
+	case "EEEEE", "ccccc":
+		return moment.Format(t, "dddd")[:1]
+	case "S":
+		return fmt.Sprint(t.Nanosecond() / int(time.Millisecond))
+	case "Z":
+		_, offset := t.Zone()
+		sign := "+"
+		if offset < 0 {
+			sign, offset = "-", -offset
+		}
+		if offset%3600 == 0 {
+			return fmt.Sprintf("%s%d", sign, offset/3600)
+		}
+		return fmt.Sprintf("%s%d:%02d", sign, offset/3600, offset/60%60)
+	case "ZZZZ":
+		name, _ := t.Zone()
+		return name
+	case "z":
+		return t.Location().String()
+	}
+	return tk
+}
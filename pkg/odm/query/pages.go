@@ -0,0 +1,314 @@
+package query
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/regions"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// A note the queries run over.
+type Page struct {
+	// The path of the note relative to the vault.
+	Path string
+
+	// The frontmatter properties, the inline fields and the 'file' object.
+	Fields Object
+
+	// The tasks of the note, with the fields of the note under them.
+	Tasks []Object
+
+	md *metadata.Metadata
+
+	// The resolved paths of the notes the note links to.
+	outlinks map[string]bool
+}
+
+// Runs the queries over the notes of a group.
+type Engine struct {
+	pages    []*Page
+	byPath   map[string]*Page
+	resolver *vault.Resolver
+
+	// The time of 'now', 'today' is its day.
+	Now time.Time
+}
+
+// Creates the engine over the notes of the group. The frontmatter, the inline fields
+// and the tasks of every note are read once, up front.
+func NewEngine(v *vault.Vault, g api.Group) (*Engine, error) {
+	r, err := v.Resolver()
+	if err != nil {
+		return nil, err
+	}
+	en := &Engine{pages: make([]*Page, 0), byPath: make(map[string]*Page), resolver: r, Now: time.Now()}
+
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		p, err := en.newPage(s, md)
+		if err != nil {
+			return nil, err
+		}
+		en.pages = append(en.pages, p)
+		en.byPath[p.Path] = p
+	}
+	sort.Slice(en.pages, func(i, j int) bool {
+		return en.pages[i].Path < en.pages[j].Path
+	})
+
+	// The inlinks are known once every note is read.
+	inlinks := make(map[string][]any)
+	for _, p := range en.pages {
+		for _, target := range sortedKeys(p.outlinks) {
+			inlinks[target] = append(inlinks[target], Link{Path: p.Path})
+		}
+	}
+	for _, p := range en.pages {
+		file := p.Fields["file"].(Object)
+		file["inlinks"] = append(make([]any, 0), inlinks[p.Path]...)
+	}
+	return en, nil
+}
+
+// Gets the pages of the engine, ordered by their paths.
+func (en *Engine) Pages() []*Page {
+	return en.pages
+}
+
+// Gets the page of the note at the path.
+func (en *Engine) Page(notePath string) (*Page, bool) {
+	p, ok := en.byPath[notePath]
+	return p, ok
+}
+
+func (en *Engine) newPage(s api.Set, md *metadata.Metadata) (*Page, error) {
+	data := *s.Data()
+	generated := generatedContents(data)
+	md = withoutGenerated(md, generated)
+	p := &Page{Path: md.Path, Fields: make(Object), md: md, outlinks: make(map[string]bool)}
+
+	frontmatter := make(Object)
+	for _, prop := range md.Frontmatter.Properties {
+		frontmatter[prop.Key] = propertyValue(prop)
+	}
+	for k, v := range frontmatter {
+		p.Fields[k] = v
+	}
+	addFields(p.Fields, md.Fields, func(f metadata.InlineField) bool { return true })
+
+	outlinks := make([]any, 0)
+	for _, l := range md.Links {
+		if l.External {
+			continue
+		}
+		if target, ok := en.resolver.Resolve(md.Path, l.Target); ok && target != md.Path && !p.outlinks[target] {
+			p.outlinks[target] = true
+			outlinks = append(outlinks, Link{Path: target})
+		}
+	}
+
+	etags := make([]any, 0)
+	tags := make([]any, 0)
+	seen := make(map[string]bool)
+	for _, t := range md.AllTags() {
+		etags = append(etags, "#"+t)
+		// The file.tags have the parents of the nested tags too, like in Dataview.
+		parts := strings.Split(t, "/")
+		for i := range parts {
+			if parent := "#" + strings.Join(parts[:i+1], "/"); !seen[parent] {
+				seen[parent] = true
+				tags = append(tags, parent)
+			}
+		}
+	}
+
+	aliases := make([]any, 0)
+	for _, a := range md.Aliases() {
+		aliases = append(aliases, a)
+	}
+
+	link := Link{Path: md.Path}
+	file := Object{
+		"name":        md.Title,
+		"path":        md.Path,
+		"folder":      folder(md.Path),
+		"ext":         strings.TrimPrefix(path.Ext(md.Path), "."),
+		"link":        link,
+		"size":        float64(len(data)),
+		"tags":        tags,
+		"etags":       etags,
+		"outlinks":    outlinks,
+		"aliases":     aliases,
+		"frontmatter": frontmatter,
+		"day":         nil,
+	}
+	if t, ok := parseDate(dayRegex.FindString(md.Title)); ok {
+		file["day"] = t
+	}
+	if attrs := s.Attributes(); attrs != nil {
+		if t, err := attrs.Created(); err == nil {
+			file["ctime"], file["cday"] = t, tasks.Day(t)
+		}
+		if t, err := attrs.Updated(); err == nil {
+			file["mtime"], file["mday"] = t, tasks.Day(t)
+		}
+	}
+	p.Fields["file"] = file
+
+	p.Tasks = make([]Object, 0)
+	taskList := make([]any, 0)
+	for _, t := range tasks.Parse(md.Path, data) {
+		if metadata.Inside(generated, t.Match.Begin) {
+			continue
+		}
+		obj := taskObject(t, link, md.Fields)
+		p.Tasks = append(p.Tasks, obj)
+		taskList = append(taskList, obj)
+	}
+	file["tasks"] = taskList
+	return p, nil
+}
+
+// Gets the contents of the managed regions, they are generated from the other notes.
+func generatedContents(data []byte) []api.Match {
+	contents := make([]api.Match, 0)
+	if rs, err := regions.Parse(data); err == nil {
+		for _, r := range rs {
+			contents = append(contents, r.Content)
+		}
+	}
+	return contents
+}
+
+// Gets a copy of the metadata without the tags, the links and the inline fields in
+// the managed regions, so the results of the queries aren't read back by them.
+func withoutGenerated(md *metadata.Metadata, generated []api.Match) *metadata.Metadata {
+	if len(generated) == 0 {
+		return md
+	}
+	clean := *md
+	clean.Tags = make([]metadata.Tag, 0, len(md.Tags))
+	for _, t := range md.Tags {
+		if !metadata.Inside(generated, t.Match.Begin) {
+			clean.Tags = append(clean.Tags, t)
+		}
+	}
+	clean.Links = make([]metadata.Link, 0, len(md.Links))
+	for _, l := range md.Links {
+		if !metadata.Inside(generated, l.Match.Begin) {
+			clean.Links = append(clean.Links, l)
+		}
+	}
+	clean.Fields = make([]metadata.InlineField, 0, len(md.Fields))
+	for _, f := range md.Fields {
+		if !metadata.Inside(generated, f.Match.Begin) {
+			clean.Fields = append(clean.Fields, f)
+		}
+	}
+	return &clean
+}
+
+// Gets the value of a frontmatter property, the lists as lists.
+func propertyValue(prop metadata.Property) any {
+	switch prop.Kind {
+	case metadata.PropertyList:
+		values := make([]any, 0, len(prop.Items))
+		for _, item := range prop.Items {
+			values = append(values, parseValue(item))
+		}
+		return values
+	case metadata.PropertyNested:
+		return prop.Value
+	}
+	return parseValue(prop.Value)
+}
+
+// Adds the inline fields kept by the filter. A key given more than once gets the list
+// of the values.
+func addFields(o Object, fields []metadata.InlineField, keep func(f metadata.InlineField) bool) {
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if !keep(f) {
+			continue
+		}
+		v := parseValue(f.Value)
+		if old, ok := o[f.Key]; ok && seen[f.Key] {
+			if l, ok := old.([]any); ok {
+				o[f.Key] = append(l, v)
+			} else {
+				o[f.Key] = []any{old, v}
+			}
+			continue
+		}
+		seen[f.Key] = true
+		o[f.Key] = v
+	}
+}
+
+// Creates the object of a task: the fields of the Tasks plugin and the inline fields
+// on the line of the task.
+func taskObject(t tasks.Task, link Link, fields []metadata.InlineField) Object {
+	tags := make([]any, 0, len(t.Tags))
+	for _, tag := range t.Tags {
+		tags = append(tags, "#"+tag)
+	}
+	obj := Object{
+		"text":      t.Text,
+		"status":    string(t.Status),
+		"checked":   t.Status != tasks.STATUS_TODO,
+		"completed": t.Status == tasks.STATUS_DONE,
+		"fullyCompleted": t.Status == tasks.STATUS_DONE ||
+			t.Status == tasks.STATUS_CANCELLED,
+		"line":     float64(t.Line),
+		"path":     t.Path,
+		"link":     link,
+		"tags":     tags,
+		"priority": float64(t.Priority),
+	}
+	for _, f := range t.Fields {
+		if f.Name == tasks.FIELD_RECURRENCE {
+			obj[f.Name] = f.Value
+		} else {
+			obj[f.Name] = parseValue(f.Value)
+		}
+	}
+	addFields(obj, fields, func(f metadata.InlineField) bool {
+		return f.Match.Begin >= t.Match.Begin && f.Match.End <= t.Match.End
+	})
+	return obj
+}
+
+// Resolves the link target written in the note at the path. The unresolved links
+// keep their targets.
+func (en *Engine) link(from, target, display string) Link {
+	if p, ok := en.resolver.Resolve(from, target); ok {
+		return Link{Path: p, Display: display}
+	}
+	return Link{Path: target, Display: display}
+}
+
+// Gets the folder of the path, empty for the root.
+func folder(notePath string) string {
+	if dir := path.Dir(notePath); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,640 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of the queries.
+const (
+	KIND_TABLE string = "TABLE"
+	KIND_LIST  string = "LIST"
+	KIND_TASK  string = "TASK"
+)
+
+// The kinds of the tokens.
+const (
+	tok_eof    int = 0
+	tok_ident  int = 1
+	tok_number int = 2
+	tok_string int = 3
+	tok_link   int = 4
+	tok_tag    int = 5
+	tok_op     int = 6
+)
+
+// The operators, the longer ones first.
+var operators = []string{"!=", "<=", ">=", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", ".", "!", "&", "|"}
+
+// The keywords starting the commands of a query.
+var commandKeywords = map[string]bool{"FROM": true, "WHERE": true, "SORT": true, "GROUP": true, "FLATTEN": true, "LIMIT": true}
+
+type token struct {
+	kind int
+	text string
+	pos  int
+	end  int
+}
+
+// A parsed query like
+//
+//	TABLE file.mtime AS "Modified", status FROM #project WHERE status != "done" SORT file.mtime DESC
+type Query struct {
+	// One of the KIND_* constants.
+	Kind string
+
+	// Whether the column of the notes or the groups is left out.
+	WithoutID bool
+
+	// The columns of the tables, or the value of the lists.
+	Columns []Column
+
+	// The notes the query runs over, nil for every note.
+	From source
+
+	// The commands run in the order they are written.
+	Commands []command
+}
+
+// A computed column of a query.
+type Column struct {
+	Name string
+	expr expr
+}
+
+// A data command of a query, one of WHERE, SORT, GROUP BY, FLATTEN or LIMIT.
+type command struct {
+	keyword string
+	exprs   []expr
+	desc    []bool
+	name    string
+	limit   int
+}
+
+type parser struct {
+	src    string
+	pos    int
+	peeked *token
+}
+
+// Parses the query. The keywords are in any case, the expressions are the ones of
+// Dataview: the fields of the notes, the literals like 3, "text", [[Note]], true and
+// null, the operators + - * / % = != < <= > >= and or ! and the function calls.
+func Parse(text string) (*Query, error) {
+	p := &parser{src: text}
+	q := &Query{Columns: make([]Column, 0), Commands: make([]command, 0)}
+
+	head := p.next()
+	if head.kind != tok_ident {
+		return nil, p.errorf(head, "the query must start with TABLE, LIST or TASK")
+	}
+	switch q.Kind = strings.ToUpper(head.text); q.Kind {
+	case KIND_TABLE, KIND_LIST:
+		if p.keyword("WITHOUT") {
+			if !p.keyword("ID") {
+				return nil, p.errorf(p.peek(), "expected ID after WITHOUT")
+			}
+			q.WithoutID = true
+		}
+		// A comma needs another column after it.
+		for !p.atCommand() && p.peek().kind != tok_eof || len(q.Columns) > 0 {
+			c, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			q.Columns = append(q.Columns, c)
+			if q.Kind == KIND_LIST || !p.op(",") {
+				break
+			}
+		}
+	case KIND_TASK:
+	default:
+		return nil, p.errorf(head, "the query must start with TABLE, LIST or TASK")
+	}
+
+	for p.peek().kind != tok_eof {
+		t := p.next()
+		keyword := strings.ToUpper(t.text)
+		if t.kind != tok_ident || !commandKeywords[keyword] {
+			return nil, p.errorf(t, "unexpected '%s'", t.text)
+		}
+
+		c := command{keyword: keyword}
+		switch keyword {
+		case "FROM":
+			if q.From != nil {
+				return nil, p.errorf(t, "FROM is given twice")
+			}
+			src, err := p.source()
+			if err != nil {
+				return nil, err
+			}
+			q.From = src
+			continue
+		case "WHERE":
+			e, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			c.exprs = []expr{e}
+		case "SORT":
+			for {
+				e, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				desc := false
+				if p.keyword("DESC") || p.keyword("DESCENDING") {
+					desc = true
+				} else if !p.keyword("ASC") {
+					p.keyword("ASCENDING")
+				}
+				c.exprs = append(c.exprs, e)
+				c.desc = append(c.desc, desc)
+				if !p.op(",") {
+					break
+				}
+			}
+		case "GROUP", "FLATTEN":
+			if keyword == "GROUP" {
+				if !p.keyword("BY") {
+					return nil, p.errorf(p.peek(), "expected BY after GROUP")
+				}
+				c.keyword = "GROUP BY"
+			}
+			begin := p.peek().pos
+			e, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			c.exprs = []expr{e}
+			c.name = strings.TrimSpace(p.src[begin:p.pos])
+			if p.keyword("AS") {
+				if c.name, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+		case "LIMIT":
+			n := p.next()
+			limit, err := strconv.Atoi(n.text)
+			if n.kind != tok_number || err != nil || limit < 0 {
+				return nil, p.errorf(n, "LIMIT needs a number")
+			}
+			c.limit = limit
+		}
+		q.Commands = append(q.Commands, c)
+	}
+	return q, nil
+}
+
+// Parses a column, an expression with an optional name given with AS.
+func (p *parser) column() (Column, error) {
+	begin := p.peek().pos
+	e, err := p.expression()
+	if err != nil {
+		return Column{}, err
+	}
+	c := Column{Name: strings.TrimSpace(p.src[begin:p.pos]), expr: e}
+	if p.keyword("AS") {
+		if c.Name, err = p.name(); err != nil {
+			return Column{}, err
+		}
+	}
+	return c, nil
+}
+
+// Parses the name after AS, a string or an identifier.
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tok_string && t.kind != tok_ident {
+		return "", p.errorf(t, "expected a name after AS")
+	}
+	return t.text, nil
+}
+
+// Parses the sources of FROM: #tags, "folders", [[links]] and outgoing([[links]])
+// combined with and, or and the negation.
+func (p *parser) source() (source, error) {
+	left, err := p.sourceAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") || p.op("|") {
+		right, err := p.sourceAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orSource{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) sourceAnd() (source, error) {
+	left, err := p.sourceUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") || p.op("&") {
+		right, err := p.sourceUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andSource{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) sourceUnary() (source, error) {
+	if p.op("-") || p.op("!") {
+		s, err := p.sourceUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notSource{s}, nil
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tok_tag:
+		return tagSource{tag: strings.ToLower(t.text)}, nil
+	case tok_string:
+		return folderSource{path: strings.Trim(t.text, "/")}, nil
+	case tok_link:
+		return linkSource{target: linkTarget(t.text)}, nil
+	case tok_ident:
+		if strings.EqualFold(t.text, "outgoing") && p.op("(") {
+			l := p.next()
+			if l.kind != tok_link || !p.op(")") {
+				return nil, p.errorf(l, "outgoing needs a link like outgoing([[Note]])")
+			}
+			return linkSource{target: linkTarget(l.text), outgoing: true}, nil
+		}
+	case tok_op:
+		if t.text == "(" {
+			s, err := p.source()
+			if err != nil {
+				return nil, err
+			}
+			if !p.op(")") {
+				return nil, p.errorf(p.peek(), "expected ')'")
+			}
+			return s, nil
+		}
+	}
+	return nil, p.errorf(t, "expected a #tag, a \"folder\" or a [[link]] after FROM")
+}
+
+// Parses an expression.
+func (p *parser) expression() (expr, error) {
+	return p.binary(0)
+}
+
+// The binary operators by their precedence, the lowest first.
+var precedence = [][]string{
+	{"or", "|"},
+	{"and", "&"},
+	{"=", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range precedence[level] {
+			if p.op(o) || (o == "or" || o == "and") && p.keyword(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "|":
+			op = "or"
+		case "&":
+			op = "and"
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.op("-") {
+		e, err := p.unary()
+		return unary{op: "-", operand: e}, err
+	}
+	if p.op("!") || p.keyword("not") {
+		e, err := p.unary()
+		return unary{op: "!", operand: e}, err
+	}
+	return p.postfix()
+}
+
+// Parses the field accesses, the indexes and the calls after a primary expression.
+func (p *parser) postfix() (expr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.op("."):
+			t := p.next()
+			if t.kind != tok_ident {
+				return nil, p.errorf(t, "expected a field name after '.'")
+			}
+			e = member{object: e, name: t.text}
+		case p.op("["):
+			i, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.op("]") {
+				return nil, p.errorf(p.peek(), "expected ']'")
+			}
+			e = index{object: e, index: i}
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tok_number:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "'%s' is not a number", t.text)
+		}
+		return literal{value: n}, nil
+	case tok_string:
+		return literal{value: t.text}, nil
+	case tok_link:
+		return linkLiteral{target: linkTarget(t.text), display: linkDisplay(t.text)}, nil
+	case tok_ident:
+		if commandKeywords[strings.ToUpper(t.text)] {
+			return nil, p.errorf(t, "expected an expression before %s", t.text)
+		}
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		case "null":
+			return literal{value: nil}, nil
+		}
+		if p.peekOp("(") {
+			return p.call(t)
+		}
+		return identifier{name: t.text}, nil
+	case tok_op:
+		switch t.text {
+		case "(":
+			e, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.op(")") {
+				return nil, p.errorf(p.peek(), "expected ')'")
+			}
+			return e, nil
+		case "[":
+			items := make([]expr, 0)
+			for !p.op("]") {
+				if len(items) > 0 && !p.op(",") {
+					return nil, p.errorf(p.peek(), "expected ',' or ']'")
+				}
+				e, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, e)
+			}
+			return list{items: items}, nil
+		}
+	case tok_eof:
+		return nil, p.errorf(t, "the query ends too early")
+	}
+	return nil, p.errorf(t, "unexpected '%s'", t.text)
+}
+
+// Parses the arguments of a call. The date and the dur functions also take their
+// argument as it is written, e.g. date(today) and dur(3 days).
+func (p *parser) call(name token) (expr, error) {
+	p.op("(")
+	c := call{name: strings.ToLower(name.text), args: make([]expr, 0)}
+	if c.name == "date" || c.name == "dur" {
+		if t := p.peek(); t.kind != tok_string && t.kind != tok_link && !(t.kind == tok_ident && p.isFieldAccess(t)) {
+			end := strings.IndexByte(p.src[p.pos:], ')')
+			if end < 0 {
+				return nil, p.errorf(name, "expected ')'")
+			}
+			c.args = append(c.args, literal{value: strings.TrimSpace(p.src[p.pos : p.pos+end])})
+			p.pos += end + 1
+			p.peeked = nil
+			return c, nil
+		}
+	}
+	for !p.op(")") {
+		if len(c.args) > 0 && !p.op(",") {
+			return nil, p.errorf(p.peek(), "expected ',' or ')'")
+		}
+		e, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, e)
+	}
+	return c, nil
+}
+
+// Whether the identifier is a field of the notes rather than a word like 'today' in
+// date(today), i.e. it is followed by a field access or it ends the arguments with a
+// known field.
+func (p *parser) isFieldAccess(t token) bool {
+	rest := strings.TrimLeft(p.src[t.pos+len(t.text):], " \t")
+	if strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, ",") {
+		return true
+	}
+	switch strings.ToLower(t.text) {
+	case "today", "now", "tomorrow", "yesterday", "sow", "eow", "som", "eom", "soy", "eoy":
+		return false
+	}
+	// A word followed by the closing parenthesis is a field, e.g. date(due).
+	return strings.HasPrefix(rest, ")")
+}
+
+// Consumes the operator if it is next.
+func (p *parser) op(text string) bool {
+	if p.peekOp(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) peekOp(text string) bool {
+	t := p.peek()
+	return t.kind == tok_op && t.text == text
+}
+
+// Consumes the keyword if it is next, in any case.
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tok_ident && strings.EqualFold(t.text, word) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// Whether a command keyword is next.
+func (p *parser) atCommand() bool {
+	t := p.peek()
+	return t.kind == tok_ident && commandKeywords[strings.ToUpper(t.text)]
+}
+
+func (p *parser) peek() token {
+	if p.peeked == nil {
+		pos := p.pos
+		t := p.lex()
+		t.end, p.pos = p.pos, pos
+		p.peeked = &t
+	}
+	return *p.peeked
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.peeked = nil
+	p.pos = t.end
+	return t
+}
+
+// Reads the token at the position and moves past it.
+func (p *parser) lex() token {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	begin := p.pos
+	if p.pos >= len(p.src) {
+		return token{kind: tok_eof, pos: begin}
+	}
+	rest := p.src[p.pos:]
+
+	switch {
+	case strings.HasPrefix(rest, "[["):
+		end := strings.Index(rest, "]]")
+		if end < 0 {
+			p.pos = len(p.src)
+			return token{kind: tok_op, text: "[[", pos: begin}
+		}
+		p.pos += end + 2
+		return token{kind: tok_link, text: rest[2:end], pos: begin}
+	case rest[0] == '"' || rest[0] == '\'':
+		var b strings.Builder
+		for i := 1; i < len(rest); i++ {
+			switch c := rest[i]; {
+			case c == rest[0]:
+				p.pos += i + 1
+				return token{kind: tok_string, text: b.String(), pos: begin}
+			case c == '\\' && i+1 < len(rest):
+				i++
+				switch rest[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(rest[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		p.pos = len(p.src)
+		return token{kind: tok_op, text: rest[:1], pos: begin}
+	case rest[0] == '#' && len(rest) > 1 && isIdentRune(rune(rest[1])):
+		end := 1
+		for end < len(rest) {
+			r, size := utf8.DecodeRuneInString(rest[end:])
+			if !isIdentRune(r) && r != '/' {
+				break
+			}
+			end += size
+		}
+		p.pos += end
+		return token{kind: tok_tag, text: rest[1:end], pos: begin}
+	case rest[0] >= '0' && rest[0] <= '9':
+		end := 0
+		for end < len(rest) && (rest[end] >= '0' && rest[end] <= '9' || rest[end] == '.' && end+1 < len(rest) && rest[end+1] >= '0' && rest[end+1] <= '9') {
+			end++
+		}
+		p.pos += end
+		return token{kind: tok_number, text: rest[:end], pos: begin}
+	}
+
+	if r, _ := utf8.DecodeRuneInString(rest); unicode.IsLetter(r) || r == '_' {
+		end := 0
+		for end < len(rest) {
+			r, size := utf8.DecodeRuneInString(rest[end:])
+			if !isIdentRune(r) {
+				break
+			}
+			end += size
+		}
+		p.pos += end
+		return token{kind: tok_ident, text: rest[:end], pos: begin}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			p.pos += len(op)
+			return token{kind: tok_op, text: op, pos: begin}
+		}
+	}
+	_, size := utf8.DecodeRuneInString(rest)
+	p.pos += size
+	return token{kind: tok_op, text: rest[:size], pos: begin}
+}
+
+// The characters of the identifiers after the first one. The dashes are in them like
+// in Dataview, 'due-date' is a field and 'a - b' a subtraction.
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+func (p *parser) errorf(t token, format string, obj ...any) error {
+	line := strings.Count(p.src[:t.pos], "\n") + 1
+	col := t.pos - strings.LastIndex(p.src[:t.pos], "\n")
+	return fmt.Errorf("%d:%d: %w", line, col, errors.New(fmt.Sprintf(format, obj...)))
+}
+
+// Gets the target of a link written as 'target#anchor|display'.
+func linkTarget(inner string) string {
+	target, _, _ := strings.Cut(inner, "|")
+	target, _, _ = strings.Cut(target, "#")
+	return strings.TrimSpace(target)
+}
+
+func linkDisplay(inner string) string {
+	_, display, _ := strings.Cut(inner, "|")
+	return strings.TrimSpace(display)
+}
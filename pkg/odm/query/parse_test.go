@@ -0,0 +1,87 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text      string
+		kind      string
+		withoutID bool
+		columns   []string
+		commands  []string
+		from      bool
+	}{
+		{`LIST`, KIND_LIST, false, nil, nil, false},
+		{`list from #project`, KIND_LIST, false, nil, nil, true},
+		{`LIST rating`, KIND_LIST, false, []string{"rating"}, nil, false},
+		{`TASK WHERE !completed`, KIND_TASK, false, nil, []string{"WHERE"}, false},
+		{`TABLE status, due AS "Due"`, KIND_TABLE, false, []string{"status", "Due"}, nil, false},
+		{`TABLE WITHOUT ID file.name AS Name`, KIND_TABLE, true, []string{"Name"}, nil, false},
+		{`TABLE length(rows) AS "Notes" GROUP BY status`, KIND_TABLE, false, []string{"Notes"}, []string{"GROUP BY"}, false},
+		{`LIST FROM "Projects" AND -#done WHERE a SORT b DESC, c LIMIT 3`, KIND_LIST, false, nil, []string{"WHERE", "SORT", "LIMIT"}, true},
+		{`LIST FLATTEN file.tags AS tag GROUP BY tag`, KIND_LIST, false, nil, []string{"FLATTEN", "GROUP BY"}, false},
+		{`TABLE (1 + 2) * 3, [[Note|Alias]], "a\"b", -1.5`, KIND_TABLE, false, []string{"(1 + 2) * 3", "[[Note|Alias]]", `"a\"b"`, "-1.5"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			q, err := Parse(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Kind != tt.kind {
+				t.Errorf("got kind %s, want %s", q.Kind, tt.kind)
+			}
+			if q.WithoutID != tt.withoutID {
+				t.Errorf("got without id %v, want %v", q.WithoutID, tt.withoutID)
+			}
+			if (q.From != nil) != tt.from {
+				t.Errorf("got from %v, want %v", q.From != nil, tt.from)
+			}
+
+			columns := make([]string, 0)
+			for _, c := range q.Columns {
+				columns = append(columns, c.Name)
+			}
+			if strings.Join(columns, "|") != strings.Join(tt.columns, "|") {
+				t.Errorf("got columns %q, want %q", columns, tt.columns)
+			}
+
+			commands := make([]string, 0)
+			for _, c := range q.Commands {
+				commands = append(commands, c.keyword)
+			}
+			if strings.Join(commands, "|") != strings.Join(tt.commands, "|") {
+				t.Errorf("got commands %q, want %q", commands, tt.commands)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`SELECT a`,
+		`TABLE WITHOUT a`,
+		`LIST FROM #a FROM #b`,
+		`LIST GROUP status`,
+		`LIST LIMIT x`,
+		`LIST LIMIT -1`,
+		`TABLE (1 + 2`,
+		`TABLE a,`,
+		`LIST WHERE`,
+		`LIST rating status`,
+		`TABLE "unterminated`,
+	}
+
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			if _, err := Parse(text); err == nil {
+				t.Fatalf("no error for %q", text)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package query
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+)
+
+// The formats of the results.
+const (
+	FORMAT_MARKDOWN string = "markdown"
+	FORMAT_JSON     string = "json"
+	FORMAT_CSV      string = "csv"
+)
+
+var Formats = []string{FORMAT_MARKDOWN, FORMAT_JSON, FORMAT_CSV}
+
+// The fields of the tasks in the json and the csv results, in this order.
+var taskKeys = []string{"path", "line", "status", "text", "completed", "priority", "tags", "due", "scheduled", "start", "done", "created", "cancelled", "recurrence"}
+
+// Creates the link from the note of the result to a note.
+type LinkFunc func(from, target string) string
+
+// How the markdown results are written.
+type RenderOptions struct {
+	// The note the result is written into, the links are relative to it.
+	From string
+
+	// Creates the links, the wikilinks of the paths if nil.
+	Link LinkFunc
+
+	// The moment.js formats of the dates and the times.
+	DateFormat string
+	TimeFormat string
+}
+
+// Writes the result in the format.
+func (res *Result) Render(format string, opts RenderOptions) (string, error) {
+	switch format {
+	case FORMAT_MARKDOWN:
+		return res.Markdown(opts), nil
+	case FORMAT_JSON:
+		return res.JSON()
+	case FORMAT_CSV:
+		return res.CSV()
+	}
+	return "", fmt.Errorf("unknown format '%s', use one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Writes the result as a markdown table or list, without the trailing new line.
+func (res *Result) Markdown(opts RenderOptions) string {
+	if opts.DateFormat == "" {
+		opts.DateFormat = "YYYY-MM-DD"
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "HH:mm"
+	}
+
+	lines := make([]string, 0, len(res.Rows)+2)
+	switch res.Kind {
+	case KIND_TABLE:
+		header, rule := "|", "|"
+		if !res.WithoutID {
+			header, rule = header+" "+cell(res.IDHeader)+" |", rule+" --- |"
+		}
+		for _, h := range res.Headers {
+			header, rule = header+" "+cell(h)+" |", rule+" --- |"
+		}
+		lines = append(lines, header, rule)
+		for _, row := range res.Rows {
+			line := "|"
+			if !res.WithoutID {
+				line += " " + cell(opts.text(row.ID)) + " |"
+			}
+			for _, v := range row.Values {
+				line += " " + cell(opts.text(v)) + " |"
+			}
+			lines = append(lines, line)
+		}
+	case KIND_LIST:
+		for _, row := range res.Rows {
+			text := opts.text(row.ID)
+			if len(row.Values) > 0 {
+				if res.WithoutID {
+					text = opts.text(row.Values[0])
+				} else {
+					text += ": " + opts.text(row.Values[0])
+				}
+			}
+			lines = append(lines, "- "+text)
+			for _, sub := range row.Rows {
+				lines = append(lines, "\t- "+opts.text(sub.ID))
+			}
+		}
+	case KIND_TASK:
+		for _, row := range res.Rows {
+			if res.Grouped {
+				lines = append(lines, "- "+opts.text(row.ID))
+				for _, sub := range row.Rows {
+					lines = append(lines, "\t"+opts.task(sub))
+				}
+			} else {
+				lines = append(lines, opts.task(row))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Writes the task as a checkbox with a link to its note.
+func (opts RenderOptions) task(row Row) string {
+	status, _ := row.Task["status"].(string)
+	text, _ := row.Task["text"].(string)
+	return fmt.Sprintf("- [%s] %s (%s)", status, text, opts.text(row.ID))
+}
+
+// Writes the value as markdown. The nulls, e.g. the keys of the groups without a
+// value, are a dash like in Dataview.
+func (opts RenderOptions) text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "\\-"
+	case Link:
+		link := ""
+		if opts.Link != nil {
+			link = opts.Link(opts.From, v.Path)
+		} else {
+			link = "[[" + v.Path + "]]"
+		}
+		if v.Display != "" && strings.HasPrefix(link, "[[") && strings.HasSuffix(link, "]]") && !strings.Contains(link, "|") {
+			link = strings.TrimSuffix(link, "]]") + "|" + v.Display + "]]"
+		}
+		return link
+	case time.Time:
+		if hasClock(v) {
+			return moment.Format(v, opts.DateFormat+" "+opts.TimeFormat)
+		}
+		return moment.Format(v, opts.DateFormat)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, opts.text(item))
+		}
+		return strings.Join(parts, ", ")
+	case Object:
+		parts := make([]string, 0, len(v))
+		for _, k := range keys(v) {
+			parts = append(parts, k+": "+opts.text(v[k]))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return toString(v)
+}
+
+// Escapes the text for a table cell, the pipes would end it.
+func cell(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), "|", "\\|")
+}
+
+// Writes the result as a json array. The links are the paths of the notes and the
+// dates are in the ISO format.
+func (res *Result) JSON() (string, error) {
+	data, err := json.MarshalIndent(res.Objects(), "", "  ")
+	return string(data), err
+}
+
+// Gets the rows as the objects of the json results. The tasks have their fields, the
+// other rows the columns by their names.
+func (res *Result) Objects() []map[string]any {
+	rows := make([]map[string]any, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		rows = append(rows, res.jsonRow(row))
+	}
+	return rows
+}
+
+func (res *Result) jsonRow(row Row) map[string]any {
+	obj := make(map[string]any)
+	if res.Kind == KIND_TASK && !res.Grouped {
+		for _, k := range taskKeys {
+			if v, ok := row.Task[k]; ok {
+				obj[k] = jsonValue(v)
+			}
+		}
+		return obj
+	}
+
+	if !res.WithoutID {
+		obj[res.IDHeader] = jsonValue(row.ID)
+	}
+	for i, v := range row.Values {
+		obj[res.Headers[i]] = jsonValue(v)
+	}
+	if row.Rows != nil {
+		sub := &Result{Kind: res.Kind, IDHeader: "File"}
+		rows := make([]any, 0, len(row.Rows))
+		for _, r := range row.Rows {
+			if res.Kind == KIND_TASK {
+				rows = append(rows, sub.jsonRow(r))
+			} else {
+				rows = append(rows, jsonValue(r.ID))
+			}
+		}
+		obj["rows"] = rows
+	}
+	return obj
+}
+
+// Converts the value for the json results.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case Link:
+		return v.Path
+	case time.Time, Duration:
+		return toString(v)
+	case []any:
+		values := make([]any, 0, len(v))
+		for _, item := range v {
+			values = append(values, jsonValue(item))
+		}
+		return values
+	case Object:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			obj[k] = jsonValue(item)
+		}
+		return obj
+	}
+	return v
+}
+
+// Writes the result as csv with a header row. The grouped lists and tasks have a row
+// for each of the notes or the tasks of the groups, after the key of their group.
+func (res *Result) CSV() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := make([]string, 0)
+	if res.Grouped && res.Kind != KIND_TABLE {
+		header = append(header, res.IDHeader)
+	}
+	switch {
+	case res.Kind == KIND_TASK:
+		header = append(header, taskKeys...)
+	case res.Grouped && res.Kind == KIND_LIST && len(res.Headers) == 0:
+		header = append(header, "File")
+	default:
+		if !res.WithoutID && !(res.Grouped && res.Kind != KIND_TABLE) {
+			header = append(header, res.IDHeader)
+		}
+		header = append(header, res.Headers...)
+	}
+	if err := w.Write(header); err != nil {
+		return "", err
+	}
+
+	for _, row := range res.Rows {
+		records := make([][]string, 0)
+		switch {
+		case res.Kind == KIND_TASK && res.Grouped:
+			for _, sub := range row.Rows {
+				records = append(records, append([]string{plain(row.ID)}, taskRecord(sub.Task)...))
+			}
+		case res.Kind == KIND_TASK:
+			records = append(records, taskRecord(row.Task))
+		case row.Rows != nil:
+			for _, sub := range row.Rows {
+				records = append(records, []string{plain(row.ID), plain(sub.ID)})
+			}
+		default:
+			record := make([]string, 0, len(row.Values)+1)
+			if !res.WithoutID || res.Grouped && res.Kind != KIND_TABLE {
+				record = append(record, plain(row.ID))
+			}
+			for _, v := range row.Values {
+				record = append(record, plain(v))
+			}
+			records = append(records, record)
+		}
+		if err := w.WriteAll(records); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n"), w.Error()
+}
+
+func taskRecord(task Object) []string {
+	record := make([]string, 0, len(taskKeys))
+	for _, k := range taskKeys {
+		record = append(record, plain(task[k]))
+	}
+	return record
+}
+
+// Writes the value for the csv results, the links as the paths and the lists joined
+// with commas.
+func plain(v any) string {
+	switch v := v.(type) {
+	case Link:
+		return v.Path
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, plain(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return toString(v)
+}
+
+// Converts the value to a string. The dates are in the ISO format and the links are
+// wikilinks.
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case time.Time:
+		if hasClock(v) {
+			return v.Format("2006-01-02T15:04:05")
+		}
+		return v.Format("2006-01-02")
+	case Duration:
+		return v.String()
+	case Link:
+		if v.Display != "" {
+			return "[[" + v.Name() + "|" + v.Display + "]]"
+		}
+		return "[[" + v.Name() + "]]"
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, toString(item))
+		}
+		return strings.Join(parts, ", ")
+	case Object:
+		parts := make([]string, 0, len(v))
+		for _, k := range keys(v) {
+			parts = append(parts, k+": "+toString(v[k]))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// Whether the date has a time of the day.
+func hasClock(t time.Time) bool {
+	return t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0
+}
+
+// Writes the duration like '1 month, 3 days, 2 hours'.
+func (d Duration) String() string {
+	parts := make([]string, 0)
+	add := func(n int, unit string) {
+		if n == 1 || n == -1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, unit))
+		} else if n != 0 {
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit))
+		}
+	}
+	add(d.Months/12, "year")
+	add(d.Months%12, "month")
+	add(d.Days/7, "week")
+	add(d.Days%7, "day")
+	add(int(d.Clock/time.Hour), "hour")
+	add(int(d.Clock%time.Hour/time.Minute), "minute")
+	add(int(d.Clock%time.Minute/time.Second), "second")
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, ", ")
+}
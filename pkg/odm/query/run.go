@@ -0,0 +1,335 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The notes a query runs over, given after FROM.
+type source interface {
+	match(en *Engine, from string, p *Page) bool
+}
+
+// The notes with the tag or one of its nested tags.
+type tagSource struct {
+	tag string
+}
+
+// The notes in the folder, or the note at the path.
+type folderSource struct {
+	path string
+}
+
+// The notes linking to the note, or the notes it links to if outgoing.
+type linkSource struct {
+	target   string
+	outgoing bool
+}
+
+type notSource struct {
+	source source
+}
+
+type andSource struct {
+	left, right source
+}
+
+type orSource struct {
+	left, right source
+}
+
+func (s tagSource) match(en *Engine, from string, p *Page) bool {
+	return p.md.HasTag(s.tag)
+}
+
+func (s folderSource) match(en *Engine, from string, p *Page) bool {
+	if s.path == "" {
+		return true
+	}
+	lower, prefix := strings.ToLower(p.Path), strings.ToLower(s.path)
+	return strings.HasPrefix(lower, prefix+"/") || lower == prefix || strings.TrimSuffix(lower, ".md") == prefix
+}
+
+func (s linkSource) match(en *Engine, from string, p *Page) bool {
+	target := en.link(from, s.target, "").Path
+	if s.outgoing {
+		t, ok := en.byPath[target]
+		return ok && t.outlinks[p.Path]
+	}
+	return p.outlinks[target]
+}
+
+func (s notSource) match(en *Engine, from string, p *Page) bool {
+	return !s.source.match(en, from, p)
+}
+
+func (s andSource) match(en *Engine, from string, p *Page) bool {
+	return s.left.match(en, from, p) && s.right.match(en, from, p)
+}
+
+func (s orSource) match(en *Engine, from string, p *Page) bool {
+	return s.left.match(en, from, p) || s.right.match(en, from, p)
+}
+
+// The result of a query.
+type Result struct {
+	// One of the KIND_* constants.
+	Kind string
+
+	// Whether the column of the notes or the groups is left out.
+	WithoutID bool
+
+	// Whether the rows are groups.
+	Grouped bool
+
+	// The name of the column of the notes or the groups, e.g. 'File'.
+	IDHeader string
+
+	// The names of the columns, or of the value of the lists.
+	Headers []string
+
+	Rows []Row
+}
+
+// A row of a result, a note, a task or a group.
+type Row struct {
+	// The link to the note, or the key of the group.
+	ID any
+
+	// The values of the columns.
+	Values []any
+
+	// The task, for the task queries.
+	Task Object
+
+	// The rows of the group, for the grouped lists and tasks.
+	Rows []Row
+}
+
+// A note, a task or a group while the commands run.
+type item struct {
+	id   any
+	obj  Object
+	rows []item
+
+	// The fields of the task alone, for the task queries.
+	task Object
+}
+
+// Runs the query from the note at the path, which is 'this' in the expressions and
+// where the links are resolved from. The path can be empty.
+func (en *Engine) Run(q *Query, from string) (*Result, error) {
+	e := &env{engine: en, from: from, this: Object{}}
+	if p, ok := en.byPath[from]; ok {
+		e.this = p.Fields
+	}
+
+	items := make([]item, 0)
+	for _, p := range en.pages {
+		if q.From != nil && !q.From.match(en, from, p) {
+			continue
+		}
+		id := p.Fields.file()["link"]
+		if q.Kind == KIND_TASK {
+			for _, t := range p.Tasks {
+				items = append(items, item{id: id, obj: overlay(p.Fields, t), task: t})
+			}
+		} else {
+			items = append(items, item{id: id, obj: p.Fields})
+		}
+	}
+
+	grouped := false
+	groupName := ""
+	for _, c := range q.Commands {
+		var err error
+		switch c.keyword {
+		case "WHERE":
+			items, err = where(e, items, c.exprs[0])
+		case "SORT":
+			err = sortItems(e, items, c.exprs, c.desc)
+		case "GROUP BY":
+			items, err = group(e, items, c.exprs[0], c.name)
+			grouped, groupName = true, c.name
+		case "FLATTEN":
+			items, err = flatten(e, items, c.exprs[0], c.name)
+		case "LIMIT":
+			if c.limit < len(items) {
+				items = items[:c.limit]
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.keyword, err)
+		}
+	}
+
+	res := &Result{Kind: q.Kind, WithoutID: q.WithoutID, Grouped: grouped, IDHeader: "File", Headers: make([]string, 0), Rows: make([]Row, 0)}
+	if grouped {
+		res.IDHeader = groupName
+	}
+	for _, c := range q.Columns {
+		res.Headers = append(res.Headers, c.Name)
+	}
+
+	for _, it := range items {
+		row := Row{ID: it.id, Values: make([]any, 0, len(q.Columns))}
+		e.row = it.obj
+		for _, c := range q.Columns {
+			v, err := c.expr.eval(e)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", c.Name, err)
+			}
+			row.Values = append(row.Values, v)
+		}
+		switch {
+		case q.Kind == KIND_TASK && !grouped:
+			row.Task = it.task
+		case q.Kind == KIND_TASK || q.Kind == KIND_LIST && grouped && len(q.Columns) == 0:
+			row.Rows = make([]Row, 0, len(it.rows))
+			for _, sub := range it.rows {
+				r := Row{ID: sub.id}
+				if q.Kind == KIND_TASK {
+					r.Task = sub.task
+				}
+				row.Rows = append(row.Rows, r)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// Gets the 'file' object of the fields of a note.
+func (o Object) file() Object {
+	f, _ := o["file"].(Object)
+	return f
+}
+
+// Creates an object with the fields of both, the ones of the top win.
+func overlay(bottom, top Object) Object {
+	o := make(Object, len(bottom)+len(top))
+	for k, v := range bottom {
+		o[k] = v
+	}
+	for k, v := range top {
+		o[k] = v
+	}
+	return o
+}
+
+func where(e *env, items []item, condition expr) ([]item, error) {
+	kept := make([]item, 0, len(items))
+	for _, it := range items {
+		e.row = it.obj
+		v, err := condition.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(v) {
+			kept = append(kept, it)
+		}
+	}
+	return kept, nil
+}
+
+// Sorts the items by the keys, the earlier keys first.
+func sortItems(e *env, items []item, keys []expr, desc []bool) error {
+	values := make([][]any, len(items))
+	for i, it := range items {
+		e.row = it.obj
+		values[i] = make([]any, len(keys))
+		for j, k := range keys {
+			v, err := k.eval(e)
+			if err != nil {
+				return err
+			}
+			values[i][j] = v
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for j := range keys {
+			c := compare(values[order[a]][j], values[order[b]][j])
+			if desc[j] {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	sorted := make([]item, len(items))
+	for i, o := range order {
+		sorted[i] = items[o]
+	}
+	copy(items, sorted)
+	return nil
+}
+
+// Groups the items by the value of the key. The groups are objects with the key, also
+// under its name, and the rows of the group, and they are sorted by their keys.
+func group(e *env, items []item, key expr, name string) ([]item, error) {
+	groups := make([]item, 0)
+	for _, it := range items {
+		e.row = it.obj
+		k, err := key.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for i := range groups {
+			if equal(groups[i].id, k) && typeRank(groups[i].id) == typeRank(k) {
+				groups[i].rows = append(groups[i].rows, it)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, item{id: k, rows: []item{it}})
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return compare(groups[i].id, groups[j].id) < 0
+	})
+	for i := range groups {
+		rows := make([]any, 0, len(groups[i].rows))
+		for _, it := range groups[i].rows {
+			rows = append(rows, it.obj)
+		}
+		groups[i].obj = Object{"key": groups[i].id, "rows": rows}
+		if name != "" {
+			groups[i].obj[name] = groups[i].id
+		}
+	}
+	return groups, nil
+}
+
+// Creates an item for each value of the list the expression gives, with the value
+// under the name. The other values give a single item.
+func flatten(e *env, items []item, value expr, name string) ([]item, error) {
+	flat := make([]item, 0, len(items))
+	for _, it := range items {
+		e.row = it.obj
+		v, err := value.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, v := range values {
+			obj := overlay(it.obj, Object{name: v})
+			flat = append(flat, item{id: it.id, obj: obj, rows: it.rows, task: it.task})
+		}
+	}
+	return flat, nil
+}
@@ -0,0 +1,272 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The values of the queries are nil, bool, float64, string, time.Time, Duration, Link,
+// []any and Object.
+
+// The fields of a note, a task or a group.
+type Object map[string]any
+
+// A link to a note.
+type Link struct {
+	// The path of the note if it is resolved, the target as it is written otherwise.
+	Path string
+
+	// The display text, empty if there is none.
+	Display string
+}
+
+// A length of time like Dataview's durations, the calendar parts are kept apart so
+// adding a month to a date moves it to the same day of the next month.
+type Duration struct {
+	Months int
+	Days   int
+	Clock  time.Duration
+}
+
+var (
+	dateValueRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2})?)?$`)
+	linkValueRegex = regexp.MustCompile(`^!?\[\[([^\[\]|#]*)(?:#[^\[\]|]*)?(?:\|([^\[\]]*))?\]\]$`)
+	durationRegex  = regexp.MustCompile(`(?i)(-?\d+(?:\.\d+)?)\s*(years?|yrs?|y|months?|mo|weeks?|wks?|w|days?|d|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)\b`)
+)
+
+// Gets the field of the object. The keys are looked up as they are, then in any case
+// and as the canonical keys of the inline fields, e.g. 'due-date' for 'Due Date'.
+func (o Object) Get(key string) (any, bool) {
+	if v, ok := o[key]; ok {
+		return v, true
+	}
+	canonical := metadata.CanonicalKey(key)
+	for k, v := range o {
+		if strings.EqualFold(k, key) || metadata.CanonicalKey(k) == canonical {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Gets the name of the note of the link, without the folders and the extension.
+func (l Link) Name() string {
+	return strings.TrimSuffix(path.Base(l.Path), vault.NOTE_EXTENSION)
+}
+
+// Gets the duration as a number of days, the months are 30 days.
+func (d Duration) days() float64 {
+	return float64(d.Months*30+d.Days) + d.Clock.Hours()/24
+}
+
+// Parses a duration like '3 days', '1 week 2 days' or '1h 30m'.
+func ParseDuration(text string) (Duration, error) {
+	d := Duration{}
+	rest := durationRegex.ReplaceAllStringFunc(text, func(part string) string {
+		m := durationRegex.FindStringSubmatch(part)
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch unit := strings.ToLower(m[2]); {
+		case strings.HasPrefix(unit, "y"):
+			d.Months += int(n * 12)
+		case strings.HasPrefix(unit, "mo"):
+			d.Months += int(n)
+		case strings.HasPrefix(unit, "w"):
+			d.Days += int(n * 7)
+		case strings.HasPrefix(unit, "d"):
+			d.Days += int(n)
+		case strings.HasPrefix(unit, "h"):
+			d.Clock += time.Duration(n * float64(time.Hour))
+		case strings.HasPrefix(unit, "m"):
+			d.Clock += time.Duration(n * float64(time.Minute))
+		default:
+			d.Clock += time.Duration(n * float64(time.Second))
+		}
+		return ""
+	})
+	if strings.Trim(rest, " ,") != "" || strings.TrimSpace(text) == "" {
+		return Duration{}, fmt.Errorf("'%s' is not a duration", text)
+	}
+	return d, nil
+}
+
+// Adds the duration n times to the date.
+func addDuration(t time.Time, d Duration, n int) time.Time {
+	return t.AddDate(0, n*d.Months, n*d.Days).Add(time.Duration(n) * d.Clock)
+}
+
+// Parses a frontmatter or an inline field value: the numbers, the booleans, the dates,
+// the links and the rest as strings.
+func parseValue(text string) any {
+	text = strings.TrimSpace(text)
+	switch strings.ToLower(text) {
+	case "":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "~":
+		return nil
+	}
+	if n, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+		return n
+	}
+	if t, ok := parseDate(text); ok {
+		return t
+	}
+	if m := linkValueRegex.FindStringSubmatch(text); m != nil {
+		return Link{Path: m[1], Display: m[2]}
+	}
+	return text
+}
+
+// Parses the ISO dates, with or without the time.
+func parseDate(text string) (time.Time, bool) {
+	if !dateValueRegex.MatchString(text) {
+		return time.Time{}, false
+	}
+	text = strings.Replace(text, " ", "T", 1)
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Whether the value counts as true: everything but nil, false, zero, the empty
+// strings and the empty lists.
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case Object:
+		return len(v) > 0
+	}
+	return true
+}
+
+// The order of the types when values of different types are compared.
+func typeRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case Duration:
+		return 3
+	case time.Time:
+		return 4
+	case string:
+		return 5
+	case Link:
+		return 6
+	case []any:
+		return 7
+	}
+	return 8
+}
+
+// Compares the values, negative if a comes first. The values of different types are
+// ordered by their types, nil first.
+func compare(a, b any) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		// A link and a string are compared by the name of the note.
+		if l, ok := a.(Link); ok {
+			if s, ok := b.(string); ok {
+				return strings.Compare(strings.ToLower(l.Name()), strings.ToLower(s))
+			}
+		}
+		if s, ok := a.(string); ok {
+			if l, ok := b.(Link); ok {
+				return strings.Compare(strings.ToLower(s), strings.ToLower(l.Name()))
+			}
+		}
+		return ra - rb
+	}
+
+	switch a := a.(type) {
+	case bool:
+		b := b.(bool)
+		if a == b {
+			return 0
+		} else if b {
+			return -1
+		}
+		return 1
+	case float64:
+		return compareFloats(a, b.(float64))
+	case Duration:
+		return compareFloats(a.days(), b.(Duration).days())
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	case Link:
+		return strings.Compare(strings.ToLower(a.Path), strings.ToLower(b.(Link).Path))
+	case []any:
+		b := b.([]any)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	}
+	return 0
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// Checks if the values are the same. The links are the same if they are to the same
+// note, a link and a string if the string is the name of the note.
+func equal(a, b any) bool {
+	la, aLink := a.(Link)
+	lb, bLink := b.(Link)
+	if aLink && bLink {
+		return strings.EqualFold(la.Path, lb.Path) || strings.EqualFold(la.Name(), lb.Name())
+	}
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if typeRank(a) != typeRank(b) && !(aLink && bString || aString && bLink) {
+		return false
+	}
+	return compare(a, b) == 0
+}
+
+// Sorts the keys of the object, for a stable output.
+func keys(o Object) []string {
+	ks := make([]string, 0, len(o))
+	for k := range o {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,162 @@
+package regions
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The attributes of the begin markers.
+const (
+	// The selector query of the notes, e.g. 'tag:#project status:active'.
+	ATTR_QUERY string = "query"
+
+	// How the notes are written, one of the FORMAT_* constants.
+	ATTR_FORMAT string = "format"
+
+	// The frontmatter properties in the columns of the tables, separated by commas.
+	ATTR_FIELDS string = "fields"
+
+	// The key the notes are sorted by, path, title or a property. A '-' before it
+	// reverses the order.
+	ATTR_SORT string = "sort"
+
+	// The most notes written.
+	ATTR_LIMIT string = "limit"
+
+	// A Dataview-style query used instead of the selector query, e.g.
+	// 'TABLE status FROM #project SORT due'.
+	ATTR_DQL string = "dql"
+)
+
+// The formats of the generated contents.
+const (
+	FORMAT_LIST  string = "list"
+	FORMAT_TABLE string = "table"
+)
+
+// A region of a note whose contents are generated, between the marker comments
+//
+//	<!-- odm:begin query="tag:#project status:active" -->
+//	...
+//	<!-- odm:end -->
+type Region struct {
+	// The attributes of the begin marker.
+	Attrs map[string]string
+
+	// Cover the marker comments.
+	Begin, End api.Match
+
+	// Covers the contents between the markers.
+	Content api.Match
+
+	// The 1 based line of the begin marker.
+	Line int
+}
+
+// Generates the contents of a region, without the new lines around them.
+type RenderFunc func(r Region) (string, error)
+
+var (
+	markerRegex = regexp.MustCompile(`<!--[ \t]*odm:(begin|end)\b(.*?)-->`)
+	attrRegex   = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_\-]*)=(?:"([^"]*)"|'([^']*)')`)
+)
+
+// Gets the query of the region, the dql one if it has one.
+func (r Region) Query() string {
+	if dql, ok := r.Attrs[ATTR_DQL]; ok {
+		return dql
+	}
+	return r.Attrs[ATTR_QUERY]
+}
+
+// Finds the regions of the note. The markers in the code blocks are skipped. A begin
+// marker without an end, an end marker without a begin or a region inside another one
+// is an error.
+func Parse(data []byte) ([]Region, error) {
+	fm := metadata.ParseFrontmatter(data)
+	code := metadata.FindCode(data, fm.Match.End)
+	lines := metadata.NewLineIndex(data)
+
+	regions := make([]Region, 0)
+	var open *Region
+	for _, m := range markerRegex.FindAllSubmatchIndex(data, -1) {
+		if m[0] < fm.Match.End || metadata.Inside(code, m[0]) {
+			continue
+		}
+		line, _ := lines.Position(m[0])
+		marker := api.Match{Begin: m[0], End: m[1]}
+
+		if string(data[m[2]:m[3]]) == "end" {
+			if open == nil {
+				return nil, fmt.Errorf("line %d: odm:end without an odm:begin", line)
+			}
+			open.End = marker
+			open.Content = api.Match{Begin: open.Begin.End, End: marker.Begin}
+			regions = append(regions, *open)
+			open = nil
+			continue
+		}
+
+		if open != nil {
+			return nil, fmt.Errorf("line %d: odm:begin inside the region of line %d", line, open.Line)
+		}
+		attrs := make(map[string]string)
+		for _, a := range attrRegex.FindAllSubmatch(data[m[4]:m[5]], -1) {
+			if a[2] != nil {
+				attrs[string(a[1])] = string(a[2])
+			} else {
+				attrs[string(a[1])] = string(a[3])
+			}
+		}
+		open = &Region{Attrs: attrs, Begin: marker, Line: line}
+	}
+	if open != nil {
+		return nil, fmt.Errorf("line %d: odm:begin without an odm:end", open.Line)
+	}
+	return regions, nil
+}
+
+// Generates the contents of every region of the note again, and replaces the ones
+// which changed. The contents are written on their own lines between the markers.
+// The note is left as it is if nothing changed. Returns the changed regions.
+func Refresh(s api.Set, render RenderFunc) ([]Region, error) {
+	regions, err := Parse(*s.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	data := *s.Data()
+	changed := make([]Region, 0)
+	matches := make([]api.Match, 0)
+	contents := make(map[int]string)
+	for _, r := range regions {
+		text, err := render(r)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.Line, err)
+		}
+		content := "\n"
+		if text != "" {
+			content = "\n" + text + "\n"
+		}
+		if string(data[r.Content.Begin:r.Content.End]) == content {
+			continue
+		}
+		changed = append(changed, r)
+		matches = append(matches, r.Content)
+		contents[r.Content.Begin] = content
+	}
+
+	if len(matches) == 0 {
+		return changed, nil
+	}
+	_, err = s.Replace(&matches, func(m api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(contents[m.Begin]), true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,187 @@
+package regions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/selector"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// Creates the link from the note of the region to a note.
+type LinkFunc func(from, target string) string
+
+// Renders the regions with selector queries over the notes of a group.
+type Renderer struct {
+	vault *vault.Vault
+	group api.Group
+	link  LinkFunc
+
+	// Renders the regions with a dql query, they fail if it is nil.
+	DQL func(notePath, dql string) (string, error)
+}
+
+// A note found by the query of a region.
+type row struct {
+	path string
+	md   *metadata.Metadata
+}
+
+// Creates the renderer of the regions over the notes of the group.
+func NewRenderer(v *vault.Vault, g api.Group, link LinkFunc) *Renderer {
+	return &Renderer{vault: v, group: g, link: link}
+}
+
+// Gets the render function of the regions of the note at the path. The note itself
+// is never in its own results.
+func (rd *Renderer) For(notePath string) RenderFunc {
+	return func(r Region) (string, error) {
+		return rd.Render(notePath, r)
+	}
+}
+
+// Generates the contents of the region of the note at the path, a list of links to
+// the notes matching its query or a table of their properties.
+func (rd *Renderer) Render(notePath string, r Region) (string, error) {
+	if dql, ok := r.Attrs[ATTR_DQL]; ok {
+		if rd.DQL == nil {
+			return "", fmt.Errorf("the dql queries are not supported here")
+		}
+		return rd.DQL(notePath, dql)
+	}
+
+	sel, err := selector.Parse(r.Query())
+	if err != nil {
+		return "", err
+	}
+	sets, err := sel.Select(rd.vault, rd.group)
+	if err != nil {
+		return "", err
+	}
+
+	rows := make([]row, 0, len(sets))
+	for _, s := range sets {
+		if p := rd.vault.RelPath(s); p != notePath {
+			md, err := rd.vault.Metadata(s)
+			if err != nil {
+				return "", err
+			}
+			rows = append(rows, row{path: p, md: md})
+		}
+	}
+
+	if key := r.Attrs[ATTR_SORT]; key != "" {
+		sortRows(rows, key)
+	}
+	if limit, ok := r.Attrs[ATTR_LIMIT]; ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("'%s' is not a limit", limit)
+		}
+		if n < len(rows) {
+			rows = rows[:n]
+		}
+	}
+
+	switch format := r.Attrs[ATTR_FORMAT]; format {
+	case "", FORMAT_LIST:
+		lines := make([]string, 0, len(rows))
+		for _, row := range rows {
+			lines = append(lines, "- "+rd.link(notePath, row.path))
+		}
+		return strings.Join(lines, "\n"), nil
+	case FORMAT_TABLE:
+		return rd.table(notePath, rows, fields(r.Attrs[ATTR_FIELDS])), nil
+	default:
+		return "", fmt.Errorf("unknown format '%s', use %s or %s", format, FORMAT_LIST, FORMAT_TABLE)
+	}
+}
+
+// Writes the rows as a table with a column for each of the properties.
+func (rd *Renderer) table(notePath string, rows []row, keys []string) string {
+	var b strings.Builder
+	b.WriteString("| Note |")
+	for _, k := range keys {
+		b.WriteString(" " + cell(k) + " |")
+	}
+	b.WriteString("\n| --- |" + strings.Repeat(" --- |", len(keys)))
+	for _, row := range rows {
+		b.WriteString("\n| " + cell(rd.link(notePath, row.path)) + " |")
+		for _, k := range keys {
+			b.WriteString(" " + cell(value(row.md, k)) + " |")
+		}
+	}
+	return b.String()
+}
+
+// Splits the fields attribute.
+func fields(attr string) []string {
+	keys := make([]string, 0)
+	for _, k := range strings.Split(attr, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Gets the value of the key for a note, the path and the title or a property. The
+// lists are joined with commas.
+func value(md *metadata.Metadata, key string) string {
+	switch key {
+	case selector.FIELD_PATH:
+		return md.Path
+	case selector.FIELD_TITLE:
+		return md.Title
+	}
+	p, ok := md.Frontmatter.Get(key)
+	if !ok {
+		return ""
+	}
+	if p.Kind == metadata.PropertyList {
+		return strings.Join(p.Items, ", ")
+	}
+	return p.Value
+}
+
+// Escapes the text for a table cell, the pipes would end it.
+func cell(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), "|", "\\|")
+}
+
+// Sorts the rows by the key, the numbers as numbers. The rows without a value go
+// last either way.
+func sortRows(rows []row, key string) {
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := value(rows[i].md, key), value(rows[j].md, key)
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		c := strings.Compare(strings.ToLower(a), strings.ToLower(b))
+		if x, err := strconv.ParseFloat(a, 64); err == nil {
+			if y, err := strconv.ParseFloat(b, 64); err == nil {
+				c = compareFloats(x, y)
+			}
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package related
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/search"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The words of the title and the tags count this many times more than the body.
+const titleBoost = 3
+const tagBoost = 2
+
+// A sparse vector, normalized to the unit length.
+type vector map[string]float64
+
+// The TF-IDF vectors of the notes in a group.
+type Model struct {
+	vectors map[string]vector
+	links   map[string]map[string]bool
+}
+
+// A note similar to the given one.
+type Suggestion struct {
+	// The path of the note relative to the vault.
+	Path  string
+	Score float64
+}
+
+// Builds the model over the notes of the group. Everything is computed locally, the
+// cost is linear in the size of the notes. The sections under the heading, the ones
+// InsertSection writes, are left out so the suggestions don't feed on themselves.
+func NewModel(v *vault.Vault, g api.Group, heading string) (*Model, error) {
+	resolver, err := v.Resolver()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]map[string]int)
+	df := make(map[string]int)
+	links := make(map[string]map[string]bool)
+
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+
+		data := *s.Data()
+		section, _ := findSection(md, data, heading)
+		tf := termCounts(md, data, section)
+		counts[md.Path] = tf
+		for term := range tf {
+			df[term] += 1
+		}
+
+		links[md.Path] = make(map[string]bool)
+		for _, l := range md.Links {
+			if l.External || l.Match.Begin >= section.Begin && l.Match.Begin < section.End {
+				continue
+			}
+			if target, ok := resolver.Resolve(md.Path, l.Target); ok {
+				links[md.Path][target] = true
+			}
+		}
+	}
+
+	n := float64(len(counts))
+	vectors := make(map[string]vector)
+	for path, tf := range counts {
+		vec := make(vector)
+		norm := 0.0
+		for term, c := range tf {
+			// Sublinear term frequency, smoothed inverse document frequency.
+			w := (1 + math.Log(float64(c))) * math.Log((1+n)/(1+float64(df[term])))
+			if w <= 0 {
+				continue
+			}
+			vec[term] = w
+			norm += w * w
+		}
+		norm = math.Sqrt(norm)
+		for term := range vec {
+			vec[term] /= norm
+		}
+		vectors[path] = vec
+	}
+
+	return &Model{vectors: vectors, links: links}, nil
+}
+
+// Counts the terms of the note, the text of the section is left out.
+func termCounts(md *metadata.Metadata, data []byte, section api.Match) map[string]int {
+	tf := make(map[string]int)
+	body := data[md.Frontmatter.Match.End:]
+	if section.End > section.Begin {
+		body = append(append([]byte{}, data[md.Frontmatter.Match.End:section.Begin]...), data[section.End:]...)
+	}
+	for _, t := range search.Tokenize(body, 0) {
+		tf[t.Term] += 1
+	}
+	for _, t := range search.Tokenize([]byte(md.Title), 0) {
+		tf[t.Term] += titleBoost
+	}
+	for _, tag := range md.AllTags() {
+		tf["#"+strings.ToLower(tag)] += tagBoost
+	}
+	return tf
+}
+
+// Gets the k most similar notes to the given one, excluding itself and the notes it
+// already links to. Notes with nothing in common are never returned.
+func (m *Model) Related(path string, k int) ([]Suggestion, error) {
+	vec, ok := m.vectors[path]
+	if !ok {
+		return nil, fmt.Errorf("note '%s' is not in the group", path)
+	}
+
+	suggestions := make([]Suggestion, 0)
+	for other, ovec := range m.vectors {
+		if other == path || m.links[path][other] {
+			continue
+		}
+		if score := dot(vec, ovec); score > 0 {
+			suggestions = append(suggestions, Suggestion{Path: other, Score: score})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Path < suggestions[j].Path
+	})
+
+	if k > 0 && len(suggestions) > k {
+		suggestions = suggestions[:k]
+	}
+	return suggestions, nil
+}
+
+func dot(a, b vector) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	sum := 0.0
+	for term, w := range a {
+		sum += w * b[term]
+	}
+	return sum
+}
@@ -0,0 +1,75 @@
+package related
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The default heading of the section.
+const DEFAULT_HEADING = "Related"
+
+// Writes the suggestions as a list of wikilinks under the heading. If the note already
+// has the heading, the section under it is replaced, otherwise a level two heading is
+// appended to the end of the note. Returns false if the section is already the same.
+func InsertSection(s api.Set, md *metadata.Metadata, heading string, suggestions []Suggestion, r *vault.Resolver) (bool, error) {
+	list := new(strings.Builder)
+	for _, sg := range suggestions {
+		fmt.Fprintf(list, "- [[%s]]\n", r.ShortestTarget(sg.Path))
+	}
+
+	data := *s.Data()
+	if i, ok := headingIndex(md, heading); ok {
+		section := md.Section(i, data)
+		begin, end := section.Begin, section.End
+
+		content := "\n" + list.String()
+		if end < len(data) {
+			content += "\n"
+		}
+		if string(data[begin:end]) == content {
+			return false, nil
+		}
+
+		return s.Replace(&[]api.Match{{Begin: begin, End: end}}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+			return []byte(content), true
+		})
+	}
+
+	section := fmt.Sprintf("## %s\n\n%s", heading, list.String())
+	if len(data) > 0 {
+		if data[len(data)-1] != '\n' {
+			section = "\n\n" + section
+		} else if len(data) < 2 || data[len(data)-2] != '\n' {
+			section = "\n" + section
+		}
+	}
+
+	end := api.Match{Begin: len(data), End: len(data)}
+	return s.InsertAfter(&[]api.Match{end}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(section), true
+	})
+}
+
+// Gets the index of the heading of the section.
+func headingIndex(md *metadata.Metadata, heading string) (int, bool) {
+	for i, h := range md.Headings {
+		if heading != "" && strings.EqualFold(strings.TrimSpace(h.Text), heading) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Gets the section under the heading, the heading included. Returns false if the note
+// doesn't have it.
+func findSection(md *metadata.Metadata, data []byte, heading string) (api.Match, bool) {
+	i, ok := headingIndex(md, heading)
+	if !ok {
+		return api.Match{}, false
+	}
+	return api.Match{Begin: md.Headings[i].Match.Begin, End: md.Section(i, data).End}, true
+}
@@ -0,0 +1,76 @@
+package related
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+func TestInsertSectionTwice(t *testing.T) {
+	dir := t.TempDir()
+	notes := map[string]string{
+		"Go.md":       "Go is a language with goroutines, channels and fast compilation.\n",
+		"Channels.md": "Channels connect the goroutines of a Go program.\n",
+		"Compile.md":  "The Go compiler makes the compilation fast.\n",
+		"Language.md": "A language with a small syntax like Go.\n",
+		"Pasta.md":    "Boil the pasta in salted water.\n",
+	}
+	for name, text := range notes {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := vault.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	g, err := v.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := v.Resolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var note api.Set
+	for _, s := range g.Sets() {
+		if v.RelPath(s) == "Go.md" {
+			note = s
+		}
+	}
+
+	insert := func() (bool, string) {
+		model, err := NewModel(v, g, DEFAULT_HEADING)
+		if err != nil {
+			t.Fatal(err)
+		}
+		suggestions, err := model.Related("Go.md", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		md, err := v.Metadata(note)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := InsertSection(note, md, DEFAULT_HEADING, suggestions, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, string(*note.Data())
+	}
+
+	ok, first := insert()
+	if !ok {
+		t.Fatal("the section is not inserted")
+	}
+	ok, second := insert()
+	if ok || second != first {
+		t.Fatalf("the second insert changed the note:\n%s\nto\n%s", first, second)
+	}
+}
@@ -0,0 +1,324 @@
+package review
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/periodic"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/tasks"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The frontmatter property with the creation date of a note. The files have no
+// creation time to go by, their modification time is not one.
+const CREATED_KEY string = "created"
+
+// Creates the link from the review note to a note.
+type LinkFunc func(from, target string) string
+
+// What goes into a review.
+type Options struct {
+	// The first and the last day of the review, both included.
+	From, To time.Time
+
+	// The headings whose contents are collected from the daily notes, in any case.
+	Sections []string
+
+	// The frontmatter keys of the daily notes summed up. Every key with a number is
+	// taken if there are none.
+	Metrics []string
+
+	// The path of the review note, it is left out of the review.
+	Path string
+}
+
+// The contents of a section of a daily note.
+type Entry struct {
+	// The path of the daily note.
+	Source string
+	Date   time.Time
+	Text   string
+}
+
+// A section collected from the daily notes.
+type Section struct {
+	Name    string
+	Entries []Entry
+}
+
+// A frontmatter value of the daily notes summed up.
+type Metric struct {
+	Key string
+
+	// The number of the daily notes having it.
+	Days int
+
+	Sum, Min, Max float64
+}
+
+// A note created during the review.
+type Note struct {
+	Path    string
+	Created time.Time
+}
+
+// The review of the days, collected from the daily notes and the rest of the vault.
+type Review struct {
+	From, To time.Time
+
+	// The paths of the daily notes of the days, sorted by their dates.
+	Days []string
+
+	Sections []Section
+
+	// The tasks done during the days, the ones in the daily notes without a done date
+	// included.
+	Tasks []tasks.Task
+
+	// The notes created during the days, the daily notes aside.
+	Created []Note
+
+	Metrics []Metric
+}
+
+// Gets the average of the metric.
+func (m Metric) Average() float64 {
+	if m.Days == 0 {
+		return 0
+	}
+	return m.Sum / float64(m.Days)
+}
+
+// Collects the review of the days from the notes of the group. The daily notes are
+// found with their settings.
+func Collect(v *vault.Vault, g api.Group, daily periodic.Settings, opts Options) (*Review, error) {
+	from, to := tasks.Day(opts.From), tasks.Day(opts.To)
+	r := &Review{From: from, To: to, Days: make([]string, 0), Tasks: make([]tasks.Task, 0), Created: make([]Note, 0)}
+
+	sets := g.Sets()
+	sort.Slice(sets, func(i, j int) bool {
+		return v.RelPath(sets[i]) < v.RelPath(sets[j])
+	})
+
+	days := make([]api.Set, 0)
+	dates := make(map[string]time.Time)
+	for _, s := range sets {
+		p := v.RelPath(s)
+		if date, ok := daily.Date(p, from.Location()); ok && !date.Before(from) && !date.After(to) {
+			days = append(days, s)
+			dates[p] = date
+		}
+	}
+	sort.SliceStable(days, func(i, j int) bool {
+		return dates[v.RelPath(days[i])].Before(dates[v.RelPath(days[j])])
+	})
+	for _, s := range days {
+		r.Days = append(r.Days, v.RelPath(s))
+	}
+
+	r.Sections = sections(v, days, dates, opts.Sections)
+	r.Metrics = metrics(v, days, opts.Metrics)
+
+	for _, s := range sets {
+		p := v.RelPath(s)
+		if p == opts.Path {
+			continue
+		}
+		_, isDay := dates[p]
+		for _, t := range tasks.Parse(p, *s.Data()) {
+			if t.Status != tasks.STATUS_DONE {
+				continue
+			}
+			if done, ok := t.Date(tasks.FIELD_DONE); ok {
+				if !done.Before(from) && !done.After(to) {
+					r.Tasks = append(r.Tasks, t)
+				}
+			} else if isDay {
+				r.Tasks = append(r.Tasks, t)
+			}
+		}
+
+		if _, ok := daily.Date(p, from.Location()); ok {
+			continue
+		}
+		if created, ok := createdAt(s); ok && !tasks.Day(created).Before(from) && !tasks.Day(created).After(to) {
+			r.Created = append(r.Created, Note{Path: p, Created: created})
+		}
+	}
+	// The tasks without a done date were done on the day of their note.
+	doneOn := func(t *tasks.Task) time.Time {
+		if done, ok := t.Date(tasks.FIELD_DONE); ok {
+			return done
+		}
+		return dates[t.Path]
+	}
+	sort.SliceStable(r.Tasks, func(i, j int) bool {
+		return doneOn(&r.Tasks[i]).Before(doneOn(&r.Tasks[j]))
+	})
+	sort.SliceStable(r.Created, func(i, j int) bool {
+		return r.Created[i].Created.Before(r.Created[j].Created)
+	})
+	return r, nil
+}
+
+// Collects the contents of the headings with the names from the daily notes.
+func sections(v *vault.Vault, days []api.Set, dates map[string]time.Time, names []string) []Section {
+	all := make([]Section, 0, len(names))
+	for _, name := range names {
+		section := Section{Name: name, Entries: make([]Entry, 0)}
+		for _, s := range days {
+			p := v.RelPath(s)
+			data := *s.Data()
+			md := metadata.Parse(p, data)
+			for i, h := range md.Headings {
+				if !strings.EqualFold(h.Text, name) {
+					continue
+				}
+				m := md.Section(i, data)
+				if text := strings.TrimSpace(string(data[m.Begin:m.End])); !empty(text) {
+					section.Entries = append(section.Entries, Entry{Source: p, Date: dates[p], Text: text})
+				}
+			}
+		}
+		all = append(all, section)
+	}
+	return all
+}
+
+// Whether the text has nothing but empty list items, like the ones templates leave.
+func empty(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != "-" && line != "*" && line != "- [ ]" {
+			return false
+		}
+	}
+	return true
+}
+
+// Sums up the frontmatter values of the daily notes with the keys, or with numbers.
+func metrics(v *vault.Vault, days []api.Set, keys []string) []Metric {
+	byKey := make(map[string]*Metric)
+	order := append([]string{}, keys...)
+	for _, k := range keys {
+		byKey[k] = &Metric{Key: k}
+	}
+
+	for _, s := range days {
+		fm := metadata.ParseFrontmatter(*s.Data())
+		for _, p := range fm.Properties {
+			if p.Kind != metadata.PropertyScalar {
+				continue
+			}
+			n, err := strconv.ParseFloat(p.Value, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				continue
+			}
+			m, ok := byKey[p.Key]
+			if !ok {
+				if len(keys) > 0 {
+					continue
+				}
+				m = &Metric{Key: p.Key}
+				byKey[p.Key] = m
+				order = append(order, p.Key)
+			}
+			if m.Days == 0 || n < m.Min {
+				m.Min = n
+			}
+			if m.Days == 0 || n > m.Max {
+				m.Max = n
+			}
+			m.Sum += n
+			m.Days++
+		}
+	}
+
+	all := make([]Metric, 0, len(order))
+	for _, k := range order {
+		all = append(all, *byKey[k])
+	}
+	return all
+}
+
+// Gets when the note was created, from its frontmatter.
+func createdAt(s api.Set) (time.Time, bool) {
+	fm := metadata.ParseFrontmatter(*s.Data())
+	if p, ok := fm.Get(CREATED_KEY); ok && len(p.Value) >= len(tasks.DATE_LAYOUT) {
+		if t, err := time.ParseInLocation(tasks.DATE_LAYOUT, p.Value[:len(tasks.DATE_LAYOUT)], time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Renders the review as the markdown of the note at the path, with the title as its
+// heading. Everything links back to the notes it came from.
+func (r *Review) Render(notePath, title string, link LinkFunc) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	notes := "daily notes"
+	if len(r.Days) == 1 {
+		notes = "daily note"
+	}
+	fmt.Fprintf(&b, "%s to %s, %d %s.\n", r.From.Format(tasks.DATE_LAYOUT), r.To.Format(tasks.DATE_LAYOUT), len(r.Days), notes)
+
+	for _, s := range r.Sections {
+		fmt.Fprintf(&b, "\n## %s\n", s.Name)
+		if len(s.Entries) == 0 {
+			b.WriteString("\nNothing.\n")
+		}
+		for _, e := range s.Entries {
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", link(notePath, e.Source), e.Text)
+		}
+	}
+
+	b.WriteString("\n## Completed tasks\n\n")
+	if len(r.Tasks) == 0 {
+		b.WriteString("Nothing.\n")
+	}
+	for _, t := range r.Tasks {
+		fmt.Fprintf(&b, "- [x] %s (%s)\n", t.Text, link(notePath, t.Path))
+	}
+
+	b.WriteString("\n## Created notes\n\n")
+	if len(r.Created) == 0 {
+		b.WriteString("Nothing.\n")
+	}
+	for _, n := range r.Created {
+		fmt.Fprintf(&b, "- %s %s\n", n.Created.Format(tasks.DATE_LAYOUT), link(notePath, n.Path))
+	}
+
+	if len(r.Metrics) > 0 {
+		b.WriteString("\n## Metrics\n\n")
+		b.WriteString("| Metric | Days | Average | Min | Max | Total |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, m := range r.Metrics {
+			if m.Days == 0 {
+				fmt.Fprintf(&b, "| %s | 0 | - | - | - | - |\n", m.Key)
+				continue
+			}
+			fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s |\n", m.Key, m.Days,
+				number(m.Average()), number(m.Min), number(m.Max), number(m.Sum))
+		}
+	}
+
+	if len(r.Days) > 0 {
+		b.WriteString("\n## Sources\n\n")
+		for _, d := range r.Days {
+			fmt.Fprintf(&b, "- %s\n", link(notePath, d))
+		}
+	}
+	return b.String()
+}
+
+// Formats the number with at most two decimals.
+func number(n float64) string {
+	return strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,239 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/links"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// What a rule has done.
+type Stat struct {
+	Rule string
+
+	// The number of notes the rule has changed or moved.
+	Notes int
+
+	// The number of matches the action ran on.
+	Matches int
+}
+
+// A file which moves when the plan is committed.
+type Move struct {
+	From string
+	To   string
+}
+
+// The result of running the rules. The notes are only changed in memory until the
+// plan is committed, the changed ones and the moves are in its transaction.
+type Plan struct {
+	*vault.Transaction
+
+	Stats []Stat
+	Moves []Move
+
+	vault *vault.Vault
+}
+
+// The matches of a rule in a note, with the submatches of the regex if there is one.
+type matches struct {
+	all        []api.Match
+	submatches map[int][]int
+}
+
+// Runs the rules on the group in memory.
+func Run(v *vault.Vault, g api.Group, r *vault.Resolver, f *File) (*Plan, error) {
+	p := &Plan{
+		Transaction: v.Begin(),
+		Stats:       make([]Stat, 0, len(f.Rules)),
+		Moves:       make([]Move, 0),
+		vault:       v,
+	}
+
+	for i := range f.Rules {
+		rule := &f.Rules[i]
+		stat := Stat{Rule: rule.Name}
+
+		sets, err := rule.selector.Select(v, g)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range sets {
+			md, err := v.Metadata(s)
+			if err != nil {
+				return nil, err
+			}
+
+			mm := rule.find(*s.Data(), md)
+			if rule.hasMatcher() && len(mm.all) == 0 {
+				continue
+			}
+
+			ok, err := p.apply(rule, s, md, mm, g, r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", rule.Name, md.Path, err)
+			}
+			if ok {
+				stat.Notes += 1
+				stat.Matches += len(mm.all)
+			}
+		}
+		p.Stats = append(p.Stats, stat)
+	}
+	return p, nil
+}
+
+// Finds the matches of the rule in the note.
+func (r *Rule) find(data []byte, md *metadata.Metadata) matches {
+	mm := matches{all: make([]api.Match, 0), submatches: make(map[int][]int)}
+	fm := md.Frontmatter
+
+	// The regions the matches must be in.
+	regions := make([]api.Match, 0)
+	switch {
+	case r.Match.Heading != "":
+		for i, h := range md.Headings {
+			if !strings.EqualFold(strings.TrimSpace(h.Text), r.Match.Heading) {
+				continue
+			}
+			if r.regex == nil && r.Match.Scope != SCOPE_SECTION {
+				mm.all = append(mm.all, h.Match)
+			} else {
+				regions = append(regions, md.Section(i, data))
+			}
+		}
+		if r.regex == nil {
+			mm.all = append(mm.all, regions...)
+			return mm
+		}
+	case r.Match.Scope == SCOPE_FRONTMATTER:
+		if fm.Present {
+			regions = append(regions, fm.Body)
+		}
+	case r.Match.Scope == SCOPE_ALL:
+		regions = append(regions, api.Match{Begin: 0, End: len(data)})
+	default:
+		regions = append(regions, api.Match{Begin: fm.Match.End, End: len(data)})
+	}
+
+	if r.regex == nil {
+		return mm
+	}
+	for _, sm := range r.regex.FindAllSubmatchIndex(data, -1) {
+		for _, region := range regions {
+			if sm[0] >= region.Begin && sm[1] <= region.End {
+				mm.all = append(mm.all, api.Match{Begin: sm[0], End: sm[1]})
+				mm.submatches[sm[0]] = sm
+				break
+			}
+		}
+	}
+	return mm
+}
+
+// Expands the template for the match.
+func (r *Rule) expand(template string, mm matches, m api.Match, data []byte) []byte {
+	if sm, ok := mm.submatches[m.Begin]; ok {
+		return r.regex.Expand(nil, []byte(template), data, sm)
+	}
+	return []byte(template)
+}
+
+// Runs the action of the rule on the note. Returns true if the note has changed.
+func (p *Plan) apply(rule *Rule, s api.Set, md *metadata.Metadata, mm matches, g api.Group, r *vault.Resolver) (bool, error) {
+	a := rule.Action
+	version := s.Attributes().Version()
+	data := *s.Data()
+
+	template := func(text string) api.SetActionCallback {
+		return func(m api.Match, buffer api.Data) ([]byte, bool) {
+			return rule.expand(text, mm, m, *buffer), true
+		}
+	}
+
+	// Without a matcher, the texts are inserted into the beginning of the body or
+	// the end of the note.
+	all := mm.all
+	if !rule.hasMatcher() {
+		if a.InsertBefore != nil {
+			all = []api.Match{{Begin: md.Frontmatter.Match.End, End: md.Frontmatter.Match.End}}
+		} else {
+			all = []api.Match{{Begin: len(data), End: len(data)}}
+		}
+	}
+
+	var err error
+	switch {
+	case a.Replace != nil:
+		_, err = s.Replace(&all, template(*a.Replace))
+	case a.InsertBefore != nil:
+		_, err = s.InsertBefore(&all, template(*a.InsertBefore))
+	case a.InsertAfter != nil:
+		_, err = s.InsertAfter(&all, template(*a.InsertAfter))
+	case a.Remove:
+		_, err = s.Remove(&all)
+	case !a.SetFrontmatter.IsZero():
+		err = setFrontmatter(s, &a.SetFrontmatter)
+	case a.Move != "":
+		return p.move(s, md, a.Move, g, r)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if s.Attributes().Version() == version {
+		return false, nil
+	}
+	p.Write(s)
+	return true, nil
+}
+
+// Sets the properties of the map node in their order.
+func setFrontmatter(s api.Set, properties *yaml.Node) error {
+	for i := 0; i+1 < len(properties.Content); i += 2 {
+		if _, err := frontmatter.Set(s, properties.Content[i].Value, properties.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moves the note and rewrites the links to it.
+func (p *Plan) move(s api.Set, md *metadata.Metadata, dest string, g api.Group, r *vault.Resolver) (bool, error) {
+	if strings.HasSuffix(dest, "/") {
+		dest = path.Join(dest, path.Base(md.Path))
+	} else if path.Ext(dest) == "" {
+		dest += path.Ext(md.Path)
+	}
+	dest = strings.TrimPrefix(path.Clean("/"+dest), "/")
+
+	if dest == md.Path {
+		return false, nil
+	}
+	for _, m := range p.Moves {
+		if m.From == md.Path {
+			return false, fmt.Errorf("already moved to '%s'", m.To)
+		}
+	}
+	if r.Exists(dest) {
+		return false, fmt.Errorf("'%s' already exists", dest)
+	}
+
+	changed, _, err := links.Retarget(p.vault, g, r, md.Path, dest)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range changed {
+		p.Write(c)
+	}
+	p.Rename(md.Path, dest)
+	p.Moves = append(p.Moves, Move{From: md.Path, To: dest})
+	return true, nil
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// The parts of a note a matcher looks at.
+const (
+	// Everything after the frontmatter, the default.
+	SCOPE_BODY string = "body"
+
+	// Only the frontmatter.
+	SCOPE_FRONTMATTER string = "frontmatter"
+
+	// The whole note.
+	SCOPE_ALL string = "all"
+
+	// The section under the heading, only with a heading matcher.
+	SCOPE_SECTION string = "section"
+)
+
+// A rules file, the rules are run one after the other and each sees the changes of
+// the previous ones.
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// A single step, it selects the notes, finds the matches in them and runs the action
+// on the matches.
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// The query selecting the notes, see the selector package. Empty selects all.
+	Select string `yaml:"select"`
+
+	Match  Matcher `yaml:"match"`
+	Action Action  `yaml:"action"`
+
+	selector *selector.Selector
+	regex    *regexp.Regexp
+}
+
+// Finds the parts of a note the action works on. A regex or a literal matches text,
+// a heading alone matches the heading lines, or the sections under them with the
+// section scope. Given together, the text is only matched under the heading. With
+// no matcher at all the action works on the whole note.
+type Matcher struct {
+	Regex      string `yaml:"regex"`
+	Literal    string `yaml:"literal"`
+	Heading    string `yaml:"heading"`
+	Scope      string `yaml:"scope"`
+	IgnoreCase bool   `yaml:"ignore_case"`
+}
+
+// What to do with the matches, exactly one of the fields is set. The texts can refer
+// to the groups of the regex with $1 or ${name}.
+type Action struct {
+	// Replaces the matches.
+	Replace *string `yaml:"replace"`
+
+	// Inserts the text before or after the matches. Without a matcher the text goes
+	// to the beginning of the body or the end of the note.
+	InsertBefore *string `yaml:"insert_before"`
+	InsertAfter  *string `yaml:"insert_after"`
+
+	// Removes the matches.
+	Remove bool `yaml:"remove"`
+
+	// Sets the frontmatter properties of the notes with a match.
+	SetFrontmatter yaml.Node `yaml:"set_frontmatter"`
+
+	// Moves the notes with a match into the folder if it ends with a '/', or to the
+	// path otherwise. The links to them are updated.
+	Move string `yaml:"move"`
+}
+
+// Reads and checks the rules file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", path)
+	}
+
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, r.Name, err)
+		}
+	}
+	return f, nil
+}
+
+// Parses the selector and the regex, and checks the action.
+func (r *Rule) compile() error {
+	var err error
+	if r.selector, err = selector.Parse(r.Select); err != nil {
+		return err
+	}
+
+	m := r.Match
+	switch m.Scope {
+	case "":
+		r.Match.Scope = SCOPE_BODY
+	case SCOPE_BODY, SCOPE_FRONTMATTER, SCOPE_ALL:
+	case SCOPE_SECTION:
+		if m.Heading == "" {
+			return errors.New("the section scope needs a heading")
+		}
+	default:
+		return fmt.Errorf("unknown scope '%s'", m.Scope)
+	}
+
+	if m.Regex != "" && m.Literal != "" {
+		return errors.New("only one of regex and literal can be given")
+	}
+	pattern := m.Regex
+	if m.Literal != "" {
+		pattern = regexp.QuoteMeta(m.Literal)
+	}
+	if pattern != "" {
+		if m.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		if r.regex, err = regexp.Compile(pattern); err != nil {
+			return err
+		}
+	}
+
+	a := r.Action
+	actions := 0
+	for _, set := range []bool{a.Replace != nil, a.InsertBefore != nil, a.InsertAfter != nil, a.Remove, !a.SetFrontmatter.IsZero(), a.Move != ""} {
+		if set {
+			actions += 1
+		}
+	}
+	if actions != 1 {
+		return errors.New("a rule needs exactly one action")
+	}
+	if !a.SetFrontmatter.IsZero() && a.SetFrontmatter.Kind != yaml.MappingNode {
+		return errors.New("set_frontmatter needs a map of properties")
+	}
+	if (a.Replace != nil || a.Remove) && !r.hasMatcher() {
+		return errors.New("replace and remove need a matcher")
+	}
+	return nil
+}
+
+// Checks if the rule matches something, otherwise it works on the whole note.
+func (r *Rule) hasMatcher() bool {
+	return r.regex != nil || r.Match.Heading != ""
+}
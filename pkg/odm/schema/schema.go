@@ -0,0 +1,448 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/lint"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// The expected shape of the frontmatter of the notes, e.g.
+//
+//	schemas:
+//	  person:
+//	    paths: [People/**]
+//	    properties:
+//	      name: {type: string, required: true}
+//	      birthday: {type: date}
+//	      status: {type: string, enum: [active, archived], default: active}
+type Schema struct {
+	// The glob patterns of the notes the schema applies to.
+	Paths []string `yaml:"paths,omitempty"`
+
+	// The notes with any of these tags also get the schema.
+	Tags []string `yaml:"tags,omitempty"`
+
+	// The properties by their keys.
+	Properties map[string]*Property `yaml:"properties,omitempty"`
+
+	// Whether the keys not in the properties are reported.
+	Strict bool `yaml:"strict,omitempty"`
+}
+
+// The rules of a frontmatter property.
+type Property struct {
+	// One of the frontmatter.TYPE_* constants, any type if empty.
+	Type string `yaml:"type,omitempty"`
+
+	// Whether the property must be there and not be empty.
+	Required bool `yaml:"required,omitempty"`
+
+	// The allowed values, for the lists the allowed items.
+	Enum []string `yaml:"enum,omitempty"`
+
+	// The moment.js format of the dates, YYYY-MM-DD by default.
+	Format string `yaml:"format,omitempty"`
+
+	// The regular expression the values, or the items of the lists, must match.
+	Pattern string `yaml:"pattern,omitempty"`
+
+	// The value the fix fills in when the property is missing. The dates can also be
+	// 'today'.
+	Default yaml.Node `yaml:"default,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// A problem with the frontmatter of a note. The rule of the diagnostic is the name of
+// the schema.
+type Problem struct {
+	lint.Diagnostic
+
+	// The key of the property.
+	Key string
+
+	// The value which fixes the problem, nil if it can't be fixed.
+	Fix any
+}
+
+// The schemas by their names, ready to validate the notes.
+type Set struct {
+	names   []string
+	schemas map[string]*Schema
+
+	// The time of 'today' in the defaults.
+	Now time.Time
+}
+
+// Checks the schemas and prepares them. The errors name the schema and the property.
+func NewSet(schemas map[string]*Schema) (*Set, error) {
+	set := &Set{names: make([]string, 0, len(schemas)), schemas: schemas, Now: time.Now()}
+	for name, s := range schemas {
+		if s == nil {
+			return nil, fmt.Errorf("schema '%s' is empty", name)
+		}
+		if len(s.Paths) == 0 && len(s.Tags) == 0 {
+			return nil, fmt.Errorf("schema '%s' has neither paths nor tags", name)
+		}
+		for key, p := range s.Properties {
+			if p == nil {
+				s.Properties[key] = &Property{}
+				continue
+			}
+			if err := p.compile(); err != nil {
+				return nil, fmt.Errorf("schema '%s': property '%s': %w", name, key, err)
+			}
+		}
+		set.names = append(set.names, name)
+	}
+	sort.Strings(set.names)
+	return set, nil
+}
+
+func (p *Property) compile() error {
+	if p.Type != "" && !contains(frontmatter.Types, p.Type) {
+		return fmt.Errorf("unknown type '%s', use one of %s", p.Type, strings.Join(frontmatter.Types, ", "))
+	}
+	if p.Format == "" {
+		p.Format = frontmatter.DEFAULT_DATE_FORMAT
+	}
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a regular expression", p.Pattern)
+		}
+		p.pattern = re
+	}
+	return nil
+}
+
+// Gets the names of the schemas applying to the note, sorted.
+func (set *Set) For(md *metadata.Metadata) []string {
+	names := make([]string, 0)
+	for _, name := range set.names {
+		if set.schemas[name].applies(md) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (s *Schema) applies(md *metadata.Metadata) bool {
+	for _, pattern := range s.Paths {
+		if vault.MatchGlob(pattern, md.Path) {
+			return true
+		}
+	}
+	for _, tag := range s.Tags {
+		if md.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates the frontmatter of every note of the group. The problems are sorted by
+// their paths, then by their lines.
+func (set *Set) Validate(v *vault.Vault, g api.Group) ([]Problem, error) {
+	problems := make([]Problem, 0)
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, set.Check(*s.Data(), md)...)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// Validates the frontmatter of the note against the schemas applying to it. The
+// problems are sorted by their lines.
+func (set *Set) Check(data []byte, md *metadata.Metadata) []Problem {
+	problems := make([]Problem, 0)
+	lines := metadata.NewLineIndex(data)
+	for _, name := range set.For(md) {
+		s := set.schemas[name]
+		for _, key := range sortedKeys(s.Properties) {
+			for _, p := range set.checkProperty(data, md, s.Properties[key], key) {
+				p.Rule, p.Path, p.Key = name, md.Path, key
+				p.Line, p.Col = lines.Position(p.Match.Begin)
+				problems = append(problems, p)
+			}
+		}
+		if s.Strict {
+			for _, prop := range md.Frontmatter.Properties {
+				if _, ok := s.Properties[prop.Key]; !ok {
+					p := problem(prop.Match, lint.SEVERITY_WARNING, nil, "'%s' is not in the schema", prop.Key)
+					p.Rule, p.Path, p.Key = name, md.Path, prop.Key
+					p.Line, p.Col = lines.Position(p.Match.Begin)
+					problems = append(problems, p)
+				}
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return problems
+}
+
+func (set *Set) checkProperty(data []byte, md *metadata.Metadata, rule *Property, key string) []Problem {
+	prop, ok := md.Frontmatter.Get(key)
+	if !ok || prop.Kind == metadata.PropertyEmpty || prop.Kind == metadata.PropertyList && len(prop.Items) == 0 {
+		def := set.defaultValue(rule)
+		switch {
+		case rule.Required && !ok:
+			return []Problem{problem(md.Frontmatter.Match, lint.SEVERITY_ERROR, def, "'%s' is missing", key)}
+		case rule.Required:
+			return []Problem{problem(prop.Match, lint.SEVERITY_ERROR, def, "'%s' is empty", key)}
+		case def != nil && !ok:
+			return []Problem{problem(md.Frontmatter.Match, lint.SEVERITY_WARNING, def, "'%s' is missing, the default is '%s'", key, text(def))}
+		}
+		return nil
+	}
+	at := prop.ValueMatch
+	if prop.Kind == metadata.PropertyNested {
+		return []Problem{problem(at, lint.SEVERITY_ERROR, nil, "'%s' is not a %s", key, or(rule.Type, "value"))}
+	}
+
+	// The type first, the other checks are about the values of the right type.
+	raw := string(data[prop.ValueMatch.Begin:prop.ValueMatch.End])
+	values, fix, err := rule.convert(prop, raw)
+	if err != nil {
+		return []Problem{problem(at, lint.SEVERITY_ERROR, fix, "'%s' %s", key, err.Error())}
+	}
+
+	problems := make([]Problem, 0)
+	if len(rule.Enum) > 0 {
+		fixed, bad := make([]string, 0, len(values)), ""
+		for _, v := range values {
+			if contains(rule.Enum, v) {
+				fixed = append(fixed, v)
+			} else if e, ok := foldContains(rule.Enum, v); ok {
+				fixed = append(fixed, e)
+				bad = or(bad, v)
+			} else {
+				fixed, bad = nil, v
+				break
+			}
+		}
+		if bad != "" {
+			var fix any
+			if fixed != nil {
+				fix = rule.value(prop, fixed)
+			}
+			problems = append(problems, problem(at, lint.SEVERITY_ERROR, fix, "'%s' is '%s', not one of %s", key, bad, strings.Join(rule.Enum, ", ")))
+		}
+	}
+	if rule.pattern != nil {
+		for _, v := range values {
+			if !rule.pattern.MatchString(v) {
+				problems = append(problems, problem(at, lint.SEVERITY_ERROR, nil, "'%s' is '%s', which doesn't match '%s'", key, v, rule.Pattern))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// Checks the type of the property and gets its values as strings, the items for the
+// lists. If the type is wrong, the fix is the value converted to the type if it can be.
+func (rule *Property) convert(prop metadata.Property, raw string) ([]string, any, error) {
+	quoted := strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, `'`)
+	value := prop.Value
+	isList := prop.Kind == metadata.PropertyList
+	// An unquoted '[[link]]' reads as a list in a list, it is a link for the schemas.
+	if isList && prop.Inline && frontmatter.IsLink(prop.Value) {
+		isList = false
+	}
+
+	if rule.Type == frontmatter.TYPE_LIST {
+		if isList {
+			return prop.Items, nil, nil
+		}
+		items := prop.List()
+		return nil, items, fmt.Errorf("is not a list")
+	}
+	if isList {
+		if len(prop.Items) == 1 && rule.Type != "" {
+			// A list of one is fixed to the one value, if that is of the right type.
+			if _, fix, err := rule.convert(metadata.Property{Kind: metadata.PropertyScalar, Value: prop.Items[0]}, prop.Items[0]); err == nil || fix != nil {
+				if fix == nil {
+					fix = rule.value(prop, prop.Items)
+				}
+				return nil, fix, fmt.Errorf("is a list, not a %s", rule.Type)
+			}
+		}
+		if rule.Type == "" {
+			return prop.Items, nil, nil
+		}
+		return nil, nil, fmt.Errorf("is a list, not a %s", rule.Type)
+	}
+
+	switch rule.Type {
+	case frontmatter.TYPE_NUMBER, frontmatter.TYPE_INTEGER:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("is '%s', not a number", value)
+		}
+		if rule.Type == frontmatter.TYPE_INTEGER && n != float64(int64(n)) {
+			return nil, nil, fmt.Errorf("is '%s', not an integer", value)
+		}
+		if quoted {
+			return nil, number(n), fmt.Errorf("is a string, not a number")
+		}
+	case frontmatter.TYPE_BOOLEAN:
+		switch strings.ToLower(value) {
+		case "true", "false":
+			if quoted || value != strings.ToLower(value) {
+				return nil, strings.ToLower(value) == "true", fmt.Errorf("is '%s', not a boolean", value)
+			}
+		case "yes", "on", "y":
+			return nil, true, fmt.Errorf("is '%s', not a boolean", value)
+		case "no", "off", "n":
+			return nil, false, fmt.Errorf("is '%s', not a boolean", value)
+		default:
+			return nil, nil, fmt.Errorf("is '%s', not a boolean", value)
+		}
+	case frontmatter.TYPE_DATE:
+		if _, err := moment.Parse(rule.Format, value, time.Local); err != nil {
+			if t, ok := frontmatter.ParseDate(value, rule.Format); ok {
+				return nil, frontmatter.Plain(moment.Format(t, rule.Format)), fmt.Errorf("is '%s', not a date like %s", value, rule.Format)
+			}
+			return nil, nil, fmt.Errorf("is '%s', not a date like %s", value, rule.Format)
+		}
+	case frontmatter.TYPE_LINK:
+		if !frontmatter.IsLink(value) {
+			return nil, "[[" + value + "]]", fmt.Errorf("is '%s', not a link", value)
+		}
+	}
+	return []string{value}, nil, nil
+}
+
+// Gets the value to write for the values, a list for the lists.
+func (rule *Property) value(prop metadata.Property, values []string) any {
+	if rule.Type == frontmatter.TYPE_LIST || prop.Kind == metadata.PropertyList && len(values) != 1 {
+		return values
+	}
+	return frontmatter.Plain(values[0])
+}
+
+// Gets the default value of the property, nil if there is none.
+func (set *Set) defaultValue(rule *Property) any {
+	if rule.Default.Kind == 0 {
+		return nil
+	}
+	if rule.Type == frontmatter.TYPE_DATE && rule.Default.Kind == yaml.ScalarNode {
+		switch strings.ToLower(rule.Default.Value) {
+		case "today", "now":
+			return frontmatter.Plain(moment.Format(set.Now, rule.Format))
+		}
+		// The dates of the configuration may have gone through a time.Time.
+		if t, ok := frontmatter.ParseDate(rule.Default.Value, rule.Format); ok {
+			return frontmatter.Plain(moment.Format(t, rule.Format))
+		}
+	}
+	return &rule.Default
+}
+
+// Fixes the fixable problems of the note through the frontmatter writer. The problems
+// must be found in the current version of the note. Returns how many are fixed.
+func Fix(s api.Set, problems []Problem) (int, error) {
+	fixed := 0
+	done := make(map[string]bool)
+	for _, p := range problems {
+		if p.Fix == nil || done[p.Key] {
+			continue
+		}
+		done[p.Key] = true
+		if ok, err := frontmatter.Set(s, p.Key, p.Fix); err != nil {
+			return fixed, err
+		} else if ok {
+			fixed++
+		}
+	}
+	return fixed, nil
+}
+
+// Creates a problem at the match, the path and the position are set by the caller.
+func problem(m api.Match, severity int, fix any, format string, obj ...any) Problem {
+	return Problem{
+		Diagnostic: lint.Diagnostic{Severity: severity, Message: fmt.Sprintf(format, obj...), Match: m},
+		Fix:        fix,
+	}
+}
+
+// Gets the number as an int if it is one, so it is written without a fraction.
+func number(n float64) any {
+	if n == float64(int64(n)) {
+		return int64(n)
+	}
+	return n
+}
+
+// Gets the text of the fix value for the messages.
+func text(value any) string {
+	if n, ok := value.(*yaml.Node); ok {
+		if n.Kind == yaml.ScalarNode {
+			return n.Value
+		}
+		var out any
+		if err := n.Decode(&out); err == nil {
+			return fmt.Sprint(out)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the value in any case, returns it as it is in the values.
+func foldContains(values []string, v string) (string, bool) {
+	for _, x := range values {
+		if strings.EqualFold(x, v) {
+			return x, true
+		}
+	}
+	return "", false
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func sortedKeys(m map[string]*Property) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,326 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/cache"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The name of the index file under the '.odm' folder.
+const INDEX_FILE_NAME = "search.gob"
+
+// Bump this when the layout or the tokenizer changes, old indexes are then rebuilt.
+const INDEX_VERSION = 1
+
+// The fields of a note which can be searched separately.
+const (
+	FIELD_TITLE   int = 0
+	FIELD_HEADING int = 1
+	FIELD_BODY    int = 2
+	FIELD_TAG     int = 3
+	NUM_FIELDS    int = 4
+)
+
+// The names of the fields used in the queries, e.g. 'title:word'.
+var FieldNames = [NUM_FIELDS]string{"title", "heading", "body", "tag"}
+
+// How much a hit in each field counts.
+var fieldWeights = [NUM_FIELDS]float64{3.0, 2.0, 1.0, 2.0}
+
+// Positions of the separate headings are this far apart, so the phrases don't span
+// over two headings.
+const headingGap = 16
+
+// The occurrences of a term in a single field of a document.
+type Posting struct {
+	Doc       int
+	Positions []int
+	Offsets   []api.Match
+}
+
+// The postings of a single term for each field.
+type TermPostings struct {
+	Fields [NUM_FIELDS][]Posting
+}
+
+type Document struct {
+	Path    string
+	Hash    cache.Hash
+	Lengths [NUM_FIELDS]int
+
+	// The terms of the document, used when removing it.
+	Terms []string
+}
+
+// The inverted index over the notes of a vault. It is not safe for concurrent use.
+type Index struct {
+	file *file.File
+
+	documents map[int]*Document
+	paths     map[string]int
+	terms     map[string]*TermPostings
+	lengths   [NUM_FIELDS]int
+	next      int
+	dirty     bool
+}
+
+// This is what is written down to the disk.
+type snapshot struct {
+	Version   int
+	Documents map[int]*Document
+	Terms     map[string]*TermPostings
+	Lengths   [NUM_FIELDS]int
+	Next      int
+}
+
+func NewEmptyIndex(f *file.File) *Index {
+	return &Index{
+		file:      f,
+		documents: make(map[int]*Document),
+		paths:     make(map[string]int),
+		terms:     make(map[string]*TermPostings),
+	}
+}
+
+// Loads the index from the given file. If the file doesn't exist or is from an older
+// version, an empty index is returned.
+func Load(f *file.File) (*Index, error) {
+	idx := NewEmptyIndex(f)
+
+	data, err := f.ReadAll()
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := snapshot{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil || s.Version != INDEX_VERSION {
+		idx.dirty = true
+		return idx, nil
+	}
+
+	if s.Documents != nil {
+		idx.documents = s.Documents
+	}
+	if s.Terms != nil {
+		idx.terms = s.Terms
+	}
+	idx.lengths = s.Lengths
+	idx.next = s.Next
+	for id, d := range idx.documents {
+		idx.paths[d.Path] = id
+	}
+	return idx, nil
+}
+
+// Loads the index of the vault from its '.odm' folder.
+func LoadVaultIndex(v *vault.Vault) (*Index, error) {
+	if f, err := v.Root().File(vault.ODM_FOLDER + "/" + INDEX_FILE_NAME); err == nil {
+		return Load(f)
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the number of documents.
+func (idx *Index) Len() int {
+	return len(idx.documents)
+}
+
+// Brings the index up to date with the group. Only the notes whose contents changed are
+// indexed again and the ones not in the group are removed. Returns how many documents
+// are added, updated or removed.
+func (idx *Index) Sync(v *vault.Vault, g api.Group) (int, error) {
+	changed := 0
+	seen := make(map[string]bool)
+
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return changed, err
+		}
+		seen[md.Path] = true
+		if idx.Update(md, *s.Data()) {
+			changed += 1
+		}
+	}
+
+	for path := range idx.paths {
+		if !seen[path] && idx.Remove(path) {
+			changed += 1
+		}
+	}
+
+	return changed, nil
+}
+
+// Indexes the note, replacing the previous version of it. Returns false if the
+// contents didn't change since the last time.
+func (idx *Index) Update(md *metadata.Metadata, data []byte) bool {
+	hash := cache.HashOf(data)
+	if id, ok := idx.paths[md.Path]; ok {
+		if idx.documents[id].Hash == hash {
+			return false
+		}
+		idx.Remove(md.Path)
+	}
+
+	id := idx.next
+	idx.next += 1
+	doc := &Document{Path: md.Path, Hash: hash, Terms: make([]string, 0)}
+
+	var fields [NUM_FIELDS][]Token
+	fields[FIELD_TITLE] = Tokenize([]byte(md.Title), 0)
+	for i, h := range md.Headings {
+		for _, t := range Tokenize(data[h.Match.Begin:h.Match.End], h.Match.Begin) {
+			t.Position += i * headingGap
+			fields[FIELD_HEADING] = append(fields[FIELD_HEADING], t)
+		}
+	}
+	fields[FIELD_BODY] = Tokenize(data[md.Frontmatter.Match.End:], md.Frontmatter.Match.End)
+	fields[FIELD_TAG] = tagTokens(md)
+
+	for field, tokens := range fields {
+		doc.Lengths[field] = len(tokens)
+		idx.lengths[field] += len(tokens)
+
+		postings := make(map[string]*Posting)
+		order := make([]string, 0)
+		for _, t := range tokens {
+			p, ok := postings[t.Term]
+			if !ok {
+				p = &Posting{Doc: id}
+				postings[t.Term] = p
+				order = append(order, t.Term)
+			}
+			p.Positions = append(p.Positions, t.Position)
+			p.Offsets = append(p.Offsets, t.Match)
+		}
+
+		for _, term := range order {
+			tp, ok := idx.terms[term]
+			if !ok {
+				tp = &TermPostings{}
+				idx.terms[term] = tp
+			}
+			tp.Fields[field] = append(tp.Fields[field], *postings[term])
+			doc.Terms = append(doc.Terms, term)
+		}
+	}
+
+	idx.documents[id] = doc
+	idx.paths[md.Path] = id
+	idx.dirty = true
+	return true
+}
+
+// Tags are indexed as a whole, the nested ones also under each of their parents. The
+// frontmatter tags don't have an offset.
+func tagTokens(md *metadata.Metadata) []Token {
+	tokens := make([]Token, 0)
+	offsets := make(map[string]api.Match)
+	for _, t := range md.Tags {
+		if _, ok := offsets[t.Name]; !ok {
+			offsets[t.Name] = t.Match
+		}
+	}
+
+	for i, name := range md.AllTags() {
+		parts := strings.Split(strings.ToLower(name), "/")
+		for j := range parts {
+			tokens = append(tokens, Token{
+				Term:     strings.Join(parts[:j+1], "/"),
+				Position: i * headingGap,
+				Match:    offsets[name],
+			})
+		}
+	}
+	return tokens
+}
+
+// Removes the document from the index. Returns false if it is not indexed.
+func (idx *Index) Remove(path string) bool {
+	id, ok := idx.paths[path]
+	if !ok {
+		return false
+	}
+	doc := idx.documents[id]
+
+	for _, term := range doc.Terms {
+		tp, ok := idx.terms[term]
+		if !ok {
+			continue
+		}
+
+		empty := true
+		for field := range tp.Fields {
+			postings := tp.Fields[field][:0]
+			for _, p := range tp.Fields[field] {
+				if p.Doc != id {
+					postings = append(postings, p)
+				}
+			}
+			tp.Fields[field] = postings
+			if len(postings) > 0 {
+				empty = false
+			}
+		}
+		if empty {
+			delete(idx.terms, term)
+		}
+	}
+
+	for field := range idx.lengths {
+		idx.lengths[field] -= doc.Lengths[field]
+	}
+
+	delete(idx.documents, id)
+	delete(idx.paths, path)
+	idx.dirty = true
+	return true
+}
+
+// Writes the index down to the disk if it is changed.
+func (idx *Index) Save() error {
+	if !idx.dirty {
+		return nil
+	}
+
+	if parent, err := idx.file.Parent(); err != nil {
+		return err
+	} else if _, err := parent.Create(); err != nil {
+		return err
+	}
+
+	buffer := new(bytes.Buffer)
+	err := gob.NewEncoder(buffer).Encode(snapshot{
+		Version:   INDEX_VERSION,
+		Documents: idx.documents,
+		Terms:     idx.terms,
+		Lengths:   idx.lengths,
+		Next:      idx.next,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp := idx.file.String() + ".tmp"
+	if err := os.WriteFile(tmp, buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, idx.file.String()); err != nil {
+		return err
+	}
+
+	idx.dirty = false
+	return nil
+}
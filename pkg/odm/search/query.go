@@ -0,0 +1,371 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// The BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// A single term of the query.
+type queryTerm struct {
+	term     string
+	position int
+	prefix   bool
+}
+
+// A clause of the query, e.g. 'word', '"a phrase"', 'title:word*' or '-word'. Every
+// clause must match unless it is negated, then it must not.
+type clause struct {
+	// The field to search in, -1 for every field.
+	field  int
+	negate bool
+	terms  []queryTerm
+}
+
+// A parsed query.
+type Query struct {
+	clauses []clause
+}
+
+type Result struct {
+	// The path of the note relative to the vault.
+	Path  string
+	Score float64
+
+	// The matched words in the contents of the note, sorted. Title hits don't have a
+	// range since the title is not in the contents.
+	Matches []api.Match
+}
+
+// Parses the query. The syntax is a list of clauses separated by spaces:
+//
+//	word          the word in any field, stemmed
+//	"some words"  the words next to each other
+//	prefix*       any word starting with the prefix
+//	field:clause  only in the field, one of title, heading, body or tag
+//	-clause       notes which don't match
+func ParseQuery(q string) (*Query, error) {
+	query := &Query{clauses: make([]clause, 0)}
+
+	for i := 0; i < len(q); {
+		if q[i] == ' ' || q[i] == '\t' {
+			i++
+			continue
+		}
+
+		c := clause{field: -1}
+		if q[i] == '-' {
+			c.negate = true
+			i++
+		}
+
+		// An optional field prefix.
+		if j := strings.IndexAny(q[i:], ": \t\""); j > 0 && q[i+j] == ':' {
+			name := strings.ToLower(q[i : i+j])
+			c.field = -1
+			for f, n := range FieldNames {
+				if n == name {
+					c.field = f
+				}
+			}
+			if c.field < 0 {
+				return nil, fmt.Errorf("unknown field '%s'", name)
+			}
+			i += j + 1
+		}
+
+		var text string
+		phrase := false
+		if i < len(q) && q[i] == '"' {
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated phrase in the query")
+			}
+			text = q[i+1 : i+1+end]
+			phrase = true
+			i += end + 2
+		} else {
+			end := strings.IndexAny(q[i:], " \t")
+			if end < 0 {
+				end = len(q) - i
+			}
+			text = q[i : i+end]
+			i += end
+		}
+
+		if c.field == FIELD_TAG {
+			// Tags are kept as they are.
+			c.terms = []queryTerm{{term: strings.ToLower(strings.TrimPrefix(text, "#"))}}
+			if strings.HasSuffix(text, "*") {
+				c.terms[0].term = strings.TrimSuffix(c.terms[0].term, "*")
+				c.terms[0].prefix = true
+			}
+		} else {
+			prefix := !phrase && strings.HasSuffix(text, "*")
+			for _, t := range Tokenize([]byte(strings.TrimSuffix(text, "*")), 0) {
+				c.terms = append(c.terms, queryTerm{term: t.Term, position: t.Position})
+			}
+			if prefix && len(c.terms) > 0 {
+				// Prefixes are matched before stemming, since the stem of a prefix is
+				// often not a prefix of the stem.
+				last := &c.terms[len(c.terms)-1]
+				last.term = strings.ToLower(strings.TrimSuffix(text, "*"))
+				last.prefix = true
+			}
+		}
+
+		// A clause made of stop words only matches everything, drop it.
+		if len(c.terms) > 0 {
+			query.clauses = append(query.clauses, c)
+		}
+	}
+
+	if len(query.clauses) == 0 {
+		return nil, errors.New("query has no searchable words")
+	}
+	return query, nil
+}
+
+// A hit of a clause in a document.
+type hit struct {
+	score   float64
+	matches []api.Match
+}
+
+// Searches the index and returns the results with the best score first. If the limit
+// is not positive, every result is returned.
+func (idx *Index) Search(q *Query, limit int) []Result {
+	var candidates map[int]*hit
+
+	for _, c := range q.clauses {
+		if c.negate {
+			continue
+		}
+		hits := idx.evaluate(c)
+		if candidates == nil {
+			candidates = hits
+			continue
+		}
+		for doc, h := range candidates {
+			if other, ok := hits[doc]; ok {
+				h.score += other.score
+				h.matches = append(h.matches, other.matches...)
+			} else {
+				delete(candidates, doc)
+			}
+		}
+	}
+
+	if candidates == nil {
+		// Only negated clauses, start with every document.
+		candidates = make(map[int]*hit)
+		for doc := range idx.documents {
+			candidates[doc] = &hit{}
+		}
+	}
+
+	for _, c := range q.clauses {
+		if !c.negate {
+			continue
+		}
+		for doc := range idx.evaluate(c) {
+			delete(candidates, doc)
+		}
+	}
+
+	results := make([]Result, 0, len(candidates))
+	for doc, h := range candidates {
+		sort.Slice(h.matches, func(i, j int) bool {
+			return h.matches[i].Begin < h.matches[j].Begin
+		})
+		results = append(results, Result{
+			Path:    idx.documents[doc].Path,
+			Score:   h.score,
+			Matches: dedupe(h.matches),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Finds the documents matching the clause with their scores.
+func (idx *Index) evaluate(c clause) map[int]*hit {
+	hits := make(map[int]*hit)
+
+	for field := 0; field < NUM_FIELDS; field++ {
+		if c.field >= 0 && c.field != field {
+			continue
+		}
+		// Words are not searched in the tags unless asked for.
+		if c.field < 0 && field == FIELD_TAG {
+			continue
+		}
+
+		if len(c.terms) == 1 {
+			for _, term := range idx.expand(c.terms[0]) {
+				idf := idx.idf(term)
+				for _, p := range idx.terms[term].Fields[field] {
+					idx.addHit(hits, p.Doc, field, idf, len(p.Positions), p.Offsets)
+				}
+			}
+			continue
+		}
+
+		// Phrases, every term must be at the same distance as in the query.
+		idf := 0.0
+		for _, t := range c.terms {
+			for _, term := range idx.expand(t) {
+				idf += idx.idf(term)
+			}
+		}
+		for doc, offsets := range idx.phrase(c.terms, field) {
+			idx.addHit(hits, doc, field, idf, len(offsets)/len(c.terms), offsets)
+		}
+	}
+
+	return hits
+}
+
+func (idx *Index) addHit(hits map[int]*hit, doc, field int, idf float64, tf int, offsets []api.Match) {
+	h, ok := hits[doc]
+	if !ok {
+		h = &hit{}
+		hits[doc] = h
+	}
+	h.score += idx.bm25(doc, field, idf, tf)
+	if field != FIELD_TITLE {
+		for _, o := range offsets {
+			if o.End > o.Begin {
+				h.matches = append(h.matches, o)
+			}
+		}
+	}
+}
+
+// Gets the terms of the index the query term stands for.
+func (idx *Index) expand(t queryTerm) []string {
+	if !t.prefix {
+		if _, ok := idx.terms[t.term]; ok {
+			return []string{t.term}
+		}
+		return []string{}
+	}
+
+	stem := Stem(t.term)
+	terms := make([]string, 0)
+	for term := range idx.terms {
+		if strings.HasPrefix(term, t.term) || strings.HasPrefix(term, stem) {
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// Finds the documents with the phrase in the field, returns the offsets of the words.
+func (idx *Index) phrase(terms []queryTerm, field int) map[int][]api.Match {
+	// The positions of each term in each document.
+	type occurrence struct {
+		position int
+		offset   api.Match
+	}
+	perTerm := make([]map[int]map[int]api.Match, len(terms))
+
+	for i, t := range terms {
+		perTerm[i] = make(map[int]map[int]api.Match)
+		for _, term := range idx.expand(t) {
+			for _, p := range idx.terms[term].Fields[field] {
+				positions, ok := perTerm[i][p.Doc]
+				if !ok {
+					positions = make(map[int]api.Match)
+					perTerm[i][p.Doc] = positions
+				}
+				for k, pos := range p.Positions {
+					positions[pos] = p.Offsets[k]
+				}
+			}
+		}
+	}
+
+	found := make(map[int][]api.Match)
+	for doc, starts := range perTerm[0] {
+		for start, offset := range starts {
+			words := []occurrence{{position: start, offset: offset}}
+			for i := 1; i < len(terms); i++ {
+				want := start + terms[i].position - terms[0].position
+				if o, ok := perTerm[i][doc][want]; ok {
+					words = append(words, occurrence{position: want, offset: o})
+				} else {
+					words = nil
+					break
+				}
+			}
+			for _, w := range words {
+				found[doc] = append(found[doc], w.offset)
+			}
+		}
+	}
+	return found
+}
+
+// Gets the inverse document frequency of the term.
+func (idx *Index) idf(term string) float64 {
+	n := float64(len(idx.documents))
+	df := 0
+	seen := make(map[int]bool)
+	for _, postings := range idx.terms[term].Fields {
+		for _, p := range postings {
+			if !seen[p.Doc] {
+				seen[p.Doc] = true
+				df += 1
+			}
+		}
+	}
+
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// Scores a term in the field of the document with BM25.
+func (idx *Index) bm25(doc, field int, idf float64, tf int) float64 {
+	n := float64(len(idx.documents))
+	avg := float64(idx.lengths[field]) / math.Max(n, 1)
+	length := float64(idx.documents[doc].Lengths[field])
+
+	norm := 1.0
+	if avg > 0 {
+		norm = 1 - bm25B + bm25B*length/avg
+	}
+	f := float64(tf)
+	return fieldWeights[field] * idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+}
+
+func dedupe(mm []api.Match) []api.Match {
+	out := make([]api.Match, 0, len(mm))
+	for _, m := range mm {
+		if n := len(out); n > 0 && out[n-1] == m {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
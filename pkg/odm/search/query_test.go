@@ -0,0 +1,52 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+func TestSearchPhrase(t *testing.T) {
+	notes := map[string]string{
+		"A.md": "The state art here.\n",
+		"B.md": "The state of the art here.\n",
+		"C.md": "The state of art here.\n",
+		"D.md": "# Art\n\nThe state is here.\n",
+	}
+
+	idx := NewEmptyIndex(nil)
+	for name, text := range notes {
+		idx.Update(metadata.Parse(name, []byte(text)), []byte(text))
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`"state art"`, "A.md"},
+		{`"state of art"`, "C.md"},
+		{`"state of the art"`, "B.md"},
+		{`"state in art"`, "C.md"},
+		{`"the state"`, "A.md B.md C.md D.md"},
+		{`state art`, "A.md B.md C.md D.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths := make([]string, 0)
+			for _, r := range idx.Search(q, 0) {
+				paths = append(paths, r.Path)
+			}
+			sort.Strings(paths)
+			if got := strings.Join(paths, " "); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
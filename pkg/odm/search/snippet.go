@@ -0,0 +1,111 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// A short piece of the note around the matched words.
+type Snippet struct {
+	Text string
+
+	// The matched words in the text.
+	Highlights []api.Match
+}
+
+// Cuts a snippet of about the given width in characters around the window with the
+// most matches. The snippet is taken from the body, the matches in the frontmatter are
+// left out. New lines are replaced with spaces so the snippet fits on a line.
+func MakeSnippet(data []byte, matches []api.Match, width int) Snippet {
+	start := metadata.ParseFrontmatter(data).Match.End
+	body := make([]api.Match, 0, len(matches))
+	for _, m := range matches {
+		if m.Begin >= start {
+			body = append(body, m)
+		}
+	}
+	matches = body
+	if len(matches) == 0 || width <= 0 {
+		return Snippet{Highlights: []api.Match{}}
+	}
+
+	// Pick the match which has the most matches after it within the width.
+	best, bestCount := 0, 0
+	for i := range matches {
+		count := 0
+		for j := i; j < len(matches) && utf8.RuneCount(data[matches[i].Begin:matches[j].End]) <= width; j++ {
+			count++
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+
+	begin := matches[best].Begin
+	for n := 0; n < width/4 && begin > start; n++ {
+		_, size := utf8.DecodeLastRune(data[start:begin])
+		begin -= size
+	}
+	end := begin
+	for n := 0; n < width && end < len(data); n++ {
+		_, size := utf8.DecodeRune(data[end:])
+		end += size
+	}
+	begin, end = align(data, start, begin, end)
+
+	highlights := make([]api.Match, 0)
+	for _, m := range matches {
+		if m.Begin >= begin && m.End <= end {
+			highlights = append(highlights, api.Match{Begin: m.Begin - begin, End: m.End - begin})
+		}
+	}
+
+	text := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, string(data[begin:end]))
+
+	return Snippet{Text: text, Highlights: highlights}
+}
+
+// Moves the range so it doesn't split runes, and tries not to split the words. The
+// words aren't cut at the start of the body.
+func align(data []byte, start, begin, end int) (int, int) {
+	for begin > start && !utf8.RuneStart(data[begin]) {
+		begin--
+	}
+	for end < len(data) && !utf8.RuneStart(data[end]) {
+		end++
+	}
+
+	if i := strings.IndexAny(string(data[begin:end]), " \n"); begin > start && i >= 0 && i < 16 {
+		begin += i + 1
+	}
+	if i := strings.LastIndexAny(string(data[begin:end]), " \n"); end < len(data) && i >= 0 && end-begin-i < 16 {
+		end = begin + i
+	}
+	return begin, end
+}
+
+// Wraps the highlights with the given markers, e.g. ANSI escape codes or '**'.
+func (s Snippet) Highlight(open, close string) string {
+	b := new(strings.Builder)
+	pointer := 0
+	for _, h := range s.Highlights {
+		if h.Begin < pointer {
+			continue
+		}
+		b.WriteString(s.Text[pointer:h.Begin])
+		b.WriteString(open)
+		b.WriteString(s.Text[h.Begin:h.End])
+		b.WriteString(close)
+		pointer = h.End
+	}
+	b.WriteString(s.Text[pointer:])
+	return b.String()
+}
@@ -0,0 +1,243 @@
+package search
+
+// This is the Porter stemming algorithm for English, see
+// https://tartarus.org/martin/PorterStemmer/def.txt. Only lower case ascii words are
+// stemmed, anything else is returned as is.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word)}
+	s.step1ab()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+type stemmer struct {
+	b []byte
+
+	// The end of the stem when checking the suffixes.
+	j int
+}
+
+// Checks if the ith letter is a consonant.
+func (s *stemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// Measures the number of consonant sequences between 0 and j.
+func (s *stemmer) m() int {
+	n := 0
+	i := 0
+	for {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// Checks if 0..j contains a vowel.
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if j-1, j is a double consonant.
+func (s *stemmer) doublec(j int) bool {
+	return j >= 1 && s.b[j] == s.b[j-1] && s.cons(j)
+}
+
+// Checks if i-2, i-1, i is consonant-vowel-consonant and the last one is not w, x or y.
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// Checks if the word ends with the suffix and sets j to the end of the stem.
+func (s *stemmer) ends(suffix string) bool {
+	k := len(s.b) - 1
+	if len(suffix) > k+1 || string(s.b[len(s.b)-len(suffix):]) != suffix {
+		return false
+	}
+	s.j = k - len(suffix)
+	return true
+}
+
+// Replaces the suffix after j.
+func (s *stemmer) setto(suffix string) {
+	s.b = append(s.b[:s.j+1], suffix...)
+}
+
+func (s *stemmer) r(suffix string) {
+	if s.m() > 0 {
+		s.setto(suffix)
+	}
+}
+
+func (s *stemmer) step1ab() {
+	if s.b[len(s.b)-1] == 's' {
+		if s.ends("sses") {
+			s.b = s.b[:len(s.b)-2]
+		} else if s.ends("ies") {
+			s.setto("i")
+		} else if len(s.b) >= 2 && s.b[len(s.b)-2] != 's' {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.b = s.b[:s.j+1]
+		k := len(s.b) - 1
+		if s.ends("at") {
+			s.setto("ate")
+		} else if s.ends("bl") {
+			s.setto("ble")
+		} else if s.ends("iz") {
+			s.setto("ize")
+		} else if s.doublec(k) {
+			switch s.b[k] {
+			case 'l', 's', 'z':
+			default:
+				s.b = s.b[:k]
+			}
+		} else {
+			s.j = k
+			if s.m() == 1 && s.cvc(k) {
+				s.b = append(s.b, 'e')
+			}
+		}
+	}
+}
+
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"},
+	{"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}, {"logi", "log"},
+}
+
+func (s *stemmer) step2() {
+	for _, p := range step2Suffixes {
+		if s.ends(p[0]) {
+			s.r(p[1])
+			return
+		}
+	}
+}
+
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"},
+	{"ful", ""}, {"ness", ""},
+}
+
+func (s *stemmer) step3() {
+	for _, p := range step3Suffixes {
+		if s.ends(p[0]) {
+			s.r(p[1])
+			return
+		}
+	}
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (s *stemmer) step4() {
+	for _, suffix := range step4Suffixes {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || (s.b[s.j] != 's' && s.b[s.j] != 't')) {
+			return
+		}
+		if s.m() > 1 {
+			s.b = s.b[:s.j+1]
+		}
+		return
+	}
+}
+
+func (s *stemmer) step5() {
+	k := len(s.b) - 1
+	s.j = k
+	if s.b[k] == 'e' {
+		s.j = k - 1
+		a := s.m()
+		if a > 1 || (a == 1 && !s.cvc(k-1)) {
+			s.b = s.b[:k]
+		}
+	}
+
+	k = len(s.b) - 1
+	s.j = k
+	if s.b[k] == 'l' && s.doublec(k) && s.m() > 1 {
+		s.b = s.b[:k]
+	}
+}
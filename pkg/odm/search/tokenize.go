@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// A single word of the text.
+type Token struct {
+	// The normalized term, lower cased and stemmed.
+	Term string
+
+	// The index of the word in the text, stop words are counted too so the phrases
+	// can skip over them.
+	Position int
+
+	// Covers the word in the original text.
+	Match api.Match
+}
+
+// Common English words which are not indexed.
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all am an and any
+		are as at be because been before being below between both but by can could did do
+		does doing down during each few for from further had has have having he her here
+		hers herself him himself his how i if in into is it its itself just me more most my
+		myself no nor not of off on once only or other our ours ourselves out over own same
+		she should so some such than that the their theirs them themselves then there these
+		they this those through to too under until up very was we were what when where which
+		while who whom why will with would you your yours yourself yourselves`) {
+		stopWords[w] = true
+	}
+}
+
+// Checks if the word is a stop word, the word must be lower cased.
+func IsStopWord(word string) bool {
+	return stopWords[word]
+}
+
+// Normalizes a single word the way the tokenizer does, without dropping stop words.
+func Normalize(word string) string {
+	return Stem(strings.ToLower(word))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+// Splits the text into words. Letters and numbers of any script make up the words,
+// anything else separates them. Apostrophes inside words are dropped, so "don't" is a
+// single word. Stop words are skipped but still take up a position. The offsets are
+// shifted by the given base.
+func Tokenize(text []byte, base int) []Token {
+	tokens := make([]Token, 0)
+	position := 0
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+
+		begin := i
+		word := new(strings.Builder)
+		for i < len(text) {
+			r, size = utf8.DecodeRune(text[i:])
+			if isWordRune(r) {
+				word.WriteRune(unicode.ToLower(r))
+				i += size
+				continue
+			}
+
+			// Keep going over the apostrophes between letters.
+			if r == '\'' || r == '’' {
+				if next, _ := utf8.DecodeRune(text[i+size:]); i+size < len(text) && unicode.IsLetter(next) {
+					i += size
+					continue
+				}
+			}
+			break
+		}
+
+		w := word.String()
+		if !stopWords[w] {
+			tokens = append(tokens, Token{
+				Term:     Stem(w),
+				Position: position,
+				Match:    api.Match{Begin: base + begin, End: base + i},
+			})
+		}
+		position += 1
+	}
+
+	return tokens
+}
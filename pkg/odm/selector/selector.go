@@ -0,0 +1,155 @@
+package selector
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The fields with a special meaning, any other field is a frontmatter property.
+const (
+	FIELD_TAG    string = "tag"
+	FIELD_PATH   string = "path"
+	FIELD_FOLDER string = "folder"
+	FIELD_TITLE  string = "title"
+	FIELD_HAS    string = "has"
+	FIELD_TEXT   string = "text"
+)
+
+// A single condition of the query.
+type term struct {
+	field  string
+	value  string
+	negate bool
+}
+
+// Selects the notes matching a query. The query is a list of terms separated by
+// spaces, a note must match all of them:
+//
+//	tag:#project      has the tag or one of its nested tags
+//	path:Daily/*.md   the path matches the glob
+//	folder:Projects   is in the folder or in one of its sub folders
+//	title:meeting     the title contains the text
+//	has:due           has the frontmatter property
+//	status:active     the property is, or the list property contains, the value
+//	word, "a phrase"  the note contains the text
+//	-term             the note doesn't match the term
+//
+// The values are compared case insensitively. An empty query matches every note.
+type Selector struct {
+	terms []term
+}
+
+// Parses the query.
+func Parse(query string) (*Selector, error) {
+	sel := &Selector{terms: make([]term, 0)}
+
+	for i := 0; i < len(query); {
+		if query[i] == ' ' || query[i] == '\t' {
+			i++
+			continue
+		}
+
+		t := term{field: FIELD_TEXT}
+		if query[i] == '-' {
+			t.negate = true
+			i++
+		}
+
+		if j := strings.IndexAny(query[i:], ": \t\""); j > 0 && query[i+j] == ':' {
+			t.field = strings.ToLower(query[i : i+j])
+			i += j + 1
+		}
+
+		if i < len(query) && query[i] == '"' {
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quote in the query")
+			}
+			t.value = query[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			end := strings.IndexAny(query[i:], " \t")
+			if end < 0 {
+				end = len(query) - i
+			}
+			t.value = query[i : i+end]
+			i += end
+		}
+
+		if t.value == "" && t.field == FIELD_TEXT {
+			return nil, errors.New("empty term in the query")
+		}
+		sel.terms = append(sel.terms, t)
+	}
+	return sel, nil
+}
+
+// Checks if the note matches every term of the query.
+func (sel *Selector) Match(md *metadata.Metadata, data []byte) bool {
+	for _, t := range sel.terms {
+		if t.match(md, data) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Gets the notes of the group matching the query, sorted by their paths.
+func (sel *Selector) Select(v *vault.Vault, g api.Group) ([]api.Set, error) {
+	sets := make([]api.Set, 0)
+	for _, s := range g.Sets() {
+		md, err := v.Metadata(s)
+		if err != nil {
+			return nil, err
+		}
+		if sel.Match(md, *s.Data()) {
+			sets = append(sets, s)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return v.RelPath(sets[i]) < v.RelPath(sets[j])
+	})
+	return sets, nil
+}
+
+// Checks if the note matches the term, ignoring the negation.
+func (t term) match(md *metadata.Metadata, data []byte) bool {
+	value := strings.ToLower(t.value)
+
+	switch t.field {
+	case FIELD_TAG:
+		return md.HasTag(value)
+	case FIELD_PATH:
+		return vault.MatchGlob(t.value, md.Path)
+	case FIELD_FOLDER:
+		folder := strings.Trim(value, "/")
+		return folder == "" || strings.HasPrefix(strings.ToLower(md.Path), folder+"/")
+	case FIELD_TITLE:
+		return strings.Contains(strings.ToLower(md.Title), value)
+	case FIELD_HAS:
+		_, ok := md.Frontmatter.Get(t.value)
+		return ok
+	case FIELD_TEXT:
+		return bytes.Contains(bytes.ToLower(data), []byte(value))
+	}
+
+	p, ok := md.Frontmatter.Get(t.field)
+	if !ok {
+		return false
+	}
+	if p.Kind == metadata.PropertyScalar || p.Kind == metadata.PropertyEmpty {
+		return strings.ToLower(p.Value) == value
+	}
+	for _, item := range p.List() {
+		if strings.ToLower(item) == value {
+			return true
+		}
+	}
+	return false
+}
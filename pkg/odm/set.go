@@ -69,6 +69,20 @@ func NewSetFromFile(file *file.File) (api.Set, error) {
 	}
 }
 
+// Creates a set from the already read contents of the file.
+func NewSetFromData(data []byte, file *file.File) (api.Set, error) {
+	attrib, err := disk.NewDiskSetAttributes(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &set{
+		data:       &data,
+		attributes: attrib,
+	}, nil
+}
+
 func NewSetFromFileOrEmpty(file *file.File) api.Set {
 	if data, err := io.ReadAll(file); err != nil {
 		return NewEmptySet()
@@ -0,0 +1,180 @@
+package tasks
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The folder of the default archive notes.
+const ARCHIVE_FOLDER string = "Archive"
+
+// The separators which can't be in the date of a file name.
+var archiveDateReplacer = strings.NewReplacer("/", "-", "\\", "-", ":", "-")
+
+// Gets the path of the default archive note, 'Archive/Tasks <date>.md' with the day in
+// the moment.js format. The slashes of formats like 'DD/MM/YYYY' would make folders, so
+// they are written as dashes.
+func ArchivePath(today time.Time, format string) string {
+	date := archiveDateReplacer.Replace(moment.Format(today, format))
+	return path.Join(ARCHIVE_FOLDER, "Tasks "+date) + vault.NOTE_EXTENSION
+}
+
+// Creates the back-reference from the archive note to the note a task came from.
+type LinkFunc func(from, target string) string
+
+// A task moved to the archive, with the lines nested under it.
+type Archived struct {
+	Task Task
+
+	// The lines moved along with the task, including the task itself.
+	Text string
+}
+
+// Moves the tasks done before the given day, with everything nested under them, out of
+// the notes and to the end of the archive note. The tasks are grouped under a heading
+// linking to the note they came from. A task is only moved when every task nested under
+// it is done or cancelled as well. The notes in the folder of the archive note are
+// skipped, unless it is the root of the vault. The sets are modified in memory, the
+// changed ones are returned with the archive note last.
+func Archive(v *vault.Vault, sets []api.Set, archive api.Set, before time.Time, link LinkFunc) ([]api.Set, []Archived, error) {
+	archivePath := v.RelPath(archive)
+	folder := path.Dir(archivePath)
+	changed := make([]api.Set, 0)
+	archived := make([]Archived, 0)
+
+	var text strings.Builder
+	for _, s := range sets {
+		notePath := v.RelPath(s)
+		// The other notes in the folder of the archive are the archives of the earlier
+		// days, their tasks are archived already.
+		if notePath == archivePath || folder != "." && strings.HasPrefix(notePath, folder+"/") {
+			continue
+		}
+
+		moved, matches := archivable(notePath, *s.Data(), Day(before))
+		if len(moved) == 0 {
+			continue
+		}
+		if _, err := s.Remove(&matches); err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, s)
+		archived = append(archived, moved...)
+
+		fmt.Fprintf(&text, "\n## %s\n\n", link(archivePath, notePath))
+		for _, a := range moved {
+			text.WriteString(a.Text)
+		}
+	}
+
+	if len(archived) == 0 {
+		return changed, archived, nil
+	}
+
+	data := *archive.Data()
+	content := text.String()
+	if len(data) == 0 {
+		content = strings.TrimPrefix(content, "\n")
+	} else if data[len(data)-1] != '\n' {
+		content = "\n" + content
+	}
+
+	end := api.Match{Begin: len(data), End: len(data)}
+	_, err := archive.InsertAfter(&[]api.Match{end}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(content), true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(changed, archive), archived, nil
+}
+
+// Finds the tasks of the note which can be archived, and the lines to remove for them.
+func archivable(path string, data []byte, before time.Time) ([]Archived, []api.Match) {
+	all := Parse(path, data)
+	archived := make([]Archived, 0)
+	matches := make([]api.Match, 0)
+
+	taken := 0
+	for i, t := range all {
+		if t.Match.Begin < taken || !t.Done() {
+			continue
+		}
+		if done, ok := t.Date(FIELD_DONE); !ok || !done.Before(before) {
+			continue
+		}
+
+		block := subtree(data, t)
+		closed := true
+		for _, c := range all[i+1:] {
+			if c.Match.Begin >= block.End {
+				break
+			}
+			if c.Open() {
+				closed = false
+				break
+			}
+		}
+		if !closed {
+			continue
+		}
+
+		// The lines are written without the indentation of the task.
+		lines := strings.SplitAfter(string(data[block.Begin:block.End]), "\n")
+		for j, l := range lines {
+			lines[j] = strings.TrimPrefix(l, t.Indent)
+		}
+		text := strings.Join(lines, "")
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+
+		archived = append(archived, Archived{Task: t, Text: text})
+		matches = append(matches, block)
+		taken = block.End
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Begin < matches[j].Begin
+	})
+	return archived, matches
+}
+
+// Gets the lines of the task and the ones nested under it, which are the following
+// lines indented deeper than the task, up to an empty line. The new line at the end
+// is included.
+func subtree(data []byte, t Task) api.Match {
+	width := indentWidth(t.Indent)
+	end := lineEnd(data, t.Match.End)
+	for end < len(data) {
+		next := lineEnd(data, end)
+		line := strings.TrimRight(string(data[end:next]), "\r\n")
+		if strings.TrimLeft(line, " \t>") == "" {
+			break
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t>"))]
+		if indentWidth(indent) <= width {
+			break
+		}
+		end = next
+	}
+	return api.Match{Begin: t.Match.Begin, End: end}
+}
+
+// Gets the offset after the new line ending the line at the offset.
+func lineEnd(data []byte, offset int) int {
+	for offset < len(data) && data[offset] != '\n' {
+		offset++
+	}
+	if offset < len(data) {
+		offset++
+	}
+	return offset
+}
@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchivePath(t *testing.T) {
+	today := time.Date(2024, 5, 10, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"YYYY-MM-DD", "Archive/Tasks 2024-05-10.md"},
+		{"DD/MM/YYYY", "Archive/Tasks 10-05-2024.md"},
+		{"YYYY\\MM\\DD", "Archive/Tasks 2024-05-10.md"},
+		{"MMM D, YYYY", "Archive/Tasks May 10, 2024.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := ArchivePath(today, tt.format); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
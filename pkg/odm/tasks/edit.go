@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+)
+
+// The markers written for the date fields.
+var FieldMarkers = map[string]string{
+	FIELD_DUE:       "📅",
+	FIELD_SCHEDULED: "⏳",
+	FIELD_START:     "🛫",
+	FIELD_DONE:      "✅",
+	FIELD_CREATED:   "➕",
+	FIELD_CANCELLED: "❌",
+}
+
+// A change in the line of a task, the offsets are absolute.
+type edit struct {
+	match api.Match
+	text  string
+}
+
+// Checks the task off or opens it again. Checking off adds the done date and the
+// next occurrence of a recurring task, opening removes the done date.
+func Toggle(s api.Set, t Task, today time.Time) (bool, error) {
+	if t.Done() {
+		return SetStatus(s, t, STATUS_TODO, today)
+	}
+	return Complete(s, t, today)
+}
+
+// Sets the status character of the task. The done date is added when the task is
+// checked off and removed when it is not done anymore, the rest of the line stays
+// as it is.
+func SetStatus(s api.Set, t Task, status rune, today time.Time) (bool, error) {
+	edits := []edit{{match: t.StatusMatch, text: string(status)}}
+
+	done, hasDone := t.Field(FIELD_DONE)
+	isDone := status == 'x' || status == 'X'
+	if isDone && !t.Done() && !hasDone {
+		edits = append(edits, t.appendField(FIELD_DONE, Day(today).Format(DATE_LAYOUT)))
+	} else if !isDone && hasDone {
+		edits = append(edits, edit{match: done.Match, text: ""})
+	}
+	return t.apply(s, edits)
+}
+
+// Sets the date field of the task, the field is added if the task doesn't have it.
+func SetDate(s api.Set, t Task, field string, date time.Time) (bool, error) {
+	if _, ok := FieldMarkers[field]; !ok {
+		return false, fmt.Errorf("'%s' is not a date field", field)
+	}
+
+	value := date.Format(DATE_LAYOUT)
+	if f, ok := t.Field(field); ok {
+		return t.apply(s, []edit{{match: f.ValueMatch, text: value}})
+	}
+	return t.apply(s, []edit{t.appendField(field, value)})
+}
+
+// Moves the due date, or the scheduled date if the task only has that one.
+func Reschedule(s api.Set, t Task, date time.Time) (bool, error) {
+	field := FIELD_DUE
+	if _, ok := t.Field(FIELD_DUE); !ok {
+		if _, ok := t.Field(FIELD_SCHEDULED); ok {
+			field = FIELD_SCHEDULED
+		}
+	}
+	return SetDate(s, t, field, date)
+}
+
+// Removes the field from the task.
+func RemoveField(s api.Set, t Task, field string) (bool, error) {
+	f, ok := t.Field(field)
+	if !ok {
+		return false, nil
+	}
+	return t.apply(s, []edit{{match: f.Match, text: ""}})
+}
+
+// Creates the edit adding a field to the end of the task, before the block id.
+func (t Task) appendField(field, value string) edit {
+	at := t.Match.End
+	if t.BlockID != "" {
+		m := blockIDRegex.FindStringIndex(t.Raw)
+		at = t.Match.Begin + m[0]
+	}
+	return edit{match: api.Match{Begin: at, End: at}, text: " " + FieldMarkers[field] + " " + value}
+}
+
+// Applies the edits to the line of the task through the SetModifier. The line must
+// be the same as when the task is parsed.
+func (t Task) apply(s api.Set, edits []edit) (bool, error) {
+	return t.replace(s, t.render(edits))
+}
+
+// Gets the line of the task with the edits made on it.
+func (t Task) render(edits []edit) string {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].match.Begin > edits[j].match.Begin
+	})
+	line := t.Raw
+	for _, e := range edits {
+		begin, end := e.match.Begin-t.Match.Begin, e.match.End-t.Match.Begin
+		line = line[:begin] + e.text + line[end:]
+	}
+	return line
+}
+
+// Replaces the line of the task with the text through the SetModifier. The line must
+// be the same as when the task is parsed.
+func (t Task) replace(s api.Set, text string) (bool, error) {
+	data := *s.Data()
+	if t.Match.End > len(data) || string(data[t.Match.Begin:t.Match.End]) != t.Raw {
+		return false, fmt.Errorf("%s:%d: the task has changed since it is read", t.Path, t.Line)
+	}
+	if text == t.Raw {
+		return false, nil
+	}
+
+	return s.Replace(&[]api.Match{t.Match}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(text), true
+	})
+}
@@ -0,0 +1,177 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The keys the tasks can be sorted by.
+const (
+	SORT_DUE       string = "due"
+	SORT_SCHEDULED string = "scheduled"
+	SORT_START     string = "start"
+	SORT_DONE      string = "done"
+	SORT_PRIORITY  string = "priority"
+	SORT_PATH      string = "path"
+	SORT_STATUS    string = "status"
+	SORT_TEXT      string = "text"
+)
+
+var SortKeys = []string{SORT_DUE, SORT_SCHEDULED, SORT_START, SORT_DONE, SORT_PRIORITY, SORT_PATH, SORT_STATUS, SORT_TEXT}
+
+// Decides if a task is kept.
+type Predicate func(t *Task) bool
+
+// Gets the tasks of every note in the group, ordered by their paths and lines.
+func Collect(v *vault.Vault, g api.Group) ([]Task, error) {
+	sets := g.Sets()
+	sort.Slice(sets, func(i, j int) bool {
+		return v.RelPath(sets[i]) < v.RelPath(sets[j])
+	})
+
+	all := make([]Task, 0)
+	for _, s := range sets {
+		all = append(all, Parse(v.RelPath(s), *s.Data())...)
+	}
+	return all, nil
+}
+
+// Gets the tasks matching all of the predicates.
+func Filter(all []Task, predicates ...Predicate) []Task {
+	kept := make([]Task, 0)
+	for i := range all {
+		ok := true
+		for _, p := range predicates {
+			if !p(&all[i]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			kept = append(kept, all[i])
+		}
+	}
+	return kept
+}
+
+// Keeps the tasks which are neither done nor cancelled.
+func IsOpen() Predicate {
+	return func(t *Task) bool {
+		return t.Open()
+	}
+}
+
+// Keeps the tasks which are checked off.
+func IsDone() Predicate {
+	return func(t *Task) bool {
+		return t.Done()
+	}
+}
+
+// Keeps the open tasks due before the day.
+func Overdue(today time.Time) Predicate {
+	today = Day(today)
+	return func(t *Task) bool {
+		due, ok := t.Date(FIELD_DUE)
+		return ok && t.Open() && due.Before(today)
+	}
+}
+
+// Keeps the tasks due between the days, both included.
+func DueBetween(from, to time.Time) Predicate {
+	from, to = Day(from), Day(to)
+	return func(t *Task) bool {
+		due, ok := t.Date(FIELD_DUE)
+		return ok && !due.Before(from) && !due.After(to)
+	}
+}
+
+// Keeps the tasks due in the week of the day, from Monday to Sunday.
+func DueThisWeek(today time.Time) Predicate {
+	monday := Day(today).AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	return DueBetween(monday, monday.AddDate(0, 0, 6))
+}
+
+// Keeps the tasks with the tag or one of its nested tags.
+func WithTag(tag string) Predicate {
+	return func(t *Task) bool {
+		return t.HasTag(tag)
+	}
+}
+
+// Keeps the tasks in the notes matching the glob, see vault.MatchGlob.
+func InPath(glob string) Predicate {
+	return func(t *Task) bool {
+		return vault.MatchGlob(glob, t.Path)
+	}
+}
+
+// Sorts the tasks by the keys, the ones missing a date go last. The order is
+// stable, so the tasks with equal keys stay in the order they are in the notes.
+func Sort(all []Task, keys ...string) error {
+	for _, k := range keys {
+		if !validSortKey(k) {
+			return fmt.Errorf("unknown sort key '%s', use one of %s", k, strings.Join(SortKeys, ", "))
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		for _, k := range keys {
+			if c := compare(&all[i], &all[j], k); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func validSortKey(k string) bool {
+	for _, key := range SortKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Compares the tasks by the key, the result is like strings.Compare.
+func compare(a, b *Task, key string) int {
+	switch key {
+	case SORT_PRIORITY:
+		// The more urgent first.
+		return b.Priority - a.Priority
+	case SORT_PATH:
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	case SORT_STATUS:
+		return int(a.Status) - int(b.Status)
+	case SORT_TEXT:
+		return strings.Compare(strings.ToLower(a.Text), strings.ToLower(b.Text))
+	}
+
+	da, oka := a.Date(key)
+	db, okb := b.Date(key)
+	switch {
+	case !oka && !okb:
+		return 0
+	case !oka:
+		return 1
+	case !okb:
+		return -1
+	}
+	return da.Compare(db)
+}
+
+// Gets the start of the day of the time.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,317 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+)
+
+// The units of the recurrence rules.
+const (
+	UNIT_DAY   string = "day"
+	UNIT_WEEK  string = "week"
+	UNIT_MONTH string = "month"
+	UNIT_YEAR  string = "year"
+)
+
+// The month day of the rules recurring on the last day of the month.
+const LAST_DAY int = -1
+
+// The date fields which are moved to the next occurrence, the first one the task has
+// is the one the rule is applied to.
+var RecurringFields = []string{FIELD_DUE, FIELD_SCHEDULED, FIELD_START}
+
+// A parsed recurrence rule like 'every 2 weeks on Monday' or 'every month on the 15th
+// when done'.
+type Rule struct {
+	// The rule as it is written.
+	Text string
+
+	// How many units there are between the occurrences.
+	Interval int
+	Unit     string
+
+	// The days of the week for the weekly rules, empty for any.
+	Weekdays []time.Weekday
+
+	// The day of the month for the monthly rules, 0 for any and LAST_DAY for the last.
+	MonthDay int
+
+	// Whether the next occurrence is counted from the day the task is done instead of
+	// from its dates.
+	WhenDone bool
+}
+
+var (
+	ruleRegex     = regexp.MustCompile(`^every\s+(?:(\d+)\s+)?(\w+?)s?(?:\s+on\s+(.+?))?(?:\s+when\s+done)?$`)
+	whenDoneRegex = regexp.MustCompile(`\s+when\s+done$`)
+	monthDayRegex = regexp.MustCompile(`^(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Parses the recurrence rule of a task. Understands the rules the Tasks plugin writes,
+// e.g. 'every day', 'every 3 days', 'every weekday', 'every Monday', 'every week on
+// Monday, Friday', 'every 2 months', 'every month on the last', 'every year', each
+// optionally followed by 'when done'.
+func ParseRule(text string) (Rule, error) {
+	rule := Rule{Text: text, Interval: 1}
+	s := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	rule.WhenDone = whenDoneRegex.MatchString(s)
+
+	m := ruleRegex.FindStringSubmatch(s)
+	if m == nil {
+		return rule, fmt.Errorf("'%s' is not a recurrence rule", text)
+	}
+	if m[1] != "" {
+		rule.Interval, _ = strconv.Atoi(m[1])
+		if rule.Interval < 1 {
+			return rule, fmt.Errorf("'%s' has no interval", text)
+		}
+	}
+
+	switch unit := m[2]; unit {
+	case UNIT_DAY, UNIT_WEEK, UNIT_MONTH, UNIT_YEAR:
+		rule.Unit = unit
+	case "weekday":
+		rule.Unit = UNIT_WEEK
+		rule.Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	default:
+		// A day of the week, e.g. 'every monday'.
+		d, ok := weekdays[unit]
+		if !ok {
+			return rule, fmt.Errorf("'%s' has an unknown unit '%s'", text, unit)
+		}
+		if m[3] != "" {
+			return rule, fmt.Errorf("'%s' is not a recurrence rule", text)
+		}
+		rule.Unit = UNIT_WEEK
+		rule.Weekdays = []time.Weekday{d}
+	}
+
+	if on := m[3]; on != "" {
+		switch rule.Unit {
+		case UNIT_WEEK:
+			rule.Weekdays = make([]time.Weekday, 0)
+			for _, name := range strings.FieldsFunc(on, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if name == "and" {
+					continue
+				}
+				d, ok := weekdays[name]
+				if !ok {
+					return rule, fmt.Errorf("'%s' has an unknown day '%s'", text, name)
+				}
+				rule.Weekdays = append(rule.Weekdays, d)
+			}
+		case UNIT_MONTH:
+			if on == "the last" || on == "last" || on == "the last day" {
+				rule.MonthDay = LAST_DAY
+			} else if dm := monthDayRegex.FindStringSubmatch(on); dm != nil {
+				rule.MonthDay, _ = strconv.Atoi(dm[1])
+				if rule.MonthDay < 1 || rule.MonthDay > 31 {
+					return rule, fmt.Errorf("'%s' has no day %d", text, rule.MonthDay)
+				}
+			} else {
+				return rule, fmt.Errorf("'%s' has an unknown day '%s'", text, on)
+			}
+		default:
+			return rule, fmt.Errorf("'%s' can't recur on '%s'", text, on)
+		}
+	}
+	return rule, nil
+}
+
+// Gets the first date of the rule after the given one.
+func (r Rule) Next(from time.Time) time.Time {
+	from = Day(from)
+	switch r.Unit {
+	case UNIT_DAY:
+		return from.AddDate(0, 0, r.Interval)
+	case UNIT_WEEK:
+		if len(r.Weekdays) == 0 {
+			return from.AddDate(0, 0, 7*r.Interval)
+		}
+		// A later day in the same week, or the first day in the week of the next
+		// occurrence. The weeks start on Monday.
+		monday := from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		for d := from.AddDate(0, 0, 1); d.Before(monday.AddDate(0, 0, 7)); d = d.AddDate(0, 0, 1) {
+			if r.on(d.Weekday()) {
+				return d
+			}
+		}
+		week := monday.AddDate(0, 0, 7*r.Interval)
+		for d := week; ; d = d.AddDate(0, 0, 1) {
+			if r.on(d.Weekday()) {
+				return d
+			}
+		}
+	case UNIT_MONTH:
+		if r.MonthDay != 0 {
+			if d := monthDay(from.Year(), from.Month(), r.MonthDay, from.Location()); d.After(from) {
+				return d
+			}
+			next := addMonths(from, r.Interval)
+			return monthDay(next.Year(), next.Month(), r.MonthDay, from.Location())
+		}
+		return addMonths(from, r.Interval)
+	case UNIT_YEAR:
+		return addMonths(from, 12*r.Interval)
+	}
+	return from
+}
+
+// Whether the weekly rule recurs on the day.
+func (r Rule) on(d time.Weekday) bool {
+	for _, w := range r.Weekdays {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds the months, a day which doesn't exist in the month becomes its last day, e.g.
+// 31 January is followed by 28 February.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, n, 0)
+	return monthDay(first.Year(), first.Month(), t.Day(), t.Location())
+}
+
+// Gets the day of the month, or its last day if the month is shorter.
+func monthDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day == LAST_DAY || day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// Gets the line of the next occurrence of the recurring task. The first of its due,
+// scheduled and start dates goes to the next date of the rule, counted from today for
+// the 'when done' rules, and the others keep their distance to it. The occurrence is
+// open, and has no done date nor block id.
+func (t Task) NextOccurrence(today time.Time) (string, error) {
+	rule, err := ParseRule(t.Recurrence())
+	if err != nil {
+		return "", fmt.Errorf("%s:%d: %w", t.Path, t.Line, err)
+	}
+
+	edits := []edit{{match: t.StatusMatch, text: string(STATUS_TODO)}}
+	for _, name := range []string{FIELD_DONE, FIELD_CANCELLED} {
+		if f, ok := t.Field(name); ok {
+			edits = append(edits, edit{match: f.Match, text: ""})
+		}
+	}
+	if t.BlockID != "" {
+		m := blockIDRegex.FindStringIndex(t.Raw)
+		edits = append(edits, edit{match: api.Match{Begin: t.Match.Begin + m[0], End: t.Match.End}, text: ""})
+	}
+	if f, ok := t.Field(FIELD_CREATED); ok {
+		edits = append(edits, edit{match: f.ValueMatch, text: Day(today).Format(DATE_LAYOUT)})
+	}
+
+	// The reference date the rule is applied to, the rest is moved as much.
+	var base, next time.Time
+	for _, name := range RecurringFields {
+		if d, ok := t.Date(name); ok {
+			base = d
+			break
+		}
+	}
+	if rule.WhenDone || base.IsZero() {
+		next = rule.Next(today)
+		if base.IsZero() {
+			base = Day(today)
+		}
+	} else {
+		next = rule.Next(base)
+	}
+	for _, name := range RecurringFields {
+		if d, ok := t.Date(name); ok {
+			f, _ := t.Field(name)
+			moved := next.AddDate(0, 0, int(d.Sub(base).Round(24*time.Hour).Hours()/24))
+			edits = append(edits, edit{match: f.ValueMatch, text: moved.Format(DATE_LAYOUT)})
+		}
+	}
+	return t.render(edits), nil
+}
+
+// Checks the task off like SetStatus, and if it recurs adds its next occurrence on a
+// new line above it, the way the Tasks plugin does.
+func Complete(s api.Set, t Task, today time.Time) (bool, error) {
+	if t.Recurrence() == "" || t.Done() {
+		return SetStatus(s, t, STATUS_DONE, today)
+	}
+
+	next, err := t.NextOccurrence(today)
+	if err != nil {
+		return false, err
+	}
+
+	edits := []edit{{match: t.StatusMatch, text: string(STATUS_DONE)}}
+	if _, ok := t.Field(FIELD_DONE); !ok {
+		edits = append(edits, t.appendField(FIELD_DONE, Day(today).Format(DATE_LAYOUT)))
+	}
+	return t.replace(s, next+"\n"+t.render(edits))
+}
+
+// Adds the missing next occurrences of the recurring tasks which are checked off, e.g.
+// in an editor without the Tasks plugin. A task already has its next occurrence when
+// the note has an open task with the same description and rule. The sets are modified
+// in memory, the changed ones are returned with the tasks which are rolled over.
+func Rollover(v *vault.Vault, sets []api.Set, today time.Time) ([]api.Set, []Task, error) {
+	changed := make([]api.Set, 0)
+	rolled := make([]Task, 0)
+
+	for _, s := range sets {
+		all := Parse(v.RelPath(s), *s.Data())
+		open := make(map[string]bool)
+		for _, t := range all {
+			if t.Open() && t.Recurrence() != "" {
+				open[t.Text+"\x00"+t.Recurrence()] = true
+			}
+		}
+
+		occurrences := make(map[int]string)
+		matches := make([]api.Match, 0)
+		for _, t := range all {
+			key := t.Text + "\x00" + t.Recurrence()
+			if !t.Done() || t.Recurrence() == "" || open[key] {
+				continue
+			}
+			next, err := t.NextOccurrence(today)
+			if err != nil {
+				return nil, nil, err
+			}
+			open[key] = true
+			occurrences[t.Match.Begin] = next + "\n"
+			matches = append(matches, t.Match)
+			rolled = append(rolled, t)
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		_, err := s.InsertBefore(&matches, func(m api.Match, _ api.Data) ([]byte, bool) {
+			return []byte(occurrences[m.Begin]), true
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		changed = append(changed, s)
+	}
+	return changed, rolled, nil
+}
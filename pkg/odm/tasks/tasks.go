@@ -0,0 +1,341 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The layout of the dates, the Tasks plugin always uses it.
+const DATE_LAYOUT string = "2006-01-02"
+
+// The statuses with a meaning, any other character is kept as a custom status.
+const (
+	STATUS_TODO        rune = ' '
+	STATUS_DONE        rune = 'x'
+	STATUS_IN_PROGRESS rune = '/'
+	STATUS_CANCELLED   rune = '-'
+)
+
+// The metadata fields of the Tasks plugin.
+const (
+	FIELD_DUE        string = "due"
+	FIELD_SCHEDULED  string = "scheduled"
+	FIELD_START      string = "start"
+	FIELD_DONE       string = "done"
+	FIELD_CREATED    string = "created"
+	FIELD_CANCELLED  string = "cancelled"
+	FIELD_RECURRENCE string = "recurrence"
+	FIELD_PRIORITY   string = "priority"
+)
+
+// The priorities, a higher one is more urgent. Tasks without a priority marker are
+// PRIORITY_NONE, which sorts between low and medium like in the Tasks plugin.
+const (
+	PRIORITY_LOWEST  int = 0
+	PRIORITY_LOW     int = 1
+	PRIORITY_NONE    int = 2
+	PRIORITY_MEDIUM  int = 3
+	PRIORITY_HIGH    int = 4
+	PRIORITY_HIGHEST int = 5
+)
+
+// The markers of the fields with a value.
+var Markers = map[string]string{
+	"📅": FIELD_DUE,
+	"⏳": FIELD_SCHEDULED,
+	"🛫": FIELD_START,
+	"✅": FIELD_DONE,
+	"➕": FIELD_CREATED,
+	"❌": FIELD_CANCELLED,
+	"🔁": FIELD_RECURRENCE,
+}
+
+// The markers of the priorities.
+var Priorities = map[string]int{
+	"🔺": PRIORITY_HIGHEST,
+	"⏫": PRIORITY_HIGH,
+	"🔼": PRIORITY_MEDIUM,
+	"🔽": PRIORITY_LOW,
+	"⏬": PRIORITY_LOWEST,
+}
+
+// A metadata field of a task.
+type Field struct {
+	// The name, one of the FIELD_* constants.
+	Name  string
+	Value string
+
+	// Covers the marker, the value and the space before the marker, so removing it
+	// leaves the rest of the line as it is.
+	Match api.Match
+
+	// Covers only the value.
+	ValueMatch api.Match
+}
+
+// A checkbox list item.
+type Task struct {
+	// The path of the note relative to the vault.
+	Path string
+
+	// The 1 based line of the task.
+	Line int
+
+	// The whitespace and the quote markers before the list marker.
+	Indent string
+
+	Status rune
+
+	// The description without the metadata fields and the block id.
+	Text string
+
+	Priority int
+	Tags     []string
+	Fields   []Field
+
+	// The block id without the '^', empty if there is none.
+	BlockID string
+
+	// The index of the parent task in the same note, -1 for the top level ones.
+	Parent int
+
+	// Covers the whole line without the new line.
+	Match api.Match
+
+	// Covers the status character between the brackets.
+	StatusMatch api.Match
+
+	// The line as it was parsed, to check it hasn't changed before editing it.
+	Raw string
+}
+
+var (
+	taskRegex     = regexp.MustCompile(`(?m)^([ \t]*(?:>[ \t]?)*[ \t]*)([-*+]|\d+[.)])[ \t]+\[(.)\][ \t]+(.*?)\r?$`)
+	listItemRegex = regexp.MustCompile(`^([-*+]|\d+[.)])[ \t]`)
+	blockIDRegex  = regexp.MustCompile(`[ \t]+\^([A-Za-z0-9\-]+)[ \t]*$`)
+	tagRegex      = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/\-]*[\p{L}_/\-][\p{L}\p{N}_/\-]*)`)
+)
+
+// Parses the tasks of the note. The code blocks and the comments are skipped.
+func Parse(path string, data []byte) []Task {
+	fm := metadata.ParseFrontmatter(data)
+	verbatim := metadata.FindVerbatim(data, fm.Match.End)
+	lines := metadata.NewLineIndex(data)
+
+	tasks := make([]Task, 0)
+	for _, m := range taskRegex.FindAllSubmatchIndex(data, -1) {
+		if m[0] < fm.Match.End || metadata.Inside(verbatim, m[0]) {
+			continue
+		}
+
+		line, _ := lines.Position(m[0])
+		status := []rune(string(data[m[6]:m[7]]))[0]
+		t := Task{
+			Path:        path,
+			Line:        line,
+			Indent:      string(data[m[2]:m[3]]),
+			Status:      status,
+			Priority:    PRIORITY_NONE,
+			Tags:        make([]string, 0),
+			Fields:      make([]Field, 0),
+			Parent:      -1,
+			Match:       api.Match{Begin: m[0], End: m[9]},
+			StatusMatch: api.Match{Begin: m[6], End: m[7]},
+			Raw:         string(data[m[0]:m[9]]),
+		}
+		t.parseDescription(data, m[8], m[9])
+
+		// The parent is the closest task above with a smaller indentation in the
+		// same list.
+		width := indentWidth(t.Indent)
+		for i := len(tasks) - 1; i >= 0; i-- {
+			if endsList(data[tasks[i].Match.End:m[0]]) {
+				break
+			}
+			if indentWidth(tasks[i].Indent) < width {
+				t.Parent = i
+				break
+			}
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks
+}
+
+// Parses the metadata fields, the block id and the tags of the description.
+func (t *Task) parseDescription(data []byte, begin, end int) {
+	desc := string(data[begin:end])
+
+	if m := blockIDRegex.FindStringSubmatchIndex(desc); m != nil {
+		t.BlockID = desc[m[2]:m[3]]
+		desc = desc[:m[0]]
+		end = begin + m[0]
+	}
+
+	// Find the markers, each value runs until the next marker.
+	type marker struct {
+		name     string
+		begin    int
+		end      int
+		priority int
+	}
+	markers := make([]marker, 0)
+	for i := 0; i < len(desc); {
+		found := false
+		for emoji, name := range Markers {
+			if strings.HasPrefix(desc[i:], emoji) {
+				markers = append(markers, marker{name: name, begin: i, end: skipSelector(desc, i+len(emoji))})
+				found = true
+				break
+			}
+		}
+		if !found {
+			for emoji, p := range Priorities {
+				if strings.HasPrefix(desc[i:], emoji) {
+					markers = append(markers, marker{name: FIELD_PRIORITY, begin: i, end: skipSelector(desc, i+len(emoji)), priority: p})
+					found = true
+					break
+				}
+			}
+		}
+		if found {
+			i = markers[len(markers)-1].end
+		} else {
+			i++
+		}
+	}
+
+	text := desc
+	if len(markers) > 0 {
+		text = desc[:markers[0].begin]
+	}
+	t.Text = strings.TrimSpace(text)
+
+	for i, mk := range markers {
+		valueEnd := len(desc)
+		if i+1 < len(markers) {
+			valueEnd = markers[i+1].begin
+		}
+		value := strings.TrimSpace(desc[mk.end:valueEnd])
+		valueBegin := mk.end + strings.Index(desc[mk.end:valueEnd], value)
+		if value == "" {
+			valueBegin = mk.end
+		}
+
+		// The space before the marker goes with the field.
+		fieldBegin := mk.begin
+		for fieldBegin > 0 && (desc[fieldBegin-1] == ' ' || desc[fieldBegin-1] == '\t') {
+			fieldBegin--
+		}
+
+		f := Field{
+			Name:       mk.name,
+			Value:      value,
+			Match:      api.Match{Begin: begin + fieldBegin, End: begin + valueBegin + len(value)},
+			ValueMatch: api.Match{Begin: begin + valueBegin, End: begin + valueBegin + len(value)},
+		}
+		if mk.name == FIELD_PRIORITY {
+			t.Priority = mk.priority
+			f.Match.End = begin + mk.end
+			f.ValueMatch = api.Match{Begin: begin + mk.begin, End: begin + mk.end}
+			f.Value = desc[mk.begin:mk.end]
+		} else if mk.name != FIELD_RECURRENCE && len(value) > len(DATE_LAYOUT) {
+			// Only the date belongs to a date field, the rest is text.
+			f.Value = value[:len(DATE_LAYOUT)]
+			f.ValueMatch.End = f.ValueMatch.Begin + len(DATE_LAYOUT)
+			f.Match.End = f.ValueMatch.End
+		}
+		t.Fields = append(t.Fields, f)
+	}
+
+	for _, m := range tagRegex.FindAllStringSubmatch(desc, -1) {
+		t.Tags = append(t.Tags, m[1])
+	}
+}
+
+// Checks if the lines between two tasks end the list, which is the case when one of
+// them is neither empty, indented nor a list item.
+func endsList(between []byte) bool {
+	for _, line := range strings.Split(string(between), "\n") {
+		if strings.TrimSpace(line) == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '>' {
+			continue
+		}
+		if !listItemRegex.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Skips the emoji variation selector if there is one.
+func skipSelector(s string, i int) int {
+	if strings.HasPrefix(s[i:], "\uFE0F") {
+		return i + len("\uFE0F")
+	}
+	return i
+}
+
+// Gets the width of the indentation, a tab counts as four spaces.
+func indentWidth(indent string) int {
+	w := 0
+	for _, c := range indent {
+		if c == '\t' {
+			w += 4
+		} else {
+			w += 1
+		}
+	}
+	return w
+}
+
+// Gets the field with the name.
+func (t *Task) Field(name string) (Field, bool) {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Gets the date field, false if the task doesn't have it or it is not a date.
+func (t *Task) Date(name string) (time.Time, bool) {
+	f, ok := t.Field(name)
+	if !ok {
+		return time.Time{}, false
+	}
+	d, err := time.ParseInLocation(DATE_LAYOUT, f.Value, time.Local)
+	return d, err == nil
+}
+
+// Gets the recurrence rule, empty if the task doesn't recur.
+func (t *Task) Recurrence() string {
+	f, _ := t.Field(FIELD_RECURRENCE)
+	return f.Value
+}
+
+// Whether the task is checked off.
+func (t *Task) Done() bool {
+	return t.Status == 'x' || t.Status == 'X'
+}
+
+// Whether the task is neither done nor cancelled.
+func (t *Task) Open() bool {
+	return !t.Done() && t.Status != STATUS_CANCELLED
+}
+
+// Checks if the task has the tag or one of its nested tags.
+func (t *Task) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	for _, tt := range t.Tags {
+		tt = strings.ToLower(tt)
+		if tt == tag || strings.HasPrefix(tt, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,500 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/frontmatter"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/moment"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// The folder of the templates when neither odm nor Obsidian is told otherwise.
+const DEFAULT_FOLDER string = "Templates"
+
+// The built in variables.
+const (
+	VAR_TITLE string = "title"
+	VAR_DATE  string = "date"
+	VAR_TIME  string = "time"
+)
+
+// Written in place of the cursor marker until the note is complete, so the position
+// is right after the frontmatter is changed.
+const cursorMark string = "\x00cursor\x00"
+
+const (
+	node_text = iota
+	node_variable
+	node_prompt
+	node_include
+	node_cursor
+	node_if
+)
+
+// A part of a parsed template.
+type node struct {
+	kind int
+
+	// The text, or the name of the variable, the prompt or the included template.
+	text string
+
+	// What comes after the ':' of a variable, e.g. the format of a date, or the
+	// default answer of a prompt.
+	arg string
+
+	// The condition of the conditionals, with the nodes used when it holds and when
+	// it doesn't.
+	cond      condition
+	then      []node
+	otherwise []node
+}
+
+// A condition like 'name', 'name == value' or 'name != value'. Without a value it
+// holds if the variable is not empty.
+type condition struct {
+	name   string
+	op     string
+	value  string
+	negate bool
+}
+
+// A frontmatter property set on the new notes.
+type Property struct {
+	Key   string
+	Value *yaml.Node
+}
+
+// Creates the notes from the templates in a folder of the vault. The templates are
+// notes with '{{...}}' tags:
+//
+//	{{title}}                     the title of the new note
+//	{{date}}, {{date:YYYY-MM-DD}}  the date of today, with the default or the given format
+//	{{time}}, {{time:HH:mm}}       the time of now
+//	{{name}}, {{name:default}}     a variable, with a default value if it is not given
+//	{{prompt:Question|default}}    asks for the value
+//	{{#if name}}..{{else}}..{{/if}} only the first part if the variable is not empty,
+//	                              also {{#if name == value}} and {{#unless name}}
+//	{{> other}}                   the other template
+//	{{cursor}}                    where the editing continues, removed from the note
+type Engine struct {
+	vault  *vault.Vault
+	folder string
+
+	// The moment.js formats of {{date}} and {{time}}.
+	DateFormat string
+	TimeFormat string
+
+	// The time the dates are written for.
+	Now time.Time
+
+	// The values of the variables, they also answer the prompts with the same name.
+	Vars map[string]string
+
+	// The date variables, written like {{date}} with the default or the given format,
+	// e.g. {{yesterday}} of a daily note.
+	Dates map[string]time.Time
+
+	// Set on the frontmatter of the new notes.
+	Properties []Property
+
+	// Asks the question of a prompt. Without it the prompts take their defaults.
+	Prompt func(question, def string) (string, error)
+
+	answers map[string]string
+}
+
+var tagRegex = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// Creates the engine of the templates in the folder relative to the vault.
+func NewEngine(v *vault.Vault, folder string) *Engine {
+	return &Engine{
+		vault:      v,
+		folder:     strings.Trim(folder, "/"),
+		DateFormat: "YYYY-MM-DD",
+		TimeFormat: "HH:mm",
+		Now:        time.Now(),
+		Vars:       make(map[string]string),
+		Dates:      make(map[string]time.Time),
+		Properties: make([]Property, 0),
+		answers:    make(map[string]string),
+	}
+}
+
+// Gets the folder of the templates relative to the vault.
+func (e *Engine) Folder() string {
+	return e.folder
+}
+
+// Gets the names of the templates, which are their paths in the folder without the
+// extension.
+func (e *Engine) Templates() ([]string, error) {
+	folder, err := e.vault.Root().Folder(e.folder)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := folder.Exists(); err != nil || !ok {
+		return []string{}, err
+	}
+
+	names := make([]string, 0)
+	err = folder.Walk(func(f *file.File) error {
+		if !vault.IsNote(f) {
+			return nil
+		}
+		if rel, err := folder.Rel(f); err == nil {
+			names = append(names, strings.TrimSuffix(rel, path.Ext(rel)))
+			return nil
+		} else {
+			return err
+		}
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// Builds the note at the path relative to the vault from the template, with the
+// properties set on its frontmatter. Without a template the note is empty. The note
+// must not exist; the set is only in memory, use Create to write it. Returns the
+// offset of the cursor too, -1 if there is none.
+func (e *Engine) Build(name, notePath, title string) (api.Set, int, error) {
+	f, err := e.vault.Root().File(notePath)
+	if err != nil {
+		return nil, -1, err
+	}
+	if ok, err := f.Exists(); err != nil {
+		return nil, -1, err
+	} else if ok {
+		return nil, -1, fmt.Errorf("'%s' already exists", notePath)
+	}
+
+	var b strings.Builder
+	if name != "" {
+		if err := e.include(&b, name, title, []string{}); err != nil {
+			return nil, -1, err
+		}
+	}
+
+	s, err := e.vault.NewNote(notePath)
+	if err != nil {
+		return nil, -1, err
+	}
+	_, err = s.InsertAfter(&[]api.Match{{Begin: 0, End: 0}}, func(_ api.Match, _ api.Data) ([]byte, bool) {
+		return []byte(b.String()), true
+	})
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for _, p := range e.Properties {
+		if _, err := frontmatter.Set(s, p.Key, p.Value); err != nil {
+			return nil, -1, err
+		}
+	}
+
+	// The cursor is found only now, the properties may have moved it.
+	marks, err := s.CompiledMatch(regexp.QuoteMeta(cursorMark))
+	if err != nil {
+		return nil, -1, err
+	}
+	cursor := -1
+	if len(*marks) > 0 {
+		cursor = (*marks)[0].Begin
+		if _, err := s.Remove(marks); err != nil {
+			return nil, -1, err
+		}
+	}
+	return s, cursor, nil
+}
+
+// Builds the note like Build and writes it down. The missing folders of its path are
+// created.
+func (e *Engine) Create(name, notePath, title string) (api.Set, int, error) {
+	s, cursor, err := e.Build(name, notePath, title)
+	if err != nil {
+		return nil, -1, err
+	}
+	if _, err := e.vault.Save(s); err != nil {
+		return nil, -1, err
+	}
+	return s, cursor, nil
+}
+
+// Renders the template into the builder. The stack has the templates being rendered,
+// to find the ones including themselves.
+func (e *Engine) include(b *strings.Builder, name, title string, stack []string) error {
+	for _, n := range stack {
+		if n == name {
+			return fmt.Errorf("'%s' is included again", name)
+		}
+	}
+
+	data, err := e.read(name)
+	if err != nil {
+		return err
+	}
+	nodes, err := parse(string(data))
+	if err != nil {
+		return fmt.Errorf("template '%s': %w", name, err)
+	}
+	if err := e.render(b, nodes, title, append(stack, name)); err != nil {
+		return fmt.Errorf("template '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Reads the template with the name.
+func (e *Engine) read(name string) ([]byte, error) {
+	rel := name
+	if !vault.IsNotePath(rel) {
+		rel += vault.NOTE_EXTENSION
+	}
+	f, err := e.vault.Root().File(path.Join(e.folder, rel))
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.ReadAll()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("template '%s' not found in '%s'", name, e.folder)
+	}
+	return data, err
+}
+
+func (e *Engine) render(b *strings.Builder, nodes []node, title string, stack []string) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case node_text:
+			b.WriteString(n.text)
+		case node_cursor:
+			b.WriteString(cursorMark)
+		case node_variable:
+			value, err := e.value(n.text, n.arg, title)
+			if err != nil {
+				return err
+			}
+			b.WriteString(value)
+		case node_prompt:
+			value, err := e.ask(n.text, n.arg)
+			if err != nil {
+				return err
+			}
+			b.WriteString(value)
+		case node_include:
+			if err := e.include(b, n.text, title, stack); err != nil {
+				return err
+			}
+		case node_if:
+			branch := n.otherwise
+			if e.holds(n.cond, title) {
+				branch = n.then
+			}
+			if err := e.render(b, branch, title, stack); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Gets the value of the variable, the argument is the format of the dates and the
+// default value of the rest.
+func (e *Engine) value(name, arg, title string) (string, error) {
+	switch name {
+	case VAR_TITLE:
+		return title, nil
+	case VAR_DATE:
+		if arg == "" {
+			arg = e.DateFormat
+		}
+		return moment.Format(e.Now, arg), nil
+	case VAR_TIME:
+		if arg == "" {
+			arg = e.TimeFormat
+		}
+		return moment.Format(e.Now, arg), nil
+	}
+
+	if date, ok := e.Dates[name]; ok {
+		if arg == "" {
+			arg = e.DateFormat
+		}
+		return moment.Format(date, arg), nil
+	}
+	if value, ok := e.Vars[name]; ok {
+		return value, nil
+	}
+	if arg != "" {
+		return arg, nil
+	}
+	return "", fmt.Errorf("variable '%s' is not given", name)
+}
+
+// Gets the answer of the prompt. The variable with the same name answers it, every
+// question is asked once.
+func (e *Engine) ask(question, def string) (string, error) {
+	if value, ok := e.Vars[question]; ok {
+		return value, nil
+	}
+	if value, ok := e.answers[question]; ok {
+		return value, nil
+	}
+
+	answer := def
+	if e.Prompt != nil {
+		a, err := e.Prompt(question, def)
+		if err != nil {
+			return "", err
+		}
+		answer = a
+	}
+	e.answers[question] = answer
+	return answer, nil
+}
+
+// Checks the condition, a missing variable is empty.
+func (e *Engine) holds(c condition, title string) bool {
+	value, err := e.value(c.name, "", title)
+	if err != nil {
+		value = ""
+	}
+
+	holds := value != ""
+	switch c.op {
+	case "==":
+		holds = value == c.value
+	case "!=":
+		holds = value != c.value
+	}
+	return holds != c.negate
+}
+
+// Parses the tags of a template.
+type parser struct {
+	text string
+	tags [][]int
+	next int
+	pos  int
+}
+
+func parse(text string) ([]node, error) {
+	p := &parser{text: text, tags: tagRegex.FindAllStringSubmatchIndex(text, -1)}
+	nodes, end, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, fmt.Errorf("{{%s}} without {{#if}}", end)
+	}
+	return nodes, nil
+}
+
+// Parses the nodes until the end of the text or the tag ending the block, which is
+// returned.
+func (p *parser) block() ([]node, string, error) {
+	nodes := make([]node, 0)
+	for p.next < len(p.tags) {
+		m := p.tags[p.next]
+		p.next++
+		tag := p.text[m[2]:m[3]]
+
+		begin, end := m[0], m[1]
+		isBlock := tag == "else" || strings.HasPrefix(tag, "#") || strings.HasPrefix(tag, "/")
+		if isBlock {
+			// A block tag alone on its line takes the whole line.
+			begin, end = p.standalone(begin, end)
+		}
+		if p.pos < begin {
+			nodes = append(nodes, node{kind: node_text, text: p.text[p.pos:begin]})
+		}
+		p.pos = end
+
+		keyword, rest, _ := strings.Cut(tag, " ")
+		switch {
+		case tag == "else" || keyword == "/if" || keyword == "/unless":
+			return nodes, keyword, nil
+		case keyword == "#if" || keyword == "#unless":
+			cond, err := parseCondition(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			cond.negate = keyword == "#unless"
+
+			n := node{kind: node_if, cond: cond}
+			closing := ""
+			if n.then, closing, err = p.block(); err != nil {
+				return nil, "", err
+			}
+			if closing == "else" {
+				if n.otherwise, closing, err = p.block(); err != nil {
+					return nil, "", err
+				}
+			}
+			if closing != "/"+keyword[1:] {
+				return nil, "", fmt.Errorf("{{%s}} is not closed with {{/%s}}", tag, keyword[1:])
+			}
+			nodes = append(nodes, n)
+		case strings.HasPrefix(tag, ">"):
+			nodes = append(nodes, node{kind: node_include, text: strings.TrimSpace(tag[1:])})
+		case tag == "cursor":
+			nodes = append(nodes, node{kind: node_cursor})
+		case strings.HasPrefix(tag, "prompt:"):
+			question, def, _ := strings.Cut(tag[len("prompt:"):], "|")
+			nodes = append(nodes, node{kind: node_prompt, text: strings.TrimSpace(question), arg: strings.TrimSpace(def)})
+		case tag == "" || isBlock:
+			return nil, "", fmt.Errorf("unknown tag {{%s}}", tag)
+		default:
+			name, arg, _ := strings.Cut(tag, ":")
+			nodes = append(nodes, node{kind: node_variable, text: strings.TrimSpace(name), arg: arg})
+		}
+	}
+
+	if p.pos < len(p.text) {
+		nodes = append(nodes, node{kind: node_text, text: p.text[p.pos:]})
+		p.pos = len(p.text)
+	}
+	return nodes, "", nil
+}
+
+// Extends the tag to its whole line, with the new line, if there is nothing else on
+// the line.
+func (p *parser) standalone(begin, end int) (int, int) {
+	lineBegin := strings.LastIndexByte(p.text[:begin], '\n') + 1
+	lineEnd := len(p.text)
+	if i := strings.IndexByte(p.text[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if lineBegin < p.pos {
+		return begin, end
+	}
+	if strings.TrimSpace(p.text[lineBegin:begin]) != "" || strings.TrimSpace(p.text[end:lineEnd]) != "" {
+		return begin, end
+	}
+	return lineBegin, lineEnd
+}
+
+// Parses the condition of an '#if'.
+func parseCondition(text string) (condition, error) {
+	for _, op := range []string{"==", "!="} {
+		if name, value, ok := strings.Cut(text, op); ok {
+			value = strings.TrimSpace(value)
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			}
+			return condition{name: strings.TrimSpace(name), op: op, value: value}, nil
+		}
+	}
+	name := strings.TrimSpace(text)
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return condition{}, fmt.Errorf("'%s' is not a condition", text)
+	}
+	return condition{name: name}, nil
+}
@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"path"
+	"strings"
+)
+
+// Matches the path relative to the vault against the glob pattern. On top of the
+// path.Match syntax, '**' matches any number of folders. Patterns without a '/' match
+// the base name in any folder, and the patterns ending with a '/' match everything
+// under the folder.
+func MatchGlob(pattern, p string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest at every position.
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// Sets the glob patterns of the files which are not loaded as notes.
+func (v *Vault) SetIgnore(patterns []string) {
+	v.ignore = patterns
+}
+
+// Checks if the path relative to the vault is ignored.
+func (v *Vault) Ignored(rel string) bool {
+	for _, pattern := range v.ignore {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package vault
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+)
+
+// Resolves the link targets to the files of the vault the way Obsidian does. Paths are
+// relative to the vault and use forward slashes.
+type Resolver struct {
+	// Lower cased path to the actual path.
+	byPath map[string]string
+
+	// Lower cased base name, with and without the note extension, to the paths.
+	byName map[string][]string
+}
+
+func NewResolver(paths []string) *Resolver {
+	r := &Resolver{
+		byPath: make(map[string]string),
+		byName: make(map[string][]string),
+	}
+	for _, p := range paths {
+		r.Add(p)
+	}
+	return r
+}
+
+// Builds the resolver over every file of the vault, notes and attachments.
+func (v *Vault) Resolver() (*Resolver, error) {
+	paths := make([]string, 0)
+	err := v.root.Walk(func(f *file.File) error {
+		if rel, err := v.root.Rel(f); err == nil {
+			paths = append(paths, rel)
+			return nil
+		} else {
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(paths), nil
+}
+
+// Adds a file to the resolver.
+func (r *Resolver) Add(p string) {
+	lower := strings.ToLower(p)
+	r.byPath[lower] = p
+
+	base := path.Base(lower)
+	r.byName[base] = appendSorted(r.byName[base], p)
+	if strings.HasSuffix(base, NOTE_EXTENSION) {
+		name := strings.TrimSuffix(base, NOTE_EXTENSION)
+		r.byName[name] = appendSorted(r.byName[name], p)
+	}
+}
+
+// Removes a file from the resolver.
+func (r *Resolver) Remove(p string) {
+	lower := strings.ToLower(p)
+	delete(r.byPath, lower)
+
+	base := path.Base(lower)
+	for _, name := range []string{base, strings.TrimSuffix(base, NOTE_EXTENSION)} {
+		paths := r.byName[name][:0]
+		for _, q := range r.byName[name] {
+			if q != p {
+				paths = append(paths, q)
+			}
+		}
+		r.byName[name] = paths
+	}
+}
+
+func appendSorted(paths []string, p string) []string {
+	for _, q := range paths {
+		if q == p {
+			return paths
+		}
+	}
+	paths = append(paths, p)
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+// Checks if the file exists in the vault.
+func (r *Resolver) Exists(p string) bool {
+	_, ok := r.byPath[strings.ToLower(p)]
+	return ok
+}
+
+// Gets every file of the resolver, sorted.
+func (r *Resolver) Paths() []string {
+	paths := make([]string, 0, len(r.byPath))
+	for _, p := range r.byPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Resolves the link target written in the note at the given path. An empty target
+// refers to the note itself, e.g. '[[#heading]]'. The comparison is case insensitive.
+func (r *Resolver) Resolve(from, target string) (string, bool) {
+	target = strings.TrimSpace(strings.ReplaceAll(target, "\\", "/"))
+	if target == "" {
+		return from, from != ""
+	}
+
+	lookup := func(p string) (string, bool) {
+		p = strings.ToLower(strings.TrimPrefix(path.Clean(p), "/"))
+		if q, ok := r.byPath[p]; ok {
+			return q, true
+		}
+		if q, ok := r.byPath[p+NOTE_EXTENSION]; ok {
+			return q, true
+		}
+		return "", false
+	}
+
+	// Explicitly relative paths are relative to the folder of the note.
+	if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		return lookup(path.Join(path.Dir(from), target))
+	}
+
+	if q, ok := lookup(target); ok {
+		return q, true
+	}
+	if q, ok := lookup(path.Join(path.Dir(from), target)); ok {
+		return q, true
+	}
+
+	lower := strings.ToLower(target)
+	if strings.Contains(lower, "/") {
+		// A partial path, pick the shortest one ending with it.
+		best := ""
+		for l, q := range r.byPath {
+			if strings.HasSuffix(l, "/"+lower) || strings.HasSuffix(l, "/"+lower+NOTE_EXTENSION) {
+				if best == "" || len(q) < len(best) || (len(q) == len(best) && q < best) {
+					best = q
+				}
+			}
+		}
+		return best, best != ""
+	}
+
+	candidates := r.byName[lower]
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// Prefer the one next to the note, then the shortest path.
+	dir := path.Dir(from)
+	for _, q := range candidates {
+		if path.Dir(q) == dir {
+			return q, true
+		}
+	}
+	return candidates[0], true
+}
+
+// Gets the shortest target which resolves to the given path from anywhere in the
+// vault. This is the base name when it is unique, otherwise the full path. The note
+// extension is dropped.
+func (r *Resolver) ShortestTarget(p string) string {
+	name := strings.TrimSuffix(path.Base(p), NOTE_EXTENSION)
+	if strings.HasSuffix(strings.ToLower(p), NOTE_EXTENSION) && len(r.byName[strings.ToLower(name)]) <= 1 {
+		return name
+	}
+	if len(r.byName[strings.ToLower(path.Base(p))]) <= 1 {
+		return path.Base(p)
+	}
+	if strings.HasSuffix(strings.ToLower(p), NOTE_EXTENSION) {
+		return p[:len(p)-len(NOTE_EXTENSION)]
+	}
+	return p
+}
@@ -0,0 +1,136 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/diff"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+)
+
+// A group of writes and renames which are applied together. The writes are done
+// first, so the notes which are also renamed are written to their old paths. If
+// anything fails, the changes already made are undone. Until then the changes can be
+// shown as a diff.
+type Transaction struct {
+	vault   *Vault
+	writes  []api.Set
+	seen    map[api.Set]bool
+	renames [][2]string
+}
+
+// Starts a transaction on the vault, nothing is written until it is committed.
+func (v *Vault) Begin() *Transaction {
+	return &Transaction{
+		vault:   v,
+		writes:  make([]api.Set, 0),
+		seen:    make(map[api.Set]bool),
+		renames: make([][2]string, 0),
+	}
+}
+
+// Writes the set down when committed, if it is modified. A set is written once
+// however many times it is given.
+func (tx *Transaction) Write(s api.Set) {
+	if !tx.seen[s] {
+		tx.seen[s] = true
+		tx.writes = append(tx.writes, s)
+	}
+}
+
+// Moves the file between the paths relative to the vault when committed.
+func (tx *Transaction) Rename(from, to string) {
+	tx.renames = append(tx.renames, [2]string{from, to})
+}
+
+// Gets the sets written by the transaction, sorted by their paths.
+func (tx *Transaction) Changed() []api.Set {
+	sets := append([]api.Set{}, tx.writes...)
+	sort.Slice(sets, func(i, j int) bool {
+		return tx.vault.RelPath(sets[i]) < tx.vault.RelPath(sets[j])
+	})
+	return sets
+}
+
+// Renders the writes as a unified diff against the notes on the disk, followed by
+// the renames.
+func (tx *Transaction) Diff() (string, error) {
+	out := new(strings.Builder)
+	for _, s := range tx.Changed() {
+		f, err := file.NewFile(s.Attributes().Name())
+		if err != nil {
+			return "", err
+		}
+		old, err := f.ReadAll()
+		if err != nil {
+			return "", err
+		}
+		name := tx.vault.RelPath(s)
+		out.WriteString(diff.Unified("a/"+name, "b/"+name, old, *s.Data(), diff.DEFAULT_CONTEXT))
+	}
+	for _, r := range tx.renames {
+		fmt.Fprintf(out, "rename %s -> %s\n", r[0], r[1])
+	}
+	return out.String(), nil
+}
+
+// Applies the writes and then the renames. On an error, the files already written
+// get their old contents back, the created ones are removed and the renamed ones are
+// moved back.
+func (tx *Transaction) Commit() error {
+	originals := make(map[*file.File][]byte)
+	created := make([]*file.File, 0)
+	moved := make([][2]*file.File, 0)
+	rollback := func(err error) error {
+		for i := len(moved) - 1; i >= 0; i-- {
+			moved[i][1].Rename(moved[i][0])
+		}
+		for f, data := range originals {
+			f.WriteAll(data)
+		}
+		for _, f := range created {
+			f.Remove()
+		}
+		return fmt.Errorf("%w, the changes are rolled back", err)
+	}
+
+	for _, s := range tx.writes {
+		if s.Attributes().Version() == 0 {
+			continue
+		}
+		f, err := file.NewFile(s.Attributes().Name())
+		if err != nil {
+			return rollback(err)
+		}
+		if ok, err := f.Exists(); err != nil {
+			return rollback(err)
+		} else if !ok {
+			created = append(created, f)
+		} else if data, err := f.ReadAll(); err == nil {
+			originals[f] = data
+		} else {
+			return rollback(err)
+		}
+		if _, err := tx.vault.Save(s); err != nil {
+			return rollback(err)
+		}
+	}
+
+	for _, r := range tx.renames {
+		from, err := tx.vault.root.File(r[0])
+		if err != nil {
+			return rollback(err)
+		}
+		to, err := tx.vault.root.File(r[1])
+		if err != nil {
+			return rollback(err)
+		}
+		if err := from.Rename(to); err != nil {
+			return rollback(err)
+		}
+		moved = append(moved, [2]*file.File{from, to})
+	}
+	return nil
+}
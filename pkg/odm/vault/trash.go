@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The folder Obsidian moves the deleted files to, when it is set to use the vault trash.
+const TRASH_FOLDER = ".trash"
+
+// Moves the file at the given path to the trash folder of the vault instead of deleting
+// it. The folders are kept, and a number is appended to the name if there is already a
+// file with the same name in the trash. Returns the path of the file in the trash.
+func (v *Vault) Trash(rel string) (string, error) {
+	f, err := v.root.File(rel)
+	if err != nil {
+		return "", err
+	}
+
+	ext := path.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	for i := 0; ; i++ {
+		dest := path.Join(TRASH_FOLDER, rel)
+		if i > 0 {
+			dest = path.Join(TRASH_FOLDER, fmt.Sprintf("%s %d%s", base, i, ext))
+		}
+
+		df, err := v.root.File(dest)
+		if err != nil {
+			return "", err
+		}
+		if ok, err := df.Exists(); err != nil {
+			return "", err
+		} else if ok {
+			continue
+		}
+
+		if err := f.Rename(df); err != nil {
+			return "", err
+		}
+		return dest, nil
+	}
+}
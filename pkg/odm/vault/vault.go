@@ -0,0 +1,257 @@
+package vault
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ubombar/obsidian-document-manager/pkg/odm"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/api"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/cache"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/file"
+	"github.com/ubombar/obsidian-document-manager/pkg/odm/metadata"
+)
+
+// The folder under the vault where odm keeps its own files.
+const ODM_FOLDER = ".odm"
+
+// The extension of the notes.
+const NOTE_EXTENSION = ".md"
+
+// The size and the modification time of a note when it is loaded.
+type stat struct {
+	size    int64
+	modTime time.Time
+}
+
+// The memoized metadata of a set.
+type memo struct {
+	hash     cache.Hash
+	metadata *metadata.Metadata
+}
+
+// A vault is a folder of notes. It loads the notes as a Group and keeps their parsed
+// metadata in a persistent cache, so only the changed notes are parsed again.
+type Vault struct {
+	root  *file.Folder
+	cache *cache.Cache
+	stats map[string]stat
+	memos map[string]memo
+
+	// Glob patterns of the files which are not loaded.
+	ignore []string
+}
+
+// Opens the vault at the given folder. The cache is loaded from the '.odm' folder.
+func Open(path string) (*Vault, error) {
+	root, err := file.NewFolder(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheFile, err := root.File(ODM_FOLDER + "/" + cache.CACHE_FILE_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cache.Load(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Vault{
+		root:  root,
+		cache: c,
+		stats: make(map[string]stat),
+		memos: make(map[string]memo),
+	}, nil
+}
+
+// Gets the root folder of the vault.
+func (v *Vault) Root() *file.Folder {
+	return v.root
+}
+
+// Checks if the file is a note.
+func IsNote(f *file.File) bool {
+	return strings.EqualFold(f.Extension(), NOTE_EXTENSION)
+}
+
+// Checks if the path is the path of a note.
+func IsNotePath(p string) bool {
+	return strings.EqualFold(path.Ext(p), NOTE_EXTENSION)
+}
+
+// Loads every note in the vault as a group, except the ignored ones. The metadata of
+// each note is read from the cache or parsed, so the later queries are cheap. The
+// entries of the deleted notes are dropped from the cache.
+func (v *Vault) Load() (api.Group, error) {
+	g := odm.NewEmptyGroup()
+
+	err := v.root.Walk(func(f *file.File) error {
+		if !IsNote(f) {
+			return nil
+		}
+		if rel, err := v.root.Rel(f); err != nil {
+			return err
+		} else if v.Ignored(rel) {
+			return nil
+		}
+
+		s, err := v.LoadSet(f)
+		if err != nil {
+			return err
+		}
+
+		if _, err := g.Add(s); err != nil {
+			return err
+		}
+
+		_, err = v.Metadata(s)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	v.cache.Prune()
+	return g, nil
+}
+
+// Loads a single note as a set and remembers its size and modification time.
+func (v *Vault) LoadSet(f *file.File) (api.Set, error) {
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	modTime, err := f.ModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := odm.NewSetFromData(data, f)
+	if err != nil {
+		return nil, err
+	}
+
+	v.stats[f.String()] = stat{size: size, modTime: modTime}
+	return s, nil
+}
+
+// Gets the note at the path relative to the vault as a set. If there is no such note
+// the set is empty, and the file is created when it is saved.
+func (v *Vault) NewNote(rel string) (api.Set, error) {
+	f, err := v.root.File(rel)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := f.Exists(); err != nil {
+		return nil, err
+	} else if ok {
+		return v.LoadSet(f)
+	}
+	return odm.NewSetFromData([]byte{}, f)
+}
+
+// Gets the path of the set relative to the vault. In memory sets are not in the vault,
+// so their names are returned as is.
+func (v *Vault) RelPath(s api.Set) string {
+	name := s.Attributes().Name()
+	root := v.root.String()
+
+	if root == "/" && strings.HasPrefix(name, "/") {
+		return name[1:]
+	}
+	if strings.HasPrefix(name, root+"/") {
+		return name[len(root)+1:]
+	}
+	return name
+}
+
+// Gets the metadata of the set. The unmodified notes are looked up in the cache by their
+// size and modification time, the rest by the hash of their contents. Only on a miss the
+// set is parsed.
+func (v *Vault) Metadata(s api.Set) (*metadata.Metadata, error) {
+	name := s.Attributes().Name()
+	path := v.RelPath(s)
+	st, onDisk := v.stats[name]
+
+	if onDisk && s.Attributes().Version() == 0 {
+		if md, ok := v.cache.Stat(path, st.size, st.modTime); ok {
+			return md, nil
+		}
+	}
+
+	data := *s.Data()
+	hash := cache.HashOf(data)
+
+	if m, ok := v.memos[name]; ok && m.hash == hash {
+		return m.metadata, nil
+	}
+
+	if !onDisk || s.Attributes().Version() != 0 {
+		// The set is not the same as the file on the disk, don't touch the cache.
+		md := metadata.Parse(path, data)
+		v.memos[name] = memo{hash: hash, metadata: md}
+		return md, nil
+	}
+
+	md, ok := v.cache.Lookup(path, hash, st.size, st.modTime)
+	if !ok {
+		md = metadata.Parse(path, data)
+		v.cache.Put(path, hash, st.size, st.modTime, md)
+	}
+
+	v.memos[name] = memo{hash: hash, metadata: md}
+	return md, nil
+}
+
+// Writes the set down to its file if it is modified since it is loaded. Returns false
+// if there was nothing to write. The cache is updated with the new contents.
+func (v *Vault) Save(s api.Set) (bool, error) {
+	if s.Attributes().Version() == 0 {
+		return false, nil
+	}
+
+	f, err := file.NewFile(s.Attributes().Name())
+	if err != nil {
+		return false, err
+	}
+
+	if parent, err := f.Parent(); err != nil {
+		return false, err
+	} else if _, err := parent.Create(); err != nil {
+		return false, err
+	}
+	if err := f.WriteAll(*s.Data()); err != nil {
+		return false, err
+	}
+
+	size, err := f.Size()
+	if err != nil {
+		return false, err
+	}
+	modTime, err := f.ModTime()
+	if err != nil {
+		return false, err
+	}
+
+	path := v.RelPath(s)
+	md := metadata.Parse(path, *s.Data())
+	hash := cache.HashOf(*s.Data())
+	v.cache.Put(path, hash, size, modTime, md)
+	v.stats[f.String()] = stat{size: size, modTime: modTime}
+	v.memos[f.String()] = memo{hash: hash, metadata: md}
+	return true, nil
+}
+
+// Writes the cache down to the disk.
+func (v *Vault) Close() error {
+	return v.cache.Save()
+}